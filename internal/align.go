@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/pterm/pterm/putils"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultAlignInterval = time.Second
+	defaultAlignWindow   = time.Minute
+
+	alignSINR = "sinr"
+	alignRSRP = "rsrp"
+
+	// alignGaugeWidth is the number of cells of the gauge.
+	alignGaugeWidth = 50
+	bell            = "\a"
+)
+
+// ErrNoSignal is returned by align for a sample without 4G or 5G signal.
+var ErrNoSignal = errors.New("no 4G or 5G signal to align on")
+
+// alignScales are the values at the ends of the gauge of each metric, from
+// no service to the best values gateways report.
+//
+//nolint:gochecknoglobals
+var alignScales = map[string][2]float64{
+	alignSINR: {-10, 30},
+	alignRSRP: {-140, -44},
+}
+
+// bellEvery is the number of samples between two bells at each quality, so
+// that they ring faster as the signal improves. Other qualities, such as no
+// signal at all, do not ring.
+//
+//nolint:gochecknoglobals
+var bellEvery = map[signal.Quality]int{
+	signal.Excellent: 1,
+	signal.Good:      2,
+	signal.Fair:      3,
+	signal.Poor:      5,
+}
+
+// alignSample is a value of the aligned metric and when it was read.
+type alignSample struct {
+	at    time.Time
+	value float64
+}
+
+// alignReading is the aligned metric in one signal sample, with the best
+// value of the window.
+type alignReading struct {
+	radio  dashboardRadio
+	rating signal.Rating
+	best   float64
+}
+
+// aligner follows one metric of the preferred radio, 5G over 4G, keeping the
+// samples of the last window to mark the best one.
+type aligner struct {
+	metric  string
+	window  time.Duration
+	now     func() time.Time
+	samples []alignSample
+	// bell, when set, receives the terminal bells.
+	bell      io.Writer
+	sinceBell int
+}
+
+func newAligner(metric string, window time.Duration) *aligner {
+	return &aligner{metric: metric, window: window, now: time.Now}
+}
+
+// add reads the aligned metric from result, ringing the bell when it is due.
+func (al *aligner) add(result *tmhi.SignalResult) (*alignReading, error) {
+	radios := signalRadios(result)
+	if len(radios) == 0 {
+		return nil, ErrNoSignal
+	}
+
+	// 5G comes last, and is preferred.
+	radio := radios[len(radios)-1]
+
+	var rating signal.Rating
+
+	for _, metric := range rateSignalData(radio.data) {
+		if strings.EqualFold(metric.name, al.metric) {
+			rating = metric.rating
+		}
+	}
+
+	now := al.now()
+	al.samples = append(al.samples, alignSample{at: now, value: rating.Value})
+
+	for len(al.samples) > 1 && now.Sub(al.samples[0].at) > al.window {
+		al.samples = al.samples[1:]
+	}
+
+	best := math.Inf(-1)
+	for _, sample := range al.samples {
+		best = max(best, sample.value)
+	}
+
+	al.ring(rating.Quality)
+
+	return &alignReading{radio: radio, rating: rating, best: best}, nil
+}
+
+func (al *aligner) ring(quality signal.Quality) {
+	if al.bell == nil {
+		return
+	}
+
+	every, ok := bellEvery[quality]
+	if !ok {
+		return
+	}
+
+	al.sinceBell++
+	if al.sinceBell >= every {
+		al.sinceBell = 0
+		_, _ = io.WriteString(al.bell, bell)
+	}
+}
+
+func (al *aligner) render(reading *alignReading) string {
+	var out strings.Builder
+
+	style := qualityStyle(reading.rating.Quality)
+	unit := reading.rating.Metric.Unit()
+	value := formatAlignValue(reading.rating.Value)
+
+	fmt.Fprintf(&out, "%s %s, best over the last %s: %s %s\n\n",
+		reading.radio.name, strings.ToUpper(al.metric), al.window,
+		formatAlignValue(reading.best), unit)
+
+	big, err := pterm.DefaultBigText.
+		WithLetters(putils.LettersFromStringWithStyle(value, &style)).
+		Srender()
+	if err != nil {
+		big = style.Sprintln(value)
+	}
+
+	out.WriteString(strings.TrimRight(big, "\n") + "  " +
+		style.Sprint(unit+"  "+reading.rating.Quality.String()+" "+
+			reading.rating.Quality.Stars()) + "\n\n")
+	out.WriteString(al.gauge(reading, style) + "\n")
+
+	header := reading.radio.name + " Signal"
+	details := [][]string{{"Node", strings.Join(reading.radio.details, ", ")}}
+	out.WriteString(renderSignalMetrics(header, reading.radio.data, nil, details...))
+
+	return out.String()
+}
+
+// gauge draws the value as a bar over the scale of the metric, with a
+// marker under the best value.
+func (al *aligner) gauge(reading *alignReading, style pterm.Style) string {
+	scale := alignScales[al.metric]
+	position := func(value float64) int {
+		ratio := (value - scale[0]) / (scale[1] - scale[0])
+
+		return int(math.Round(min(max(ratio, 0), 1) * alignGaugeWidth))
+	}
+
+	filled := position(reading.rating.Value)
+	bar := style.Sprint(strings.Repeat("█", filled)) +
+		pterm.Gray(strings.Repeat("░", alignGaugeWidth-filled))
+
+	marker := strings.Repeat(" ", max(position(reading.best)-1, 0)) + "▲ best " +
+		formatAlignValue(reading.best)
+
+	return formatAlignValue(scale[0]) + " " + bar + " " + formatAlignValue(scale[1]) + "\n" +
+		strings.Repeat(" ", len(formatAlignValue(scale[0]))+1) + marker + "\n"
+}
+
+func formatAlignValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+type alignJSON struct {
+	Radio   string  `json:"radio"`
+	Metric  string  `json:"metric"`
+	Value   float64 `json:"value"`
+	Unit    string  `json:"unit"`
+	Quality string  `json:"quality"`
+	Best    float64 `json:"best"`
+}
+
+func (al *aligner) toJSON(reading *alignReading) any {
+	return alignJSON{
+		Radio:   reading.radio.name,
+		Metric:  al.metric,
+		Value:   reading.rating.Value,
+		Unit:    reading.rating.Metric.Unit(),
+		Quality: reading.rating.Quality.String(),
+		Best:    reading.best,
+	}
+}
+
+// align samples the signal every interval, showing one metric large with
+// the best value of the window, until interrupted.
+func (a *app) align(ctx context.Context, cmd *cli.Command) error {
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	al := newAligner(cmd.String(ConfigMetric), cmd.Duration(ConfigWindow))
+	if cmd.Bool(ConfigBell) && !a.jsonOutput() {
+		al.bell = a.stdout
+	}
+
+	return watchLoop(ctx, a, cmd.Duration(ConfigInterval),
+		func(ctx context.Context) (*alignReading, error) {
+			result, err := gateway.Signal(ctx)
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			return al.add(result)
+		},
+		func(current, _ *alignReading) string { return al.render(current) },
+		al.toJSON,
+	)
+}
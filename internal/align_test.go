@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// sinrResults returns a signal result per SINR.
+func sinrResults(sinrs ...int) []*tmhi.SignalResult {
+	results := make([]*tmhi.SignalResult, 0, len(sinrs))
+
+	for _, sinr := range sinrs {
+		result := testSignalResult()
+		result.FiveG.SINR = sinr
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func newAlignCmd(a *app) *cli.Command {
+	return &cli.Command{
+		Name:   cmdAlign,
+		Action: a.align,
+		Flags:  alignFlags(),
+	}
+}
+
+func runAlign(t *testing.T, a *app, sinrs []int, args ...string) *recordingArea {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	gw := &sequenceGateway{
+		mockGateway: &mockGateway{},
+		cancel:      cancel,
+		results:     sinrResults(sinrs...),
+	}
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) { return gw, nil }
+	liveArea := &recordingArea{}
+	a.newArea = func() (area, error) { return liveArea, nil }
+
+	args = append([]string{cmdAlign, "--interval", "1ms"}, args...)
+	require.NoError(t, newAlignCmd(a).Run(ctx, args))
+
+	return liveArea
+}
+
+func TestAligner_BestOverWindow(t *testing.T) {
+	al := newAligner(alignSINR, time.Minute)
+	now := time.Now()
+	al.now = func() time.Time { return now }
+
+	for _, step := range []struct {
+		sinr  int
+		after time.Duration
+		best  float64
+	}{
+		{sinr: 10, best: 10},
+		{sinr: 20, after: 30 * time.Second, best: 20},
+		{sinr: 5, after: 30 * time.Second, best: 20},
+		{sinr: 8, after: 31 * time.Second, best: 8},
+	} {
+		now = now.Add(step.after)
+		result := testSignalResult()
+		result.FiveG.SINR = step.sinr
+
+		reading, err := al.add(result)
+		require.NoError(t, err)
+		assert.InDelta(t, float64(step.sinr), reading.rating.Value, 0)
+		assert.InDelta(t, step.best, reading.best, 0, "SINR %d", step.sinr)
+	}
+}
+
+func TestAligner_PrefersFiveG(t *testing.T) {
+	al := newAligner(alignRSRP, time.Minute)
+
+	result := testSignalResult()
+	result.FourG = &tmhi.FourGSignal{SignalData: tmhi.SignalData{RSRP: -80}}
+
+	reading, err := al.add(result)
+	require.NoError(t, err)
+	assert.Equal(t, "5G", reading.radio.name)
+	assert.InDelta(t, -95, reading.rating.Value, 0)
+
+	result.FiveG = nil
+	reading, err = al.add(result)
+	require.NoError(t, err)
+	assert.Equal(t, "4G", reading.radio.name)
+
+	_, err = al.add(&tmhi.SignalResult{})
+	require.ErrorIs(t, err, ErrNoSignal)
+}
+
+func TestAligner_Bell(t *testing.T) {
+	var bells bytes.Buffer
+
+	al := newAligner(alignSINR, time.Minute)
+	al.bell = &bells
+
+	// Excellent SINR rings at every sample, poor SINR every fifth.
+	for _, sinr := range []int{25, 25, 25, -5, -5, -5, -5, -5} {
+		result := testSignalResult()
+		result.FiveG.SINR = sinr
+		_, err := al.add(result)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, strings.Repeat(bell, 4), bells.String())
+
+	// A quality without a rate never rings.
+	bells.Reset()
+
+	unrated := signal.Quality(0)
+	for bellEvery[unrated] != 0 {
+		unrated++
+	}
+
+	for range 10 {
+		al.ring(unrated)
+	}
+
+	assert.Empty(t, bells.String())
+}
+
+func TestAligner_Render(t *testing.T) {
+	al := newAligner(alignSINR, time.Minute)
+
+	for _, sinr := range []int{30, 10} {
+		result := testSignalResult()
+		result.FiveG.SINR = sinr
+		reading, err := al.add(result)
+		require.NoError(t, err)
+
+		frame := pterm.RemoveColorFromString(al.render(reading))
+		assert.Contains(t, frame, "5G SINR, best over the last 1m0s: 30 dB")
+		assert.Contains(t, frame, "▲ best 30")
+		assert.Contains(t, frame, "gNB 67890")
+	}
+
+	gauge := pterm.RemoveColorFromString(al.gauge(&alignReading{
+		rating: testRating(alignSINR, 10), best: 30,
+	}, pterm.Style{}))
+	lines := strings.Split(gauge, "\n")
+	assert.Equal(t, "-10 "+strings.Repeat("█", 25)+strings.Repeat("░", 25)+" 30", lines[0])
+	assert.Equal(t, strings.Repeat(" ", 4+alignGaugeWidth-1)+"▲ best 30", lines[1])
+}
+
+func testRating(metric string, value int) signal.Rating {
+	result := testSignalResult()
+	result.FiveG.SINR = value
+	result.FiveG.RSRP = value
+
+	for _, rated := range rateSignalData(&result.FiveG.SignalData) {
+		if strings.EqualFold(rated.name, metric) {
+			return rated.rating
+		}
+	}
+
+	return signal.Rating{}
+}
+
+func TestAlign_Text(t *testing.T) {
+	a := newTestApp(nil)
+
+	var out bytes.Buffer
+
+	a.stdout = &out
+
+	liveArea := runAlign(t, a, []int{25, 15}, "--bell", "--metric", alignRSRP)
+	require.Len(t, liveArea.updates, 2)
+	assert.Contains(t, pterm.RemoveColorFromString(liveArea.updates[1]),
+		"5G RSRP, best over the last 1m0s: -95 dBm")
+	assert.True(t, liveArea.stopped)
+	assert.Equal(t, bell, out.String(), "good RSRP rings every other sample")
+}
+
+func TestAlign_JSON(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	runAlign(t, a, []int{12, 4}, "--bell")
+
+	dec := json.NewDecoder(buf)
+	for _, want := range []alignJSON{
+		{Radio: "5G", Metric: alignSINR, Value: 12, Unit: "dB", Quality: "Good", Best: 12},
+		{Radio: "5G", Metric: alignSINR, Value: 4, Unit: "dB", Quality: "Fair", Best: 12},
+	} {
+		var env struct {
+			OK   bool      `json:"ok"`
+			Data alignJSON `json:"data"`
+		}
+
+		require.NoError(t, dec.Decode(&env))
+		assert.True(t, env.OK)
+		assert.Equal(t, want, env.Data)
+	}
+
+	assert.False(t, dec.More(), "no bell in JSON output")
+}
+
+func TestAlign_Flags(t *testing.T) {
+	a := newTestApp(&mockGateway{})
+	a.newArea = func() (area, error) {
+		t.Fatal("invalid flags start nothing")
+
+		return nil, nil //nolint:nilnil
+	}
+
+	for _, args := range [][]string{
+		{cmdAlign, "--metric", "rsrq"},
+		{cmdAlign, "--window", "0s"},
+		{cmdAlign, "--interval", "-1s"},
+	} {
+		require.Error(t, newAlignCmd(a).Run(t.Context(), args), args)
+	}
+}
@@ -0,0 +1,344 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/urfave/cli/v3"
+)
+
+// Nagios plugin states, in increasing severity but for unknown. Each is also
+// the exit code of the check command.
+const (
+	checkOK = iota
+	checkWarning
+	checkCritical
+	checkUnknown
+)
+
+// Threshold levels of the check flags.
+const (
+	checkLevelWarning  = "warning"
+	checkLevelCritical = "critical"
+)
+
+// Bounds of the scan for the quality boundaries of signal.Rater.
+const (
+	minRatedValue = -200
+	maxRatedValue = 100
+)
+
+//nolint:gochecknoglobals
+var checkStateNames = [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// Errors returned by check for a state other than OK, so the exit code can
+// follow the Nagios plugin API.
+var (
+	ErrCheckWarning  = errors.New("check warning")
+	ErrCheckCritical = errors.New("check critical")
+	ErrCheckUnknown  = errors.New("check unknown")
+)
+
+//nolint:gochecknoglobals
+var checkStateErrors = map[int]error{
+	checkWarning:  ErrCheckWarning,
+	checkCritical: ErrCheckCritical,
+	checkUnknown:  ErrCheckUnknown,
+}
+
+// checkThreshold alerts when a metric falls below its warning or critical
+// value.
+type checkThreshold struct {
+	warning  float64
+	critical float64
+}
+
+// checkedMetric is a metric of the check with its thresholds.
+type checkedMetric struct {
+	Name     string  `json:"name"`
+	Value    float64 `json:"value"`
+	Unit     string  `json:"unit"`
+	Warning  float64 `json:"warning"`
+	Critical float64 `json:"critical"`
+	State    string  `json:"state"`
+}
+
+// perfdata returns the metric as Nagios performance data.
+func (m checkedMetric) perfdata() string {
+	return strings.ToLower(m.Name) + "=" + formatCheckValue(m.Value) + m.Unit + ";" +
+		formatCheckValue(m.Warning) + ";" + formatCheckValue(m.Critical)
+}
+
+// checkResult is the outcome of a check.
+type checkResult struct {
+	state   int
+	network string
+	problem string
+	metrics []checkedMetric
+}
+
+// checkJSON is the JSON document of a check.
+type checkJSON struct {
+	State    string          `json:"state"`
+	ExitCode int             `json:"exit_code"`
+	Summary  string          `json:"summary"`
+	Network  string          `json:"network,omitempty"`
+	Metrics  []checkedMetric `json:"metrics,omitempty"`
+}
+
+// summary is the human-readable part of the plugin output.
+func (r checkResult) summary() string {
+	if r.problem != "" {
+		return r.problem
+	}
+
+	parts := make([]string, 0, len(r.metrics))
+
+	for _, m := range r.metrics {
+		part := m.Name + " " + formatCheckValue(m.Value) + " " + m.Unit
+
+		switch m.State {
+		case checkStateNames[checkCritical]:
+			part += " (critical below " + formatCheckValue(m.Critical) + ")"
+		case checkStateNames[checkWarning]:
+			part += " (warning below " + formatCheckValue(m.Warning) + ")"
+		}
+
+		parts = append(parts, part)
+	}
+
+	return r.network + " " + strings.Join(parts, ", ")
+}
+
+// line returns the plugin output: the state, the summary and the
+// performance data.
+func (r checkResult) line() string {
+	line := "TMHI " + checkStateNames[r.state] + " - " + r.summary()
+	if len(r.metrics) == 0 {
+		return line
+	}
+
+	perfdata := make([]string, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		perfdata = append(perfdata, m.perfdata())
+	}
+
+	return line + " | " + strings.Join(perfdata, " ")
+}
+
+func (r checkResult) toJSON() checkJSON {
+	return checkJSON{
+		State:    checkStateNames[r.state],
+		ExitCode: r.state,
+		Summary:  r.summary(),
+		Network:  r.network,
+		Metrics:  r.metrics,
+	}
+}
+
+func formatCheckValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// raterThreshold returns the lowest whole value that rate rates at least
+// quality, signal.Rater not exposing its boundaries.
+func raterThreshold(rate func(float64) signal.Rating, quality signal.Quality) float64 {
+	for v := minRatedValue; v <= maxRatedValue; v++ {
+		if rate(float64(v)).Quality >= quality {
+			return float64(v)
+		}
+	}
+
+	return maxRatedValue
+}
+
+// defaultCheckThresholds warns below the Good quality of signal.Rater and
+// is critical below Fair, keyed by metric name.
+func defaultCheckThresholds() map[string]checkThreshold {
+	rater := signal.NewRater()
+	thresholds := map[string]checkThreshold{}
+
+	for name, rate := range map[string]func(float64) signal.Rating{
+		"RSRP": rater.RateRSRP,
+		"RSRQ": rater.RateRSRQ,
+		"RSSI": rater.RateRSSI,
+		"SINR": rater.RateSINR,
+	} {
+		thresholds[name] = checkThreshold{
+			warning:  raterThreshold(rate, signal.Good),
+			critical: raterThreshold(rate, signal.Fair),
+		}
+	}
+
+	return thresholds
+}
+
+// evaluateCheck rates the web interface and the signal of the preferred
+// radio, 5G over 4G, against thresholds.
+func evaluateCheck(
+	status *tmhi.StatusResult,
+	result *tmhi.SignalResult,
+	thresholds map[string]checkThreshold,
+) checkResult {
+	if !status.WebInterfaceUp {
+		return checkResult{state: checkCritical, problem: "web interface is down"}
+	}
+
+	radios := signalRadios(result)
+	if len(radios) == 0 {
+		return checkResult{state: checkCritical, problem: "no 4G or 5G signal"}
+	}
+
+	// 5G comes last, and is preferred.
+	radio := radios[len(radios)-1]
+	check := checkResult{state: checkOK, network: radio.name}
+
+	for _, metric := range rateSignalData(radio.data) {
+		threshold := thresholds[metric.name]
+		state := checkOK
+
+		switch {
+		case metric.rating.Value < threshold.critical:
+			state = checkCritical
+		case metric.rating.Value < threshold.warning:
+			state = checkWarning
+		}
+
+		check.state = max(check.state, state)
+		check.metrics = append(check.metrics, checkedMetric{
+			Name:     metric.name,
+			Value:    metric.rating.Value,
+			Unit:     metric.rating.Metric.Unit(),
+			Warning:  threshold.warning,
+			Critical: threshold.critical,
+			State:    checkStateNames[state],
+		})
+	}
+
+	return check
+}
+
+// checkThresholds returns the thresholds of cmd, the defaults of
+// signal.Rater filling in those not set.
+func checkThresholds(cmd *cli.Command) map[string]checkThreshold {
+	thresholds := defaultCheckThresholds()
+
+	for name, threshold := range thresholds {
+		if flag := checkFlagName(name, checkLevelWarning); cmd.IsSet(flag) {
+			threshold.warning = cmd.Float(flag)
+		}
+
+		if flag := checkFlagName(name, checkLevelCritical); cmd.IsSet(flag) {
+			threshold.critical = cmd.Float(flag)
+		}
+
+		thresholds[name] = threshold
+	}
+
+	return thresholds
+}
+
+// checkFlagName returns the flag setting the level threshold of metric,
+// e.g. check.sinr-warning.
+func checkFlagName(metric, level string) string {
+	return ConfigCheck + strings.ToLower(metric) + "-" + level
+}
+
+// runCheck queries the gateway, reporting a failed query as unknown.
+func runCheck(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	thresholds map[string]checkThreshold,
+) checkResult {
+	status, err := gateway.Status(ctx)
+	if err != nil {
+		return checkResult{state: checkUnknown, problem: "failed to get status: " + err.Error()}
+	}
+
+	if !status.WebInterfaceUp {
+		return evaluateCheck(status, nil, thresholds)
+	}
+
+	result, err := gateway.Signal(ctx)
+	if err != nil {
+		return checkResult{state: checkUnknown, problem: "failed to get signal: " + err.Error()}
+	}
+
+	return evaluateCheck(status, result, thresholds)
+}
+
+// beginCheck marks check as selected before the flag actions and the
+// action run, for their failures must exit UNKNOWN too.
+func (a *app) beginCheck(ctx context.Context, _ *cli.Command) (context.Context, error) {
+	a.checking = true
+
+	return ctx, nil
+}
+
+// checkUsageError marks check as selected when its flags cannot be parsed,
+// before beginCheck runs.
+func (a *app) checkUsageError(_ context.Context, _ *cli.Command, err error, _ bool) error {
+	a.checking = true
+
+	return err
+}
+
+// checkFailed reports err, raised outside the action of check, as its
+// UNKNOWN state.
+func (a *app) checkFailed(err error) error {
+	for _, stateErr := range checkStateErrors {
+		if errors.Is(err, stateErr) {
+			return err
+		}
+	}
+
+	result := checkResult{state: checkUnknown, problem: err.Error()}
+	if writeErr := a.writeCheck(result); writeErr != nil {
+		return writeErr
+	}
+
+	return documented(fmt.Errorf("%w: %w", ErrCheckUnknown, err))
+}
+
+// check prints a single Nagios plugin line and returns the error matching
+// its state, which Cmd does not print again.
+func (a *app) check(ctx context.Context, cmd *cli.Command) error {
+	var result checkResult
+
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		result = checkResult{state: checkUnknown, problem: err.Error()}
+	} else {
+		result = runCheck(ctx, gateway, checkThresholds(cmd))
+	}
+
+	if err := a.writeCheck(result); err != nil {
+		return err
+	}
+
+	if stateErr, ok := checkStateErrors[result.state]; ok {
+		return documented(fmt.Errorf("%w: %s", stateErr, a.secrets.redact(result.summary())))
+	}
+
+	return nil
+}
+
+// writeCheck prints the plugin line of result, or its JSON document.
+func (a *app) writeCheck(result checkResult) error {
+	result.problem = a.secrets.redact(result.problem)
+
+	if a.jsonOutput() {
+		return a.writeJSON(result.toJSON())
+	}
+
+	if _, err := fmt.Fprintln(a.stdout, result.line()); err != nil {
+		return fmt.Errorf("failed to write check result: %w", err)
+	}
+
+	return nil
+}
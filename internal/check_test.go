@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+	"github.com/zenizh/go-capturer"
+)
+
+func testCheckThresholds() map[string]checkThreshold {
+	return map[string]checkThreshold{
+		"RSRP": {warning: -105, critical: -115},
+		"RSRQ": {warning: -12, critical: -15},
+		"RSSI": {warning: -75, critical: -85},
+		"SINR": {warning: 5, critical: 0},
+	}
+}
+
+func newCheckCmd(t *testing.T, a *app) *cli.Command {
+	t.Helper()
+
+	noConfig := altsrc.StringSourcer(filepath.Join(t.TempDir(), "missing.toml"))
+
+	return &cli.Command{Name: cmdCheck, Flags: checkFlags(noConfig), Action: a.check}
+}
+
+func TestDefaultCheckThresholds(t *testing.T) {
+	rater := signal.NewRater()
+	thresholds := defaultCheckThresholds()
+
+	require.Len(t, thresholds, 4)
+
+	sinr := thresholds["SINR"]
+	assert.Equal(t, signal.Good, rater.RateSINR(sinr.warning).Quality)
+	assert.Equal(t, signal.Fair, rater.RateSINR(sinr.warning-1).Quality)
+	assert.Equal(t, signal.Fair, rater.RateSINR(sinr.critical).Quality)
+	assert.Equal(t, signal.Poor, rater.RateSINR(sinr.critical-1).Quality)
+
+	rsrp := thresholds["RSRP"]
+	assert.Less(t, rsrp.critical, rsrp.warning)
+}
+
+func TestEvaluateCheck(t *testing.T) {
+	up := &tmhi.StatusResult{WebInterfaceUp: true}
+
+	result := evaluateCheck(up, testSignalResult(), testCheckThresholds())
+	assert.Equal(t, checkOK, result.state)
+	assert.Equal(t,
+		"TMHI OK - 5G RSRP -95 dBm, RSRQ -9 dB, RSSI -65 dBm, SINR 12 dB | "+
+			"rsrp=-95dBm;-105;-115 rsrq=-9dB;-12;-15 rssi=-65dBm;-75;-85 sinr=12dB;5;0",
+		result.line())
+
+	weak := testSignalResult()
+	weak.FiveG.SINR = 3
+	weak.FiveG.RSRP = -120
+
+	result = evaluateCheck(up, weak, testCheckThresholds())
+	assert.Equal(t, checkCritical, result.state)
+	assert.Contains(t, result.line(),
+		"TMHI CRITICAL - 5G RSRP -120 dBm (critical below -115), RSRQ -9 dB, "+
+			"RSSI -65 dBm, SINR 3 dB (warning below 5) |")
+
+	result = evaluateCheck(&tmhi.StatusResult{}, nil, testCheckThresholds())
+	assert.Equal(t, "TMHI CRITICAL - web interface is down", result.line())
+
+	result = evaluateCheck(up, &tmhi.SignalResult{}, testCheckThresholds())
+	assert.Equal(t, checkCritical, result.state)
+}
+
+func TestCheckCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway *mockGateway
+		args    []string
+		wantErr error
+		want    string
+	}{
+		{
+			name:    "ok",
+			gateway: &mockGateway{signalResult: testSignalResult()},
+			args:    []string{"--check.sinr-warning", "5", "--check.sinr-critical", "0"},
+			want:    "TMHI OK - 5G ",
+		},
+		{
+			name:    "warning threshold from flag",
+			gateway: &mockGateway{signalResult: testSignalResult()},
+			args:    []string{"--check.sinr-warning", "15", "--check.sinr-critical", "0"},
+			wantErr: ErrCheckWarning,
+			want:    "SINR 12 dB (warning below 15)",
+		},
+		{
+			name:    "unreachable",
+			gateway: &mockGateway{statusErr: errors.New("connection refused")},
+			wantErr: ErrCheckUnknown,
+			want:    "TMHI UNKNOWN - failed to get status: connection refused\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(tt.gateway)
+
+			var stdout bytes.Buffer
+
+			a.stdout = &stdout
+
+			err := newCheckCmd(t, a).Run(t.Context(), append([]string{cmdCheck}, tt.args...))
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				_, ok := errors.AsType[*documentedError](err)
+				assert.True(t, ok, "not reported again")
+			}
+
+			assert.Contains(t, stdout.String(), tt.want)
+		})
+	}
+}
+
+func TestCheckCommand_JSON(t *testing.T) {
+	weak := testSignalResult()
+	weak.FiveG.SINR = -3
+
+	a, out := newJSONTestApp(&mockGateway{signalResult: weak})
+
+	err := newCheckCmd(t, a).Run(t.Context(), []string{cmdCheck, "--check.sinr-critical", "0"})
+	require.ErrorIs(t, err, ErrCheckCritical)
+
+	ok, data, _ := decodeEnvelope(t, out)
+	assert.True(t, ok, "the check itself ran")
+
+	var doc checkJSON
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "CRITICAL", doc.State)
+	assert.Equal(t, checkCritical, doc.ExitCode)
+	assert.Equal(t, "5G", doc.Network)
+	require.Len(t, doc.Metrics, 4)
+	assert.Equal(t, checkedMetric{
+		Name: "SINR", Value: -3, Unit: "dB", Warning: doc.Metrics[3].Warning, State: "CRITICAL",
+	}, doc.Metrics[3])
+}
+
+func TestCmd_CheckFailsUnknown(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "tmhi.toml")
+	require.NoError(t, os.WriteFile(config, []byte("[profiles.home]\n"), 0o600))
+
+	oldArgs := os.Args
+
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	for _, args := range [][]string{
+		{"--config", config, "--profile", "office", cmdCheck},
+		{cmdCheck, "--check.sinr-warning", "high"},
+	} {
+		os.Args = append([]string{appName}, args...)
+
+		var err error
+
+		out := capturer.CaptureStdout(func() {
+			err = Cmd("test-version")
+		})
+
+		require.ErrorIs(t, err, ErrCheckUnknown, args)
+		assert.Equal(t, checkUnknown, ExitCode(err), args)
+		assert.Contains(t, out, "TMHI UNKNOWN - ", args)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -28,17 +29,45 @@ type spinner interface {
 // so tests can swap them without mutating package state.
 type app struct {
 	config      *Config
-	initGateway func(*Config) (tmhi.Gateway, error)
+	initGateway func(context.Context, *Config) (tmhi.Gateway, error)
 	newSpinner  func(message string) (spinner, error)
 	confirm     func(ctx context.Context, msg string, defaultVal bool) (bool, error)
+	input       func(ctx context.Context, msg, defaultVal string, secret bool) (string, error)
+	choose      func(ctx context.Context, msg string, opts []string, def string) (string, error)
+	newArea     func() (area, error)
+	// defaultGateway returns the gateway of the default route.
+	defaultGateway func(ctx context.Context) (string, error)
+	// newPrompt returns the prompt of a shell session, completing words.
+	newPrompt func(words []string, historyPath string) (prompt, error)
+	newScreen func() (screen, error)
+	stdout    io.Writer
+	secrets   *redactor
+	// origins maps flag names to the source their value was read from.
+	origins map[string]string
+	taps    *trafficTaps
+	// models remembers the detected gateway models across runs.
+	models modelCache
+	// checking is set once check is selected, so that Cmd reports any
+	// failure as its UNKNOWN state.
+	checking bool
 }
 
 func newApp() *app {
 	return &app{
-		config:      &Config{},
-		initGateway: initGateway,
-		newSpinner:  newPtermSpinner,
-		confirm:     ptermConfirm,
+		config:         &Config{},
+		initGateway:    initGateway,
+		newSpinner:     newPtermSpinner,
+		confirm:        ptermConfirm,
+		input:          ptermInput,
+		choose:         ptermSelect,
+		newArea:        newPtermArea,
+		defaultGateway: defaultGateway,
+		newPrompt:      newTermPrompt,
+		newScreen:      newTermScreen,
+		stdout:         os.Stdout,
+		secrets:        &redactor{},
+		origins:        map[string]string{},
+		taps:           &trafficTaps{},
 	}
 }
 
@@ -52,6 +81,19 @@ func newPtermSpinner(message string) (spinner, error) {
 	return &spinnerWrapper{spinnerPrinter: sp}, nil
 }
 
+// silentSpinner discards all feedback, keeping JSON output free of spinner
+// noise.
+type silentSpinner struct{}
+
+func (silentSpinner) Fail(_ ...any) {}
+
+func (silentSpinner) Success(_ ...any) {}
+
+//nolint:ireturn
+func newSilentSpinner(_ string) (spinner, error) {
+	return silentSpinner{}, nil
+}
+
 // ptermConfirm shows an interactive confirmation prompt, returning early with
 // ctx.Err() if ctx is cancelled (e.g. by SIGINT) before the user answers.
 // The prompt itself keeps blocking on stdin in the background since pterm
@@ -114,15 +156,28 @@ const (
 	NOK5G21        string        = "NOK5G21"
 	DefaultTimeout time.Duration = 5 * time.Second
 
-	appName     = "tmhi-cli"
-	defaultIP   = "192.168.12.1"
-	defaultUser = "admin"
-	autoValue   = "auto"
-	cmdLogin    = "login"
-	cmdReq      = "req"
-	cmdInfo     = "info"
-	cmdStatus   = "status"
-	cmdSignal   = "signal"
+	appName      = "tmhi-cli"
+	defaultIP    = "192.168.12.1"
+	defaultUser  = "admin"
+	autoValue    = "auto"
+	cmdLogin     = "login"
+	cmdReq       = "req"
+	cmdInfo      = "info"
+	cmdStatus    = "status"
+	cmdSignal    = "signal"
+	cmdExporter  = "exporter"
+	cmdWatchdog  = "watchdog"
+	cmdRecord    = "record"
+	cmdHistory   = "history"
+	cmdConfig    = "config"
+	cmdDetect    = "detect"
+	cmdDiscover  = "discover"
+	cmdSimulate  = "simulate"
+	cmdShell     = "shell"
+	cmdDashboard = "dashboard"
+	cmdAlign     = "align"
+	cmdMQTT      = "mqtt"
+	cmdCheck     = "check"
 )
 
 // ErrReqArgs is returned when req is not given exactly an HTTP method and a path.
@@ -192,28 +247,75 @@ func runWithFeedback(
 	return err
 }
 
+// gatewayOpener returns the initGateway of the CLI, which taps the traffic
+// of the gateway, recording it for --record or replaying it for --replay,
+// and uses the model detected on an earlier run when none is configured. The
+// configuration it is given keeps describing the gateway, the tap aside.
+func (a *app) gatewayOpener(userAgent string) func(context.Context, *Config) (tmhi.Gateway, error) {
+	return func(ctx context.Context, cfg *Config) (tmhi.Gateway, error) {
+		// Recorded traffic must hold the detection, and replayed traffic
+		// may not come from the cached gateway.
+		cached := cfg.Model == "" && cfg.Record == "" && cfg.Replay == ""
+		if cached {
+			cfg.Model = a.models.load(cfg.IP)
+			if cfg.Model != "" {
+				pterm.Debug.Printfln("Using the %s model detected earlier at %s", cfg.Model, cfg.IP)
+			}
+		}
+
+		if err := cfg.resolvePassword(ctx); err != nil {
+			return nil, err
+		}
+
+		tapped, tap, err := a.taps.tap(cfg, a.secrets)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := prepareConfig(ctx, tapped, a.secrets); err != nil {
+			return nil, err
+		}
+
+		if cfg.Model == "" {
+			cfg.Model = tapped.Model
+		}
+
+		if cached {
+			a.models.store(cfg.IP, cfg.Model)
+		}
+
+		return openGateway(tapped, userAgent, tap)
+	}
+}
+
 //nolint:ireturn
-func initGateway(cfg *Config) (tmhi.Gateway, error) {
-	if err := cfg.Validate(); err != nil {
+func initGateway(ctx context.Context, cfg *Config) (tmhi.Gateway, error) {
+	if err := prepareConfig(ctx, cfg, nil); err != nil {
 		return nil, err
 	}
 
-	return getGateway(cfg, "")
+	return openGateway(cfg, "", nil)
 }
 
 func (a *app) login(ctx context.Context, _ *cli.Command) error {
-	gateway, err := a.initGateway(a.config)
+	gateway, err := a.initGateway(ctx, a.config)
 	if err != nil {
 		return err
 	}
 
-	return runWithFeedback(
+	const successMessage = "Successfully logged in"
+
+	if err := runWithFeedback(
 		ctx,
 		a.newSpinner,
 		"Logging in...",
 		gateway.Login,
-		"Successfully logged in",
-	)
+		successMessage,
+	); err != nil {
+		return err
+	}
+
+	return a.report(jsonMessage{Message: successMessage})
 }
 
 func (a *app) req(ctx context.Context, cmd *cli.Command) error {
@@ -229,15 +331,34 @@ func (a *app) req(ctx context.Context, cmd *cli.Command) error {
 		return ErrReqMethod
 	}
 
-	gateway, err := a.initGateway(a.config)
+	path, err := withQuery(path, cmd.StringSlice(ConfigQuery))
+	if err != nil {
+		return err
+	}
+
+	if err := a.checkReqOutput(cmd); err != nil {
+		return err
+	}
+
+	opts, err := newReqOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
 	if err != nil {
 		return err
 	}
 
 	if a.config.DryRun {
-		pterm.Info.Printfln("Dry run - would send %s %s request", method, path)
+		msg := fmt.Sprintf("Dry run - would send %s %s request", method, path)
+		if opts.hasBody {
+			msg += fmt.Sprintf(" with a %d-byte body", len(opts.body))
+		}
 
-		return nil
+		pterm.Info.Println(msg)
+
+		return a.report(jsonMessage{Message: msg, DryRun: true})
 	}
 
 	if cmd.Bool("login") {
@@ -252,21 +373,37 @@ func (a *app) req(ctx context.Context, cmd *cli.Command) error {
 		}
 	}
 
-	_, err = fetchWithFeedback(
+	display := present(a, displayReqResponse, reqResponseToJSON)
+	if reqOutputRequested(cmd) {
+		display = nil
+	}
+
+	response, err := fetchWithFeedback(
 		ctx,
 		a.newSpinner,
 		fmt.Sprintf("%s %s...", method, path),
-		func(ctx context.Context) (*tmhi.InfoResult, error) {
-			return gateway.Request(ctx, method, path)
+		func(ctx context.Context) (*reqResponse, error) {
+			return fetchReq(ctx, gateway, method, path, opts)
 		},
-		displayInfoResult,
+		display,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if display != nil {
+		return nil
+	}
+
+	return a.writeReqOutput(cmd, response)
 }
 
-func (a *app) info(ctx context.Context, _ *cli.Command) error {
-	gateway, err := a.initGateway(a.config)
+func (a *app) info(ctx context.Context, cmd *cli.Command) error {
+	if fanOutRequested(cmd) {
+		return fanOut(ctx, a, cmd, fetchInfo, renderInfoResults, infoToJSON)
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
 	if err != nil {
 		return err
 	}
@@ -276,56 +413,107 @@ func (a *app) info(ctx context.Context, _ *cli.Command) error {
 		a.newSpinner,
 		"Fetching gateway info...",
 		gateway.Info,
-		displayInfoResult,
+		present(a, displayInfoResult, infoToJSON),
 	)
 
 	return err
 }
 
-func (a *app) status(ctx context.Context, _ *cli.Command) error {
-	gateway, err := a.initGateway(a.config)
+func (a *app) status(ctx context.Context, cmd *cli.Command) error {
+	if fanOutRequested(cmd) {
+		return fanOut(ctx, a, cmd, fetchStatus, renderStatusResults, statusToJSON)
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
 	if err != nil {
 		return err
 	}
 
+	if interval := watchInterval(cmd); interval > 0 {
+		notify, err := a.newNotifier(cmd)
+		if err != nil {
+			return err
+		}
+
+		return watchLoop(ctx, a, interval,
+			func(ctx context.Context) (*tmhi.StatusResult, error) {
+				result, err := gateway.Status(ctx)
+				if err == nil {
+					notify.status(ctx, result)
+				}
+
+				return result, err //nolint:wrapcheck
+			},
+			func(current, _ *tmhi.StatusResult) string { return renderStatusResult(current) },
+			statusToJSON)
+	}
+
 	_, err = fetchWithFeedback(
 		ctx,
 		a.newSpinner,
 		"Checking gateway status...",
 		gateway.Status,
-		displayStatusResult,
+		present(a, displayStatusResult, statusToJSON),
 	)
 
 	return err
 }
 
-func (a *app) signal(ctx context.Context, _ *cli.Command) error {
-	gateway, err := a.initGateway(a.config)
+func (a *app) signal(ctx context.Context, cmd *cli.Command) error {
+	if cmd != nil && cmd.IsSet(ConfigSink) && watchInterval(cmd) == 0 {
+		return ErrSinkWatch
+	}
+
+	if fanOutRequested(cmd) {
+		return fanOut(ctx, a, cmd, fetchSignal, renderSignalResults, signalToJSON)
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
 	if err != nil {
 		return err
 	}
 
+	if interval := watchInterval(cmd); interval > 0 {
+		notify, err := a.newNotifier(cmd)
+		if err != nil {
+			return err
+		}
+
+		sinks, err := a.newSinks(cmd)
+		if err != nil {
+			return err
+		}
+
+		return watchSignal(ctx, a, gateway, interval, notify, sinks)
+	}
+
 	_, err = fetchWithFeedback(
 		ctx,
 		a.newSpinner,
 		"Fetching signal information...",
 		gateway.Signal,
-		displaySignalResult,
+		present(a, displaySignalResult, signalToJSON),
 	)
 
 	return err
 }
 
 func (a *app) reboot(ctx context.Context, cmd *cli.Command) error {
-	gateway, err := a.initGateway(a.config)
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	notify, err := a.newNotifier(cmd)
 	if err != nil {
 		return err
 	}
 
 	if a.config.DryRun {
-		pterm.Info.Println("Dry run - would send reboot request")
+		const msg = "Dry run - would send reboot request"
+		pterm.Info.Println(msg)
 
-		return nil
+		return a.report(jsonMessage{Message: msg, DryRun: true})
 	}
 
 	if !cmd.Bool(ConfigAutoConfirm) {
@@ -341,17 +529,25 @@ func (a *app) reboot(ctx context.Context, cmd *cli.Command) error {
 		if !confirmed {
 			pterm.Warning.Println("Reboot cancelled")
 
-			return nil
+			return displayed(fmt.Errorf("reboot %w", ErrCancelled))
 		}
 	}
 
-	return runWithFeedback(
+	const successMessage = "Reboot command sent successfully"
+
+	if err := runWithFeedback(
 		ctx,
 		a.newSpinner,
 		"Rebooting gateway...",
 		gateway.Reboot,
-		"Reboot command sent successfully",
-	)
+		successMessage,
+	); err != nil {
+		return err
+	}
+
+	notify.reboot(ctx)
+
+	return a.report(jsonMessage{Message: successMessage})
 }
 
 func defaultConfigPath() string {
@@ -408,26 +604,48 @@ func setupColor(ctx context.Context, cmd *cli.Command) (context.Context, error)
 	return ctx, nil
 }
 
+// signalContext is signal.NotifyContext for SIGINT and SIGTERM, but
+// cancels ctx with ErrInterrupted or ErrTerminated as its cause, for the
+// exit code.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case received := <-signals:
+			if received == syscall.SIGTERM {
+				cancel(ErrTerminated)
+			} else {
+				cancel(ErrInterrupted)
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(context.Canceled)
+	}
+}
+
 // Cmd runs the CLI application with the given version string.
 func Cmd(version string) error {
 	var configFile string
 
 	configSource := altsrc.NewStringPtrSourcer(&configFile)
 	cliApp := newApp()
-	cliApp.initGateway = func(cfg *Config) (tmhi.Gateway, error) {
-		if err := cfg.Validate(); err != nil {
-			return nil, err
-		}
-
-		return getGateway(cfg, appName+"/"+version)
-	}
+	cliApp.models = modelCache{path: defaultModelCachePath()}
+	cliApp.initGateway = cliApp.gatewayOpener(appName + "/" + version)
 
 	root := &cli.Command{
 		Name:     appName,
 		Usage:    "Utility to interact with T-Mobile Home Internet gateway",
 		Version:  version,
 		Flags:    cliApp.flags(&configFile, configSource),
-		Commands: cliApp.commands(),
+		Commands: cliApp.commands(configSource),
 		Before:   setupColor,
 		OnUsageError: func(_ context.Context, cmd *cli.Command, err error, _ bool) error {
 			_, _ = fmt.Fprintf(cmd.ErrWriter, "error: %v\n", err)
@@ -436,15 +654,38 @@ func Cmd(version string) error {
 		},
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, stop := signalContext(context.Background())
 	defer stop()
+	defer cliApp.taps.close()
 
 	err := root.Run(ctx, os.Args)
 	if err != nil {
-		if _, ok := errors.AsType[*displayedError](err); !ok {
-			pterm.Error.Println(err)
+		if cause := context.Cause(ctx); cause != nil && errors.Is(err, context.Canceled) {
+			err = fmt.Errorf("%w: %w", cause, err)
+		}
+
+		if cliApp.checking {
+			err = cliApp.checkFailed(err)
 		}
+
+		if _, ok := errors.AsType[*documentedError](err); ok {
+			return err //nolint:wrapcheck
+		}
+
+		cliApp.reportError(err)
 	}
 
 	return err //nolint:wrapcheck
 }
+
+// reportError shows err as a JSON error document or an error message, unless
+// it was already displayed.
+func (a *app) reportError(err error) {
+	if a.jsonOutput() {
+		if jsonErr := a.writeJSONError(err); jsonErr != nil {
+			pterm.Error.Println(jsonErr)
+		}
+	} else if _, ok := errors.AsType[*displayedError](err); !ok {
+		pterm.Error.Println(a.secrets.redact(err.Error()))
+	}
+}
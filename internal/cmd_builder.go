@@ -2,7 +2,12 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/pterm/pterm"
 	altsrc "github.com/urfave/cli-altsrc/v3"
@@ -13,23 +18,74 @@ import (
 
 // Configuration flag names.
 const (
-	ConfigAutoConfirm string = "yes"
-	ConfigColor       string = "color"
-	ConfigConfig      string = "config"
-	ConfigDebug       string = "debug"
-	ConfigDryRun      string = "dry-run"
-	ConfigGateway     string = "gateway."
-	ConfigIP          string = ConfigGateway + "ip"
-	ConfigLogin       string = "login."
-	ConfigModel       string = ConfigGateway + "model"
-	ConfigPassword    string = ConfigLogin + "password"
-	ConfigQuiet       string = "quiet"
-	ConfigRetries     string = "retries"
-	ConfigTimeout     string = "timeout"
-	ConfigUsername    string = ConfigLogin + "username"
+	ConfigAllProfiles     string = "all-profiles"
+	ConfigAutoConfirm     string = "yes"
+	ConfigBell            string = "bell"
+	ConfigBroker          string = "broker"
+	ConfigCache           string = "cache"
+	ConfigCIDR            string = "cidr"
+	ConfigColor           string = "color"
+	ConfigConfig          string = "config"
+	ConfigData            string = "data"
+	ConfigDataFile        string = "data-file"
+	ConfigDB              string = "db"
+	ConfigDebug           string = "debug"
+	ConfigDiscoveryPrefix string = "discovery-prefix"
+	ConfigDowntime        string = "downtime"
+	ConfigDryRun          string = "dry-run"
+	ConfigFilter          string = "filter"
+	ConfigGateway         string = "gateway."
+	ConfigHeader          string = "header"
+	ConfigHistoryFile     string = "history-file"
+	ConfigInclude         string = "include"
+	ConfigInfluxToken     string = "influx-token"
+	ConfigInterval        string = "interval"
+	ConfigIP              string = ConfigGateway + "ip"
+	ConfigInventory       string = "inventory"
+	ConfigListen          string = "listen"
+	ConfigLogin           string = "login."
+	ConfigMetric          string = "metric"
+	ConfigModel           string = ConfigGateway + "model"
+	ConfigOutput          string = "output"
+	ConfigOutputFile      string = "output-file"
+	ConfigPassword        string = ConfigLogin + "password"
+	ConfigPasswordCommand string = ConfigLogin + "password-command"
+	ConfigPasswordFile    string = ConfigLogin + "password-file"
+	ConfigPretty          string = "pretty"
+	ConfigProfile         string = "profile"
+	ConfigQuery           string = "query"
+	ConfigQuiet           string = "quiet"
+	ConfigRaw             string = "raw"
+	ConfigRecord          string = "record"
+	ConfigReplay          string = "replay"
+	ConfigRetries         string = "retries"
+	ConfigSamples         string = "samples"
+	ConfigSave            string = "save"
+	ConfigSimulatedModel  string = "model"
+	ConfigSince           string = "since"
+	ConfigSink            string = "sink"
+	ConfigSources         string = "sources"
+	ConfigTimeout         string = "timeout"
+	ConfigTopic           string = "topic"
+	ConfigUntil           string = "until"
+	ConfigUsername        string = ConfigLogin + "username"
+	ConfigWatch           string = "watch"
+	ConfigWindow          string = "window"
+
+	ConfigCheck string = "check."
+
+	ConfigWatchdog           string = "watchdog."
+	ConfigWatchdogCooldown   string = ConfigWatchdog + "cooldown"
+	ConfigWatchdogFailures   string = ConfigWatchdog + "failures"
+	ConfigWatchdogInterval   string = ConfigWatchdog + "interval"
+	ConfigWatchdogMaxReboots string = ConfigWatchdog + "max-reboots"
+	ConfigWatchdogProbe      string = ConfigWatchdog + "probe"
 )
 
-func (a *app) commands() []*cli.Command {
+// ErrNotPositive is returned when a duration or count flag must be above zero.
+var ErrNotPositive = errors.New("must be greater than zero")
+
+func (a *app) commands(configSource altsrc.Sourcer) []*cli.Command { //nolint:funlen
 	return []*cli.Command{
 		{
 			Name:   cmdLogin,
@@ -52,54 +108,471 @@ func (a *app) commands() []*cli.Command {
 		{
 			Name:   cmdInfo,
 			Usage:  "Get gateway information",
+			Flags:  fanOutFlags(),
 			Action: a.info,
 		},
 		{
 			Name:   cmdStatus,
 			Usage:  "Check gateway status",
+			Flags:  append(fanOutFlags(), watchFlag()),
 			Action: a.status,
 		},
 		{
 			Name:   cmdSignal,
 			Usage:  "Display signal strength information",
+			Flags:  append(append(fanOutFlags(), watchFlag()), sinkFlags()...),
 			Action: a.signal,
 		},
 		{
 			Name:      cmdReq,
 			Usage:     "Make a custom HTTP request to the gateway",
 			ArgsUsage: "<HTTP method> <path>",
+			Flags:     reqFlags(),
+			Action:    a.req,
+		},
+		{
+			Name:  cmdExporter,
+			Usage: "Serve signal and status metrics for Prometheus",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  ConfigListen,
+					Value: defaultExporterListen,
+					Usage: "address to serve " + metricsPath + " on",
+				},
+				&cli.DurationFlag{
+					Name:  ConfigCache,
+					Value: 0,
+					Usage: "reuse a scrape for this long instead of querying on every request",
+				},
+			},
+			Action: a.exporter,
+		},
+		{
+			Name:   cmdMQTT,
+			Usage:  "Publish signal and status to MQTT, with Home Assistant discovery",
+			Flags:  mqttFlags(),
+			Action: a.mqtt,
+		},
+		{
+			Name:         cmdCheck,
+			Usage:        "Check the gateway as a Nagios or Icinga plugin",
+			Flags:        checkFlags(configSource),
+			Before:       a.beginCheck,
+			OnUsageError: a.checkUsageError,
+			Action:       a.check,
+		},
+		{
+			Name:   cmdWatchdog,
+			Usage:  "Reboot the gateway when connectivity checks keep failing",
+			Flags:  watchdogFlags(configSource),
+			Action: a.watchdog,
+		},
+		{
+			Name:   cmdRecord,
+			Usage:  "Append signal samples to a history file at an interval",
+			Flags:  recordFlags(),
+			Action: a.record,
+		},
+		{
+			Name:   cmdHistory,
+			Usage:  "Summarize recorded signal samples per network and band",
+			Flags:  historyFlags(),
+			Action: a.history,
+		},
+		{
+			Name:   cmdDetect,
+			Usage:  "Detect the gateway model from unauthenticated requests",
+			Action: a.detect,
+		},
+		{
+			Name:  cmdDiscover,
+			Usage: "Find gateways on the local network and detect their model",
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  ConfigCIDR,
+					Usage: "also scan this IPv4 range (e.g. 192.168.1.0/24, at most a /20)",
+				},
 				&cli.BoolFlag{
-					Name:    cmdLogin,
-					Aliases: []string{"l"},
-					Value:   false,
-					Usage:   "login before making request",
+					Name:  ConfigSave,
+					Usage: "write the found gateway's address and model to the configuration file",
+				},
+			},
+			Action: a.discover,
+		},
+		{
+			Name:  cmdDashboard,
+			Usage: "Show live signal, status and clients full screen",
+			Flags: []cli.Flag{
+				&cli.DurationFlag{
+					Name:      ConfigInterval,
+					Value:     defaultDashboardInterval,
+					Usage:     "time between refreshes",
+					Validator: positive[time.Duration],
+				},
+			},
+			Action: a.dashboard,
+		},
+		{
+			Name:   cmdAlign,
+			Usage:  "Show one signal metric large while aiming the gateway",
+			Flags:  alignFlags(),
+			Action: a.align,
+		},
+		{
+			Name:  cmdShell,
+			Usage: "Log in once and run commands at an interactive prompt",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:      ConfigHistoryFile,
+					Value:     defaultShellHistoryPath(),
+					Usage:     "file keeping the prompt history, empty to keep none",
+					TakesFile: true,
 				},
 			},
-			Action: a.req,
+			Action: a.shell,
+		},
+		{
+			Name:   cmdSimulate,
+			Usage:  "Serve a fake gateway web API for offline testing",
+			Flags:  simulateFlags(),
+			Action: a.simulate,
+		},
+		{
+			Name:  cmdConfig,
+			Usage: "Create or inspect the configuration file",
+			Commands: []*cli.Command{
+				{
+					Name:   "init",
+					Usage:  "Write the configuration file from prompted and verified settings",
+					Action: a.configInit,
+				},
+				{
+					Name:  "show",
+					Usage: "Show the effective value of every global option",
+					Flags: []cli.Flag{
+						&cli.BoolFlag{
+							Name:  ConfigSources,
+							Usage: "also show where each value comes from",
+						},
+					},
+					Action: a.configShow,
+				},
+			},
+		},
+	}
+}
+
+func reqFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    cmdLogin,
+			Aliases: []string{"l"},
+			Value:   false,
+			Usage:   "login before making request",
+		},
+		&cli.StringFlag{
+			Name:  ConfigData,
+			Usage: "request body",
+		},
+		&cli.StringFlag{
+			Name:      ConfigDataFile,
+			Usage:     "read the request body from this file, - for standard input",
+			TakesFile: true,
+		},
+		&cli.StringSliceFlag{
+			Name:    ConfigHeader,
+			Aliases: []string{"H"},
+			Usage:   "add a request header, as Name: value",
+		},
+		&cli.StringSliceFlag{
+			Name:  ConfigQuery,
+			Usage: "add a query parameter, as name=value",
+		},
+		&cli.BoolFlag{
+			Name:  ConfigRaw,
+			Usage: "print the response body exactly as received",
+		},
+		&cli.BoolFlag{
+			Name:  ConfigPretty,
+			Usage: "indent the JSON response body",
+		},
+		&cli.StringFlag{
+			Name:      ConfigOutputFile,
+			Aliases:   []string{"o"},
+			Usage:     "write the response to this file",
+			TakesFile: true,
+		},
+		&cli.BoolFlag{
+			Name:    ConfigInclude,
+			Aliases: []string{"i"},
+			Usage:   "print the response status line and headers",
+		},
+		&cli.StringFlag{
+			Name:  ConfigFilter,
+			Usage: "print the values at a jq-like path of the JSON body, such as .signal.5g.sinr",
+		},
+	}
+}
+
+func simulateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      ConfigSimulatedModel,
+			Value:     ARCADYAN,
+			Usage:     fmt.Sprintf("gateway model to simulate: %s, %s", ARCADYAN, NOK5G21),
+			Validator: clival.Enum(ARCADYAN, NOK5G21),
+		},
+		&cli.StringFlag{
+			Name:  ConfigListen,
+			Value: defaultSimulateListen,
+			Usage: "address to serve the simulated gateway on",
+		},
+		&cli.DurationFlag{
+			Name:  ConfigDowntime,
+			Value: defaultSimulateDowntime,
+			Usage: "how long the gateway stays unreachable after a reboot",
+		},
+		&cli.StringFlag{
+			Name:      ConfigSamples,
+			Usage:     "JSON array of signal samples to serve in turn (default: random)",
+			TakesFile: true,
+		},
+	}
+}
+
+func recordFlags() []cli.Flag {
+	return append([]cli.Flag{
+		historyDBFlag(),
+		&cli.DurationFlag{
+			Name:      ConfigInterval,
+			Value:     defaultRecordInterval,
+			Usage:     "time between samples",
+			Validator: positive[time.Duration],
+		},
+	}, sinkFlags()...)
+}
+
+func sinkFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name: ConfigSink,
+			Usage: "also write samples to influx:- (stdout), influx:<file>, " +
+				"influx:<http(s) write URL> or statsd:<host>:<port>",
+		},
+		&cli.StringFlag{
+			Name:    ConfigInfluxToken,
+			Sources: cli.EnvVars(envVarName(ConfigInfluxToken)),
+			Usage:   "token authenticating writes to an InfluxDB URL",
 		},
 	}
 }
 
+func mqttFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  ConfigBroker,
+			Value: defaultMQTTBroker,
+			Usage: "MQTT broker URL, tcp://, ssl:// or ws://, with user:password@ if needed",
+		},
+		&cli.StringFlag{
+			Name:  ConfigTopic,
+			Value: defaultMQTTTopic,
+			Usage: "base topic of the published values and of the command topic",
+		},
+		&cli.StringFlag{
+			Name:  ConfigDiscoveryPrefix,
+			Value: defaultMQTTDiscoveryPrefix,
+			Usage: "Home Assistant discovery topic prefix",
+		},
+		&cli.DurationFlag{
+			Name:      ConfigInterval,
+			Value:     defaultMQTTInterval,
+			Usage:     "time between publications",
+			Validator: positive[time.Duration],
+		},
+	}
+}
+
+func alignFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      ConfigMetric,
+			Value:     alignSINR,
+			Usage:     "metric to show: " + alignSINR + " or " + alignRSRP,
+			Validator: clival.Enum(alignSINR, alignRSRP),
+		},
+		&cli.DurationFlag{
+			Name:      ConfigInterval,
+			Value:     defaultAlignInterval,
+			Usage:     "time between samples",
+			Validator: positive[time.Duration],
+		},
+		&cli.DurationFlag{
+			Name:      ConfigWindow,
+			Value:     defaultAlignWindow,
+			Usage:     "how long the best value is kept",
+			Validator: positive[time.Duration],
+		},
+		&cli.BoolFlag{
+			Name:  ConfigBell,
+			Usage: "ring the terminal bell, faster as the signal improves",
+		},
+	}
+}
+
+// checkFlags sets the warning and critical threshold of each metric, below
+// which the check alerts.
+func checkFlags(configSource altsrc.Sourcer) []cli.Flag {
+	var flags []cli.Flag
+
+	for _, metric := range []string{"rsrp", "rsrq", "rssi", "sinr"} {
+		for _, level := range []string{checkLevelWarning, checkLevelCritical} {
+			name := checkFlagName(metric, level)
+			quality := "Good"
+			if level == checkLevelCritical {
+				quality = "Fair"
+			}
+
+			flags = append(flags, &cli.FloatFlag{
+				Name:        name,
+				Sources:     cli.NewValueSourceChain(toml.TOML(name, configSource)),
+				Usage:       level + " when " + strings.ToUpper(metric) + " is below this",
+				DefaultText: "where " + quality + " starts",
+			})
+		}
+	}
+
+	return flags
+}
+
+func historyFlags() []cli.Flag {
+	return []cli.Flag{
+		historyDBFlag(),
+		&cli.StringFlag{
+			Name:  ConfigSince,
+			Usage: "only samples since: a duration ago (e.g. 24h), a date or RFC 3339",
+		},
+		&cli.StringFlag{
+			Name:  ConfigUntil,
+			Usage: "only samples before: a duration ago, a date or RFC 3339",
+		},
+	}
+}
+
+func historyDBFlag() *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:      ConfigDB,
+		Value:     defaultHistoryPath(),
+		Usage:     "CSV file holding the signal history",
+		TakesFile: true,
+	}
+}
+
+func watchdogFlags(configSource altsrc.Sourcer) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    ConfigWatchdogProbe,
+			Sources: cli.NewValueSourceChain(toml.TOML(ConfigWatchdogProbe, configSource)),
+			Usage: "connectivity probe: icmp:<host>, tcp:<host>:<port>, dns:<name> " +
+				"or an http(s):// URL (default: " + strings.Join(defaultWatchdogProbes, ", ") + ")",
+		},
+		&cli.DurationFlag{
+			Name:      ConfigWatchdogInterval,
+			Sources:   cli.NewValueSourceChain(toml.TOML(ConfigWatchdogInterval, configSource)),
+			Value:     defaultWatchdogInterval,
+			Usage:     "time between connectivity checks",
+			Validator: positive[time.Duration],
+		},
+		&cli.IntFlag{
+			Name:      ConfigWatchdogFailures,
+			Sources:   cli.NewValueSourceChain(toml.TOML(ConfigWatchdogFailures, configSource)),
+			Value:     defaultWatchdogFailures,
+			Usage:     "consecutive failed checks before rebooting",
+			Validator: positive[int],
+		},
+		&cli.DurationFlag{
+			Name:    ConfigWatchdogCooldown,
+			Sources: cli.NewValueSourceChain(toml.TOML(ConfigWatchdogCooldown, configSource)),
+			Value:   defaultWatchdogCooldown,
+			Usage:   "minimum time between two reboots",
+		},
+		&cli.IntFlag{
+			Name:    ConfigWatchdogMaxReboots,
+			Sources: cli.NewValueSourceChain(toml.TOML(ConfigWatchdogMaxReboots, configSource)),
+			Value:   defaultWatchdogMaxReboots,
+			Usage:   "maximum number of reboots in 24 hours",
+		},
+	}
+}
+
+func positive[T int | time.Duration](v T) error {
+	if v <= 0 {
+		return ErrNotPositive
+	}
+
+	return nil
+}
+
+// fanOutFlags select several gateways to query at once instead of the one
+// set by the global flags.
+func fanOutFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  ConfigAllProfiles,
+			Usage: "query every profile of the configuration file concurrently",
+		},
+		&cli.StringFlag{
+			Name:      ConfigInventory,
+			Usage:     "query every profile of this TOML file concurrently",
+			TakesFile: true,
+		},
+	}
+}
+
+func watchFlag() *cli.DurationFlag {
+	return &cli.DurationFlag{
+		Name:    ConfigWatch,
+		Aliases: []string{"w"},
+		Usage:   "poll again at this interval (e.g. 2s) and redraw in place until interrupted",
+	}
+}
+
 func (a *app) flags(configFile *string, configSource altsrc.Sourcer) []cli.Flag { //nolint:funlen
 	return []cli.Flag{
 		&cli.StringFlag{
 			Name:        ConfigConfig,
 			Aliases:     []string{"c"},
+			Sources:     a.envSources(ConfigConfig),
 			Usage:       "use the specified TOML configuration file",
 			Value:       defaultConfigPath(),
 			Destination: configFile,
 			TakesFile:   true,
 		},
+		// Must come before the gateway flags, whose values depend on the
+		// selected profile.
+		&cli.StringFlag{
+			Name:    ConfigProfile,
+			Aliases: []string{"p"},
+			Sources: a.tracked(ConfigProfile, cli.NewValueSourceChain(
+				cli.EnvVar(envVarName(ConfigProfile)),
+				toml.TOML(defaultProfileKey, configSource),
+			)),
+			Usage:       "use the named [profiles.<name>] section of the configuration file",
+			Destination: &a.config.Profile,
+			Action:      checkProfile(configSource),
+		},
 		&cli.BoolFlag{
 			Name:        ConfigDebug,
 			Aliases:     []string{"d"},
+			Sources:     a.envSources(ConfigDebug),
 			Value:       false,
 			Usage:       "display debugging output in the console",
 			Destination: &a.config.Debug,
 			Action: func(_ context.Context, _ *cli.Command, v bool) error {
 				if v {
 					pterm.EnableDebugMessages()
+					pterm.SetDefaultOutput(a.secrets.writer(os.Stdout))
+					log.SetOutput(a.secrets.writer(os.Stderr))
 				}
 
 				return nil
@@ -107,13 +580,33 @@ func (a *app) flags(configFile *string, configSource altsrc.Sourcer) []cli.Flag
 		},
 		&cli.StringFlag{
 			Name:      ConfigColor,
+			Sources:   a.envSources(ConfigColor),
 			Value:     autoValue,
 			Usage:     "colorize output: always, never, auto",
 			Validator: clival.Enum("always", "never", autoValue),
 		},
+		&cli.StringFlag{
+			Name:        ConfigOutput,
+			Sources:     a.envSources(ConfigOutput),
+			Value:       outputText,
+			Usage:       "output format: text, json",
+			Validator:   clival.Enum(outputText, outputJSON),
+			Destination: &a.config.Output,
+			Action: func(_ context.Context, _ *cli.Command, v string) error {
+				if v == outputJSON {
+					// Keep stdout for the JSON document; pterm messages
+					// still reach the user on stderr.
+					pterm.SetDefaultOutput(a.secrets.writer(os.Stderr))
+					a.newSpinner = newSilentSpinner
+				}
+
+				return nil
+			},
+		},
 		&cli.BoolFlag{
 			Name:    ConfigQuiet,
 			Aliases: []string{"q"},
+			Sources: a.envSources(ConfigQuiet),
 			Value:   false,
 			Usage:   "quiet mode, suppresses output",
 			Action: func(_ context.Context, _ *cli.Command, v bool) error {
@@ -127,50 +620,82 @@ func (a *app) flags(configFile *string, configSource altsrc.Sourcer) []cli.Flag
 		&cli.BoolFlag{
 			Name:        ConfigDryRun,
 			Aliases:     []string{"D"},
+			Sources:     a.envSources(ConfigDryRun),
 			Value:       false,
 			Usage:       "do not perform any change to the gateway",
 			Destination: &a.config.DryRun,
 		},
 		&cli.StringFlag{
-			Name:        ConfigModel,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigModel, configSource)),
-			Usage:       fmt.Sprintf("gateway model: options: %s, %s", ARCADYAN, NOK5G21),
+			Name:    ConfigModel,
+			Sources: a.configSources(ConfigModel, ConfigModel, "model", configSource),
+			Usage: fmt.Sprintf("gateway model: options: %s, %s (detected when unset)",
+				ARCADYAN, NOK5G21),
 			Destination: &a.config.Model,
 		},
 		&cli.StringFlag{
 			Name:        ConfigIP,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigIP, configSource)),
+			Sources:     a.configSources(ConfigIP, ConfigIP, "ip", configSource),
 			Value:       defaultIP,
-			Usage:       "gateway IP",
+			Usage:       "gateway IP, optionally with a port",
 			Destination: &a.config.IP,
 		},
 		&cli.StringFlag{
 			Name:        ConfigUsername,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigUsername, configSource)),
+			Sources:     a.configSources(ConfigUsername, ConfigUsername, "username", configSource),
 			Value:       defaultUser,
 			Usage:       "admin username",
 			Destination: &a.config.Username,
 		},
 		&cli.StringFlag{
-			Name:        ConfigPassword,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigPassword, configSource)),
+			Name: ConfigPassword,
+			Sources: a.configSources(
+				ConfigPassword, ConfigPassword, "password", configSource, envPassword),
 			Required:    false,
 			Usage:       "admin password",
 			Destination: &a.config.Password,
 		},
+		&cli.StringFlag{
+			Name: ConfigPasswordFile,
+			Sources: a.configSources(
+				ConfigPasswordFile, passwordFileKey, "password_file", configSource),
+			Usage:       "read the admin password from the first line of this file",
+			Destination: &a.config.PasswordFile,
+			TakesFile:   true,
+		},
+		&cli.StringFlag{
+			Name: ConfigPasswordCommand,
+			Sources: a.configSources(
+				ConfigPasswordCommand, passwordCommandKey, "password_command", configSource),
+			Usage:       "shell command printing the admin password on its first line",
+			Destination: &a.config.PasswordCommand,
+		},
 		&cli.IntFlag{
 			Name:        ConfigRetries,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigRetries, configSource)),
+			Sources:     a.configSources(ConfigRetries, ConfigRetries, "retries", configSource),
 			Value:       0,
 			Usage:       "number of retries",
 			Destination: &a.config.Retries,
 		},
 		&cli.DurationFlag{
 			Name:        ConfigTimeout,
-			Sources:     cli.NewValueSourceChain(toml.TOML(ConfigTimeout, configSource)),
+			Sources:     a.configSources(ConfigTimeout, ConfigTimeout, "timeout", configSource),
 			Value:       DefaultTimeout,
 			Usage:       "request timeout (e.g. 5s, 1m)",
 			Destination: &a.config.Timeout,
 		},
+		&cli.StringFlag{
+			Name:        ConfigRecord,
+			Sources:     a.envSources(ConfigRecord),
+			Usage:       "record HTTP exchanges with the gateway in this directory, secrets masked",
+			Destination: &a.config.Record,
+			TakesFile:   true,
+		},
+		&cli.StringFlag{
+			Name:        ConfigReplay,
+			Sources:     a.envSources(ConfigReplay),
+			Usage:       "answer from the exchanges recorded in this directory, not the gateway",
+			Destination: &a.config.Replay,
+			TakesFile:   true,
+		},
 	}
 }
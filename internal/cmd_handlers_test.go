@@ -28,9 +28,11 @@ type mockGateway struct {
 	rebootCalled  bool
 	rebootErr     error
 	requestCalled bool
+	requestPath   string
 	requestErr    error
 	signalCalled  bool
 	signalErr     error
+	signalResult  *tmhi.SignalResult
 }
 
 func (m *mockGateway) Login(context.Context) error {
@@ -45,8 +47,9 @@ func (m *mockGateway) Reboot(context.Context) error {
 	return m.rebootErr
 }
 
-func (m *mockGateway) Request(context.Context, string, string) (*tmhi.InfoResult, error) {
+func (m *mockGateway) Request(_ context.Context, _, path string) (*tmhi.InfoResult, error) {
 	m.requestCalled = true
+	m.requestPath = path
 	if m.requestErr != nil {
 		return nil, m.requestErr
 	}
@@ -78,6 +81,10 @@ func (m *mockGateway) Signal(context.Context) (*tmhi.SignalResult, error) {
 		return nil, m.signalErr
 	}
 
+	if m.signalResult != nil {
+		return m.signalResult, nil
+	}
+
 	return &tmhi.SignalResult{}, nil
 }
 
@@ -102,9 +109,7 @@ func newReqCmd(a *app) *cli.Command {
 	return &cli.Command{
 		Name:   cmdReq,
 		Action: a.req,
-		Flags: []cli.Flag{
-			&cli.BoolFlag{Name: cmdLogin, Value: false},
-		},
+		Flags:  reqFlags(),
 	}
 }
 
@@ -274,7 +279,12 @@ func testRebootConfirm(t *testing.T, confirmResult bool, expectCalled bool, msg
 	cmd := newRebootCmd(false, false)
 
 	err := a.reboot(t.Context(), cmd)
-	require.NoError(t, err)
+	if expectCalled {
+		require.NoError(t, err)
+	} else {
+		require.ErrorIs(t, err, ErrCancelled)
+	}
+
 	assert.Equal(t, expectCalled, mg.rebootCalled, msg)
 }
 
@@ -423,7 +433,7 @@ func TestInitGateway(t *testing.T) {
 			Timeout:  5 * time.Second,
 		}
 
-		g, err := initGateway(cfg)
+		g, err := initGateway(t.Context(), cfg)
 		require.NoError(t, err)
 		assert.NotNil(t, g)
 	})
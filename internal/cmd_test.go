@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	tmhi "github.com/hugoh/tmhi-gateway/v2"
 	"github.com/pterm/pterm"
@@ -182,13 +185,13 @@ func TestBuildFlags(t *testing.T) {
 
 	flags := newApp().flags(&configFile, nil)
 
-	require.Len(t, flags, 11)
+	require.Len(t, flags, 17)
 }
 
 func TestBuildCommands(t *testing.T) {
-	commands := newApp().commands()
+	commands := newApp().commands(nil)
 
-	require.Len(t, commands, 6)
+	require.Len(t, commands, 19)
 	require.Equal(t, "login", commands[0].Name)
 	require.Equal(t, "reboot", commands[1].Name)
 	require.Equal(t, cmdInfo, commands[2].Name)
@@ -361,6 +364,10 @@ func TestOnUsageError(t *testing.T) {
 func TestDebugFlagAction(t *testing.T) {
 	pterm.DisableDebugMessages()
 	t.Cleanup(pterm.DisableDebugMessages)
+	t.Cleanup(func() {
+		pterm.SetDefaultOutput(os.Stdout)
+		log.SetOutput(os.Stderr)
+	})
 
 	var configFile string
 
@@ -420,13 +427,13 @@ func TestInitGateway_Success(t *testing.T) {
 		Retries:  1,
 	}
 
-	gateway, err := initGateway(cfg)
+	gateway, err := initGateway(t.Context(), cfg)
 	require.NoError(t, err)
 	assert.NotNil(t, gateway)
 }
 
 func TestInitGateway_ValidationError(t *testing.T) {
-	gateway, err := initGateway(&Config{})
+	gateway, err := initGateway(t.Context(), &Config{})
 	require.Error(t, err)
 	assert.Nil(t, gateway)
 }
@@ -455,7 +462,7 @@ func TestGatewayInitErrors(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			a := newTestApp(nil)
-			a.initGateway = func(_ *Config) (tmhi.Gateway, error) {
+			a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) {
 				return nil, errors.New("gateway init failed")
 			}
 
@@ -465,3 +472,26 @@ func TestGatewayInitErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestSignalContext(t *testing.T) {
+	for received, want := range map[syscall.Signal]error{
+		syscall.SIGINT:  ErrInterrupted,
+		syscall.SIGTERM: ErrTerminated,
+	} {
+		ctx, stop := signalContext(t.Context())
+		require.NoError(t, syscall.Kill(os.Getpid(), received))
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v did not cancel", received)
+		}
+
+		require.ErrorIs(t, context.Cause(ctx), want)
+		stop()
+	}
+
+	ctx, stop := signalContext(t.Context())
+	stop()
+	require.ErrorIs(t, context.Cause(ctx), context.Canceled)
+}
@@ -1,8 +1,13 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,35 +19,54 @@ import (
 // ErrInvalidConfig is returned when configuration validation fails.
 var ErrInvalidConfig = errors.New("invalid configuration")
 
+var errInvalidPort = errors.New("must be a valid port number")
+
 // Config holds all configuration values for the CLI application.
 type Config struct {
+	Profile  string
 	Model    string
 	IP       string
 	Username string
 	Password string
-	Timeout  time.Duration
-	Retries  int
-	Debug    bool
-	DryRun   bool
+	// PasswordFile and PasswordCommand are where Password is read from when
+	// it is not set directly.
+	PasswordFile    string
+	PasswordCommand string
+	Timeout         time.Duration
+	Retries         int
+	Output          string
+	Debug           bool
+	DryRun          bool
+	// Record and Replay are the directories gateway traffic is recorded to
+	// or replayed from.
+	Record string
+	Replay string
 }
 
 //nolint:gochecknoglobals
 var fieldToFlag = map[string]string{
-	"Model":    ConfigModel,
-	"IP":       ConfigIP,
-	"Username": ConfigUsername,
-	"Password": ConfigPassword,
-	"Timeout":  ConfigTimeout,
-	"Retries":  ConfigRetries,
-	"Debug":    ConfigDebug,
-	"DryRun":   ConfigDryRun,
+	"Profile":         ConfigProfile,
+	"Model":           ConfigModel,
+	"IP":              ConfigIP,
+	"Username":        ConfigUsername,
+	"Password":        ConfigPassword,
+	"PasswordFile":    ConfigPasswordFile,
+	"PasswordCommand": ConfigPasswordCommand,
+	"Timeout":         ConfigTimeout,
+	"Retries":         ConfigRetries,
+	"Output":          ConfigOutput,
+	"Debug":           ConfigDebug,
+	"DryRun":          ConfigDryRun,
+	"Record":          ConfigRecord,
+	"Replay":          ConfigReplay,
 }
 
 // Validate validates the Config struct and returns formatted errors.
 func (c *Config) Validate() error {
 	err := validation.ValidateStruct(c,
-		validation.Field(&c.Model, validation.Required, validation.In(ARCADYAN, NOK5G21)),
-		validation.Field(&c.IP, validation.Required, is.Host),
+		// An empty model is detected from the gateway.
+		validation.Field(&c.Model, validation.In(ARCADYAN, NOK5G21)),
+		validation.Field(&c.IP, validation.Required, hostWithPort),
 		validation.Field(&c.Username, validation.Required),
 		validation.Field(&c.Password, validation.Required),
 		validation.Field(&c.Timeout, validation.Required, validation.Min(1*time.Second)),
@@ -64,6 +88,58 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// resolvePassword reads Password from PasswordFile or, failing that, from
+// the first line printed by PasswordCommand, unless it is already set.
+func (c *Config) resolvePassword(ctx context.Context) error {
+	var (
+		out []byte
+		err error
+	)
+
+	switch {
+	case c.Password != "":
+		return nil
+	case c.PasswordFile != "":
+		if out, err = os.ReadFile(c.PasswordFile); err != nil {
+			return fmt.Errorf("failed to read password file: %w", err)
+		}
+	case c.PasswordCommand != "":
+		//nolint:gosec // the command comes from the user's own configuration
+		cmd := exec.CommandContext(ctx, "sh", "-c", c.PasswordCommand)
+		cmd.Stderr = os.Stderr
+
+		if out, err = cmd.Output(); err != nil {
+			return fmt.Errorf("password command failed: %w", err)
+		}
+	default:
+		return nil
+	}
+
+	line, _, _ := strings.Cut(string(out), "\n")
+	c.Password = strings.TrimRight(line, "\r")
+
+	return nil
+}
+
+// hostWithPort accepts a host optionally followed by a port, such as the
+// address of `tmhi-cli simulate`.
+//
+//nolint:gochecknoglobals
+var hostWithPort = validation.By(func(value any) error {
+	address, _ := value.(string)
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return is.Host.Validate(address) //nolint:wrapcheck
+	}
+
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return errInvalidPort
+	}
+
+	return is.Host.Validate(host) //nolint:wrapcheck
+})
+
 func flagNameFromField(field string) string {
 	if name, ok := fieldToFlag[field]; ok {
 		return name
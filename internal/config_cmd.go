@@ -0,0 +1,313 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	burntsushi "github.com/BurntSushi/toml"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	envPrefix      = "TMHI_"
+	configFilePerm = 0o600
+
+	sourceCommandLine = "command line"
+	sourceDefault     = "default"
+)
+
+// envVarName returns the environment variable setting the flag name, such as
+// TMHI_GATEWAY_IP for gateway.ip.
+func envVarName(name string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(name))
+}
+
+// envSources returns the sources of a flag that can only be set from the
+// command line or its TMHI_* environment variable.
+func (a *app) envSources(name string) cli.ValueSourceChain {
+	return a.tracked(name, cli.EnvVars(envVarName(name)))
+}
+
+// tracked wraps every source of the flag name so the one its value comes
+// from is remembered for `config show --sources`.
+func (a *app) tracked(name string, chain cli.ValueSourceChain) cli.ValueSourceChain {
+	sources := make([]cli.ValueSource, len(chain.Chain))
+
+	for i, source := range chain.Chain {
+		sources[i] = &trackedSource{ValueSource: source, name: name, origins: a.origins}
+	}
+
+	return cli.NewValueSourceChain(sources...)
+}
+
+// trackedSource records in origins which source provided a flag's value.
+// Flags given on the command line never look their sources up, so a set flag
+// without an origin came from the command line.
+type trackedSource struct {
+	cli.ValueSource
+
+	name    string
+	origins map[string]string
+}
+
+func (s *trackedSource) Lookup() (string, bool) {
+	value, ok := s.ValueSource.Lookup()
+	if ok {
+		s.origins[s.name] = s.String()
+	}
+
+	return value, ok
+}
+
+// IsFromEnv and Key keep environment variables listed in the help output.
+func (s *trackedSource) IsFromEnv() bool {
+	env, ok := s.ValueSource.(cli.EnvValueSource)
+
+	return ok && env.IsFromEnv()
+}
+
+func (s *trackedSource) Key() string {
+	if env, ok := s.ValueSource.(cli.EnvValueSource); ok {
+		return env.Key()
+	}
+
+	return ""
+}
+
+// settingJSON is one effective configuration value in `config show` output.
+type settingJSON struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Env    string `json:"env"`
+	Source string `json:"source,omitempty"`
+}
+
+// effectiveSettings lists the value of every global flag of root, with where
+// it came from. The password is masked.
+func (a *app) effectiveSettings(root *cli.Command) []settingJSON {
+	var settings []settingJSON
+
+	for _, flag := range root.Flags {
+		name := flag.Names()[0]
+		if name == "help" || name == "version" {
+			continue
+		}
+
+		value := fmt.Sprint(root.Value(name))
+		if name == ConfigPassword && value != "" {
+			value = redactedSecret
+		}
+
+		source, ok := a.origins[name]
+
+		switch {
+		case ok:
+		case root.IsSet(name):
+			source = sourceCommandLine
+		default:
+			source = sourceDefault
+		}
+
+		settings = append(settings, settingJSON{
+			Name:   name,
+			Value:  value,
+			Env:    envVarName(name),
+			Source: source,
+		})
+	}
+
+	return settings
+}
+
+func (a *app) configShow(_ context.Context, cmd *cli.Command) error {
+	settings := a.effectiveSettings(cmd.Root())
+	withSources := cmd.Bool(ConfigSources)
+
+	if !withSources {
+		for i := range settings {
+			settings[i].Source = ""
+		}
+	}
+
+	if a.jsonOutput() {
+		return a.writeJSON(settings)
+	}
+
+	header := []string{"Setting", "Value", "Environment"}
+	if withSources {
+		header = append(header, "Source")
+	}
+
+	tableData := pterm.TableData{header}
+
+	for _, setting := range settings {
+		row := []string{setting.Name, setting.Value, setting.Env}
+		if withSources {
+			row = append(row, setting.Source)
+		}
+
+		tableData = append(tableData, row)
+	}
+
+	pterm.Print(renderTable(tableData))
+
+	return nil
+}
+
+// writeConfigFile sets the gateway settings of config in the configuration
+// file at path, keeping its other settings. Comments are not preserved.
+func writeConfigFile(path string, config *Config) error {
+	doc := map[string]any{}
+
+	if _, err := burntsushi.DecodeFile(path, &doc); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	gateway := subtable(doc, "gateway")
+	gateway["model"] = config.Model
+	gateway["ip"] = config.IP
+
+	login := subtable(doc, "login")
+	login["username"] = config.Username
+	login["password"] = config.Password
+
+	return writeTOML(path, doc)
+}
+
+// writeTOML encodes doc to path, readable by its owner only since
+// configuration files hold passwords. The document is written to a temporary
+// file next to path and renamed over it, so a failed write never leaves a
+// truncated configuration behind.
+func writeTOML(path string, doc any) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+	defer os.Remove(file.Name()) //nolint:errcheck
+
+	if err := encodeTOML(file, doc); err != nil {
+		file.Close() //nolint:errcheck,gosec
+
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write configuration: %w", err)
+	}
+
+	return nil
+}
+
+// encodeTOML writes doc to file with the configuration file permissions.
+func encodeTOML(file *os.File, doc any) error {
+	if err := file.Chmod(configFilePerm); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := burntsushi.NewEncoder(file).Encode(doc); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	return file.Sync() //nolint:wrapcheck
+}
+
+// askConfig prompts for the gateway settings, offering the current ones and
+// the detected model as defaults.
+func (a *app) askConfig(ctx context.Context) (*Config, error) {
+	config := *a.config
+
+	var err error
+
+	if config.IP, err = a.input(ctx, "Gateway IP", a.config.IP, false); err != nil {
+		return nil, err
+	}
+
+	defaultModel := a.config.Model
+
+	detected := detectModel(ctx, config.IP, config.Timeout)
+	if detected.Model != modelUnknown {
+		pterm.Info.Printfln("Detected %s (%s)",
+			detected.Model, strings.Join(detected.Evidence, "; "))
+		defaultModel = detected.Model
+	} else {
+		pterm.Warning.Printfln("Could not detect the gateway model (%s)",
+			strings.Join(detected.Evidence, "; "))
+	}
+
+	if defaultModel == "" {
+		defaultModel = ARCADYAN
+	}
+
+	if config.Model, err = a.choose(
+		ctx, "Gateway model", []string{ARCADYAN, NOK5G21}, defaultModel,
+	); err != nil {
+		return nil, err
+	}
+
+	if config.Username, err = a.input(ctx, "Admin username", a.config.Username, false); err != nil {
+		return nil, err
+	}
+
+	if config.Password, err = a.input(ctx, "Admin password", "", true); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// configInit asks for the gateway settings, checks them by logging in, and
+// only then sets them in the configuration file.
+func (a *app) configInit(ctx context.Context, cmd *cli.Command) error {
+	path := cmd.String(ConfigConfig)
+
+	if _, err := os.Stat(path); err == nil {
+		update, err := a.confirm(ctx, path+" already exists. Update its gateway and login?", false)
+		if err != nil {
+			return err
+		}
+
+		if !update {
+			pterm.Warning.Println("Configuration left unchanged")
+
+			return displayed(fmt.Errorf("update of %s %w", path, ErrCancelled))
+		}
+	}
+
+	config, err := a.askConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	gateway, err := a.initGateway(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	if err := runWithFeedback(
+		ctx,
+		a.newSpinner,
+		"Logging in...",
+		gateway.Login,
+		"Successfully logged in",
+	); err != nil {
+		return err
+	}
+
+	if err := writeConfigFile(path, config); err != nil {
+		return err
+	}
+
+	msg := "Configuration written to " + path
+	pterm.Success.Println(msg)
+
+	return a.report(jsonMessage{Message: msg})
+}
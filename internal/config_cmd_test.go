@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	burntsushi "github.com/BurntSushi/toml"
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+)
+
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "TMHI_GATEWAY_IP", envVarName(ConfigIP))
+	assert.Equal(t, "TMHI_DRY_RUN", envVarName(ConfigDryRun))
+	assert.Equal(t, "TMHI_LOGIN_PASSWORD_FILE", envVarName(ConfigPasswordFile))
+}
+
+func TestEnvSources_Precedence(t *testing.T) {
+	const configContents = "timeout = \"9s\"\n[gateway]\nip = \"10.0.0.1\"\nmodel = \"ARCADYAN\"\n"
+
+	t.Setenv("TMHI_GATEWAY_IP", "10.0.0.2")
+	t.Setenv("TMHI_GATEWAY_MODEL", NOK5G21)
+	t.Setenv("TMHI_DRY_RUN", "true")
+	t.Setenv("TMHI_RETRIES", "4")
+
+	config, err := runWithConfig(t, configContents, "--gateway.model", ARCADYAN)
+	require.NoError(t, err)
+
+	assert.Equal(t, ARCADYAN, config.Model, "flag over env")
+	assert.Equal(t, "10.0.0.2", config.IP, "env over TOML")
+	assert.Equal(t, "9s", config.Timeout.String(), "TOML over default")
+	assert.Equal(t, 4, config.Retries, "env over default")
+	assert.True(t, config.DryRun)
+	assert.Equal(t, defaultUser, config.Username)
+}
+
+func TestConfigShow_Sources(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile,
+		[]byte("[gateway]\nip = \"10.0.0.1\"\n[login]\npassword = \"s3cret\"\n"), 0o600))
+	t.Setenv("TMHI_RETRIES", "2")
+	// --output json sends pterm output to stderr.
+	t.Cleanup(func() { pterm.SetDefaultOutput(os.Stdout) })
+
+	a := newApp()
+
+	var buf bytes.Buffer
+
+	a.stdout = &buf
+
+	configSource := altsrc.NewStringPtrSourcer(&configFile)
+	root := &cli.Command{
+		Name:     appName,
+		Flags:    a.flags(&configFile, configSource),
+		Commands: a.commands(configSource),
+	}
+
+	err := root.Run(t.Context(), []string{
+		appName, "-c", configFile, "--output", "json", "--timeout", "3s",
+		cmdConfig, "show", "--sources",
+	})
+	require.NoError(t, err)
+
+	ok, data, _ := decodeEnvelope(t, &buf)
+	require.True(t, ok)
+
+	var settings []settingJSON
+	require.NoError(t, json.Unmarshal(data, &settings))
+
+	byName := map[string]settingJSON{}
+	for _, setting := range settings {
+		byName[setting.Name] = setting
+	}
+
+	assert.Equal(t, sourceCommandLine, byName[ConfigTimeout].Source)
+	assert.Equal(t, "3s", byName[ConfigTimeout].Value)
+	assert.Equal(t, `environment variable "TMHI_RETRIES"`, byName[ConfigRetries].Source)
+	assert.Contains(t, byName[ConfigIP].Source, `at key "gateway.ip"`)
+	assert.Equal(t, "10.0.0.1", byName[ConfigIP].Value)
+	assert.Equal(t, sourceDefault, byName[ConfigUsername].Source)
+	assert.Equal(t, redactedSecret, byName[ConfigPassword].Value)
+	assert.Equal(t, "TMHI_LOGIN_PASSWORD", byName[ConfigPassword].Env)
+	assert.NotContains(t, byName, "help")
+}
+
+func TestTrackedSource_KeepsEnvInHelp(t *testing.T) {
+	chain := newApp().envSources(ConfigDebug)
+
+	assert.Equal(t, []string{"TMHI_DEBUG"}, chain.EnvKeys())
+}
+
+// scriptAnswers makes a's prompts answer from answers, keyed by prompt.
+func scriptAnswers(a *app, answers map[string]string) {
+	a.input = func(_ context.Context, msg, _ string, _ bool) (string, error) {
+		return answers[msg], nil
+	}
+	a.choose = func(_ context.Context, msg string, _ []string, _ string) (string, error) {
+		return answers[msg], nil
+	}
+}
+
+func runConfigInit(t *testing.T, a *app, path string) error {
+	t.Helper()
+
+	cmd := &cli.Command{
+		Name:   "init",
+		Flags:  []cli.Flag{&cli.StringFlag{Name: ConfigConfig, Value: path}},
+		Action: a.configInit,
+	}
+
+	return cmd.Run(t.Context(), []string{"init"})
+}
+
+func TestConfigInit(t *testing.T) {
+	// Nothing listens there, so detection finds no model.
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	answers := map[string]string{
+		"Gateway IP":     host,
+		"Gateway model":  NOK5G21,
+		"Admin username": "owner",
+		"Admin password": "s3cret",
+	}
+
+	t.Run("writes the verified settings", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		gw := &mockGateway{}
+		a := newTestApp(gw)
+		scriptAnswers(a, answers)
+
+		require.NoError(t, runConfigInit(t, a, path))
+		assert.True(t, gw.loginCalled)
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(configFilePerm), info.Mode().Perm())
+
+		config, err := runWithConfig(t, readFile(t, path))
+		require.NoError(t, err)
+		assert.Equal(t, NOK5G21, config.Model)
+		assert.Equal(t, host, config.IP)
+		assert.Equal(t, "owner", config.Username)
+		assert.Equal(t, "s3cret", config.Password)
+	})
+
+	t.Run("keeps an existing file unless confirmed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		require.NoError(t, os.WriteFile(path, []byte("# mine\n"), 0o644))
+
+		gw := &mockGateway{}
+		a := newTestApp(gw)
+		scriptAnswers(a, answers)
+
+		require.ErrorIs(t, runConfigInit(t, a, path), ErrCancelled)
+		assert.False(t, gw.loginCalled)
+		assert.Equal(t, "# mine\n", readFile(t, path))
+
+		a.confirm = func(context.Context, string, bool) (bool, error) { return true, nil }
+
+		require.NoError(t, runConfigInit(t, a, path))
+		assert.Contains(t, readFile(t, path), "s3cret")
+
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(configFilePerm), info.Mode().Perm())
+	})
+
+	t.Run("keeps the other settings of an existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		require.NoError(t, os.WriteFile(path, []byte(`[gateway]
+ip = "192.168.12.1"
+model = "ARCADYAN"
+
+[profiles.home]
+ip = "10.0.0.1"
+
+[[notify]]
+exec = "notify-send"
+`), 0o600))
+
+		a := newTestApp(&mockGateway{})
+		scriptAnswers(a, answers)
+		a.confirm = func(context.Context, string, bool) (bool, error) { return true, nil }
+
+		require.NoError(t, runConfigInit(t, a, path))
+
+		doc := map[string]any{}
+		_, err := burntsushi.DecodeFile(path, &doc)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"ip": host, "model": NOK5G21}, doc["gateway"])
+		assert.Equal(t, map[string]any{"username": "owner", "password": "s3cret"}, doc["login"])
+		assert.Equal(t, map[string]any{"home": map[string]any{"ip": "10.0.0.1"}}, doc[profilesKey])
+		assert.Equal(t, []map[string]any{{"exec": "notify-send"}}, doc["notify"])
+	})
+
+	t.Run("does not write settings that fail to log in", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		a := newTestApp(&mockGateway{loginErr: errGatewayDown})
+		scriptAnswers(a, answers)
+
+		require.Error(t, runConfigInit(t, a, path))
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("writes the entered settings under --record and --replay", func(t *testing.T) {
+		_, gatewayHost := simulator.Start(t, simulator.Options{Model: simulator.ModelNokia})
+		recording := t.TempDir()
+
+		for _, traffic := range []func(*Config){
+			func(c *Config) { c.Record = recording },
+			func(c *Config) { c.Replay = recording },
+		} {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			a := newTestApp(nil)
+			a.config.Timeout = time.Second
+			traffic(a.config)
+			a.initGateway = a.gatewayOpener("test")
+			t.Cleanup(a.taps.close)
+			scriptAnswers(a, map[string]string{
+				"Gateway IP":     gatewayHost,
+				"Gateway model":  "",
+				"Admin username": "owner",
+				"Admin password": "s3cret",
+			})
+
+			require.NoError(t, runConfigInit(t, a, path))
+
+			var doc map[string]any
+
+			_, err := burntsushi.DecodeFile(path, &doc)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"ip": gatewayHost, "model": NOK5G21}, doc["gateway"])
+			assert.Equal(t, map[string]any{"username": "owner", "password": "s3cret"}, doc["login"])
+		}
+	})
+}
+
+func TestWriteTOML_KeepsFileOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("# mine\n"), 0o600))
+
+	require.Error(t, writeTOML(path, map[string]any{"broken": make(chan int)}))
+	assert.Equal(t, "# mine\n", readFile(t, path))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file is removed")
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	return string(data)
+}
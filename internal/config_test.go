@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -51,15 +53,37 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: nil,
 		},
 		{
-			name: "missing model",
+			name: "IP with port",
 			config: Config{
-				IP:       defaultIP,
+				Model:    ARCADYAN,
+				IP:       "127.0.0.1:8080",
+				Username: defaultUser,
+				Password: testPassword,
+				Timeout:  5 * time.Second,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid port",
+			config: Config{
+				Model:    ARCADYAN,
+				IP:       "127.0.0.1:http",
 				Username: defaultUser,
 				Password: testPassword,
 				Timeout:  5 * time.Second,
 			},
 			wantErr: ErrInvalidConfig,
 		},
+		{
+			name: "missing model is detected later",
+			config: Config{
+				IP:       defaultIP,
+				Username: defaultUser,
+				Password: testPassword,
+				Timeout:  5 * time.Second,
+			},
+			wantErr: nil,
+		},
 		{
 			name: "invalid model",
 			config: Config{
@@ -160,3 +184,60 @@ func TestFlagNameFromField_UnknownField(t *testing.T) {
 	result := flagNameFromField("UnknownField")
 	assert.Equal(t, "unknownfield", result)
 }
+
+func TestConfig_ResolvePassword(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\nignored\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "password set directly wins",
+			config: Config{Password: testPassword, PasswordFile: passwordFile},
+			want:   testPassword,
+		},
+		{
+			name:   "first line of the password file",
+			config: Config{PasswordFile: passwordFile, PasswordCommand: "echo from-command"},
+			want:   "from-file",
+		},
+		{
+			name:   "first line printed by the command",
+			config: Config{PasswordCommand: "printf 'from-command\\r\\nmetadata\\n'"},
+			want:   "from-command",
+		},
+		{
+			name:   "nothing to resolve",
+			config: Config{},
+			want:   "",
+		},
+		{
+			name:    "missing password file",
+			config:  Config{PasswordFile: filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to read password file",
+		},
+		{
+			name:    "failing command",
+			config:  Config{PasswordCommand: "exit 3"},
+			wantErr: "password command failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.resolvePassword(t.Context())
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, tt.config.Password)
+		})
+	}
+}
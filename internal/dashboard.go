@@ -0,0 +1,576 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+const (
+	defaultDashboardInterval = 5 * time.Second
+
+	// dashboardHistory is the number of samples the sparklines show.
+	dashboardHistory = 60
+	// dashboardSlowEvery is the number of refreshes between two updates of the
+	// uptime and clients, which take heavier requests.
+	dashboardSlowEvery = 6
+
+	arcadyanClientsPath = "/TMI/v1/network/telemetry?get=clients"
+
+	keyCtrlC = 3
+
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+// ErrDashboardJSON is returned when the dashboard is asked for JSON output.
+var ErrDashboardJSON = errors.New("the dashboard only supports text output")
+
+//nolint:gochecknoglobals
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// uptimePaths are where gateway information reports the uptime in seconds:
+// Arcadyan, then Nokia.
+//
+//nolint:gochecknoglobals
+var uptimePaths = []string{".time.upTime", ".UpTime", ".device_app_status[0].UpTime"}
+
+// screen is a full-screen display reading single key presses.
+type screen interface {
+	Draw(frame string)
+	Keys() <-chan rune
+	Close() error
+}
+
+// termScreen draws on the alternate screen of the terminal, which is left
+// as it was on Close. Keys are read in raw mode when standard input is a
+// terminal, the reading blocking on stdin in the background as in
+// ptermConfirm.
+type termScreen struct {
+	out   io.Writer
+	fd    int
+	state *term.State
+	keys  chan rune
+}
+
+//nolint:ireturn
+func newTermScreen() (screen, error) {
+	s := &termScreen{out: os.Stdout, fd: int(os.Stdin.Fd()), keys: make(chan rune, 1)}
+
+	if term.IsTerminal(s.fd) {
+		state, err := term.MakeRaw(s.fd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up terminal: %w", err)
+		}
+
+		s.state = state
+
+		go s.readKeys()
+	}
+
+	_, _ = io.WriteString(s.out, enterAltScreen)
+
+	return s, nil
+}
+
+func (s *termScreen) readKeys() {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		key, _, err := reader.ReadRune()
+		if err != nil {
+			return
+		}
+
+		s.keys <- key
+	}
+}
+
+// Draw replaces the screen with frame. Raw mode does not return the carriage
+// at line feeds, so they are written as CRLF.
+func (s *termScreen) Draw(frame string) {
+	_, _ = io.WriteString(s.out, clearScreen+strings.ReplaceAll(frame, "\n", "\r\n"))
+}
+
+func (s *termScreen) Keys() <-chan rune {
+	return s.keys
+}
+
+func (s *termScreen) Close() error {
+	_, _ = io.WriteString(s.out, leaveAltScreen)
+
+	if s.state == nil {
+		return nil
+	}
+
+	return term.Restore(s.fd, s.state) //nolint:wrapcheck
+}
+
+// dashboard is what the dashboard shows.
+type dashboard struct {
+	target   string
+	interval time.Duration
+	signal   *tmhi.SignalResult
+	status   *tmhi.StatusResult
+	// history holds the last values of each metric, keyed by radio and
+	// metric name.
+	history map[string][]float64
+	uptime  time.Duration
+	// hasUptime and hasClients are set when the last update found them.
+	hasUptime  bool
+	clients    int
+	hasClients bool
+	updated    time.Time
+	err        error
+	// confirming is set while a reboot waits for confirmation.
+	confirming bool
+	// loggedOut is set once a reboot ended the session.
+	loggedOut bool
+	message   string
+	notify    *notifier
+	// cells tracks the serving cells for cell change notifications.
+	cells *cellTracker
+}
+
+func newDashboard(target string, interval time.Duration) *dashboard {
+	return &dashboard{
+		target:   target,
+		interval: interval,
+		history:  map[string][]float64{},
+		cells:    newCellTracker(),
+	}
+}
+
+// addSignal records the metrics of result in the history.
+func (d *dashboard) addSignal(result *tmhi.SignalResult) {
+	d.signal = result
+
+	for _, radio := range signalRadios(result) {
+		for _, metric := range rateSignalData(radio.data) {
+			key := radio.name + " " + metric.name
+
+			values := append(d.history[key], metric.rating.Value)
+			if len(values) > dashboardHistory {
+				values = values[len(values)-dashboardHistory:]
+			}
+
+			d.history[key] = values
+		}
+	}
+}
+
+// dashboardRadio is the signal of one radio access technology.
+type dashboardRadio struct {
+	name    string
+	data    *tmhi.SignalData
+	details []string
+}
+
+func signalRadios(result *tmhi.SignalResult) []dashboardRadio {
+	var radios []dashboardRadio
+
+	if result.FourG != nil {
+		radios = append(radios, dashboardRadio{
+			name:    "4G",
+			data:    &result.FourG.SignalData,
+			details: []string{"eNB " + strconv.Itoa(result.FourG.ENBID)},
+		})
+	}
+
+	if result.FiveG != nil {
+		details := []string{"gNB " + strconv.Itoa(result.FiveG.GNBID)}
+		if result.FiveG.AntennaUsed != "" {
+			details = append(details, "Antenna "+result.FiveG.AntennaUsed)
+		}
+
+		radios = append(radios, dashboardRadio{
+			name:    "5G",
+			data:    &result.FiveG.SignalData,
+			details: details,
+		})
+	}
+
+	return radios
+}
+
+func (d *dashboard) render() string {
+	var out strings.Builder
+
+	// Without styling, the header has no line feed of its own.
+	header := pterm.DefaultHeader.WithFullWidth().Sprint(appName + " dashboard - " + d.target)
+	out.WriteString(strings.TrimRight(header, "\n") + "\n")
+	out.WriteString(d.renderSummary() + "\n")
+
+	if d.updated.IsZero() {
+		out.WriteString(pterm.Gray("Waiting for the gateway...") + "\n\n")
+	} else {
+		out.WriteString(pterm.Gray(fmt.Sprintf("Updated %s, refreshing every %s",
+			d.updated.Format(time.TimeOnly), d.interval)) + "\n\n")
+	}
+
+	if d.signal != nil {
+		radios := signalRadios(d.signal)
+		for _, radio := range radios {
+			out.WriteString(d.renderRadio(radio))
+		}
+
+		if len(radios) == 0 {
+			out.WriteString(pterm.Warning.Sprintln("No signal information available"))
+		}
+	}
+
+	if d.err != nil {
+		out.WriteString(pterm.Error.Sprintln("Last refresh failed: " + d.err.Error()))
+	}
+
+	if d.message != "" {
+		out.WriteString(pterm.Info.Sprintln(d.message))
+	}
+
+	if d.confirming {
+		out.WriteString(pterm.Warning.Sprintln("Reboot the gateway? y/n"))
+	} else {
+		out.WriteString(pterm.Gray("q quit   r reboot") + "\n")
+	}
+
+	return out.String()
+}
+
+func (d *dashboard) renderSummary() string {
+	label := pterm.ThemeDefault.SecondaryStyle.Sprint
+
+	webInterface, registration := "-", "-"
+	if d.status != nil {
+		webInterface = pterm.ThemeDefault.ErrorMessageStyle.Sprint("down")
+		if d.status.WebInterfaceUp {
+			webInterface = pterm.ThemeDefault.SuccessMessageStyle.Sprint("up")
+		}
+
+		if d.status.Registration != "" {
+			registration = d.status.Registration
+		}
+	}
+
+	if d.signal != nil && d.signal.Generic.Registration != "" {
+		registration = d.signal.Generic.Registration
+	}
+
+	uptime, clients := "-", "n/a"
+	if d.hasUptime {
+		uptime = formatUptime(d.uptime)
+	}
+
+	if d.hasClients {
+		clients = strconv.Itoa(d.clients)
+	}
+
+	return strings.Join([]string{
+		label("Web interface ") + webInterface,
+		label("Registration ") + registration,
+		label("Uptime ") + uptime,
+		label("Clients ") + clients,
+	}, "   ") + "\n"
+}
+
+func (d *dashboard) renderRadio(radio dashboardRadio) string {
+	details := append([]string{
+		"CID " + strconv.Itoa(radio.data.CID),
+		"Bands " + strings.Join(radio.data.Bands, ", "),
+		fmt.Sprintf("Bars %.0f", radio.data.Bars),
+	}, radio.details...)
+
+	tableData := pterm.TableData{{"Metric", "Value", "Rating", "Trend", "Range"}}
+
+	for _, metric := range rateSignalData(radio.data) {
+		style := qualityStyle(metric.rating.Quality)
+		values := d.history[radio.name+" "+metric.name]
+
+		tableData = append(tableData, []string{
+			metric.name,
+			style.Sprint(strconv.FormatFloat(metric.rating.Value, 'f', -1, 64) + " " +
+				metric.rating.Metric.Unit()),
+			metric.rating.Quality.String() + " " + metric.rating.Quality.Stars(),
+			style.Sprint(sparkline(values)),
+			valueRange(values),
+		})
+	}
+
+	return pterm.ThemeDefault.PrimaryStyle.Sprint(radio.name) + "  " +
+		strings.Join(details, "   ") + "\n" + renderTable(tableData)
+}
+
+// qualityStyle colors a metric by its rating with the message styles of the
+// theme, which setupColor adapts to light backgrounds.
+func qualityStyle(quality signal.Quality) pterm.Style {
+	switch quality {
+	case signal.Excellent:
+		return pterm.ThemeDefault.SuccessMessageStyle
+	case signal.Good:
+		return pterm.ThemeDefault.InfoMessageStyle
+	case signal.Fair:
+		return pterm.ThemeDefault.WarningMessageStyle
+	default:
+		return pterm.ThemeDefault.ErrorMessageStyle
+	}
+}
+
+// sparkline draws values as block characters, scaled from their minimum to
+// their maximum.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	low, high := slices.Min(values), slices.Max(values)
+	top := float64(len(sparkBlocks) - 1)
+
+	var out strings.Builder
+
+	for _, value := range values {
+		level := top / 2
+		if high > low {
+			level = (value - low) / (high - low) * top
+		}
+
+		out.WriteRune(sparkBlocks[int(math.Round(level))])
+	}
+
+	return out.String()
+}
+
+func valueRange(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strconv.FormatFloat(slices.Min(values), 'f', -1, 64) + ".." +
+		strconv.FormatFloat(slices.Max(values), 'f', -1, 64)
+}
+
+// formatUptime formats d in days, hours and minutes.
+func formatUptime(d time.Duration) string {
+	const day = 24 * time.Hour
+
+	days := d / day
+	hours := (d % day) / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	}
+
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// uptimeFromInfo finds the uptime in the gateway information.
+func uptimeFromInfo(info string) (time.Duration, bool) {
+	for _, path := range uptimePaths {
+		values, err := filterJSON([]byte(info), path)
+		if err != nil || len(values) != 1 {
+			continue
+		}
+
+		number, ok := values[0].(json.Number)
+		if !ok {
+			continue
+		}
+
+		seconds, err := number.Float64()
+		if err == nil {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+	}
+
+	return 0, false
+}
+
+// countClients counts the devices of an Arcadyan client list, grouped by
+// connection type.
+func countClients(body string) (int, bool) {
+	values, err := filterJSON([]byte(body), ".clients[][]")
+	if err != nil {
+		return 0, false
+	}
+
+	return len(values), true
+}
+
+func (a *app) dashboard(ctx context.Context, cmd *cli.Command) error {
+	if a.jsonOutput() {
+		return ErrDashboardJSON
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	// The session is needed for the client list and reboots.
+	if err := runWithFeedback(ctx, a.newSpinner, "Logging in...", gateway.Login); err != nil {
+		return err
+	}
+
+	scr, err := a.newScreen()
+	if err != nil {
+		return err
+	}
+	defer scr.Close() //nolint:errcheck
+
+	target := a.config.IP
+	if a.config.Model != "" {
+		target += " (" + a.config.Model + ")"
+	}
+
+	notify, err := a.newNotifier(cmd)
+	if err != nil {
+		return err
+	}
+
+	d := newDashboard(target, cmd.Duration(ConfigInterval))
+	d.notify = notify
+	// Warnings would garble the screen.
+	notify.onError = func(err error) { d.message = a.secrets.redact(err.Error()) }
+
+	return a.runDashboard(ctx, gateway, scr, d)
+}
+
+// runDashboard refreshes d every interval and handles key presses until q or
+// Ctrl-C is pressed or ctx is cancelled. Failed refreshes are shown with the
+// last data, since the gateway is often briefly unreachable.
+func (a *app) runDashboard(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	scr screen,
+	d *dashboard,
+) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for refresh := 0; ; refresh++ {
+		a.refreshDashboard(ctx, gateway, d, refresh%dashboardSlowEvery == 0)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		scr.Draw(d.render())
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				waiting = false
+			case key := <-scr.Keys():
+				if a.handleDashboardKey(ctx, gateway, d, key) {
+					return nil
+				}
+
+				scr.Draw(d.render())
+			}
+		}
+	}
+}
+
+// refreshDashboard fetches the signal and status, and the uptime and clients
+// when slow is set.
+func (a *app) refreshDashboard(ctx context.Context, gateway tmhi.Gateway, d *dashboard, slow bool) {
+	var errs []error
+
+	if result, err := gateway.Signal(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		d.addSignal(result)
+
+		samples := samplesFromSignal(time.Now(), result)
+		d.notify.signal(ctx, samples, d.cells.track(samples), result.Generic.Registration)
+	}
+
+	if result, err := gateway.Status(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		d.status = result
+		d.notify.status(ctx, result)
+	}
+
+	d.err = errors.Join(errs...)
+	if d.err == nil {
+		d.updated = time.Now()
+	}
+
+	if !slow {
+		return
+	}
+
+	if d.loggedOut && gateway.Login(ctx) == nil {
+		d.loggedOut = false
+	}
+
+	d.hasUptime = false
+	if info, err := gateway.Info(ctx); err == nil {
+		d.uptime, d.hasUptime = uptimeFromInfo(info.String())
+	}
+
+	d.hasClients = false
+	if a.config.Model == ARCADYAN {
+		if clients, err := gateway.Request(ctx, http.MethodGet, arcadyanClientsPath); err == nil {
+			d.clients, d.hasClients = countClients(clients.String())
+		}
+	}
+}
+
+// handleDashboardKey acts on a key press, reporting whether to quit.
+func (a *app) handleDashboardKey(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	d *dashboard,
+	key rune,
+) bool {
+	if key == 'q' || key == keyCtrlC {
+		return true
+	}
+
+	if !d.confirming {
+		if key == 'r' {
+			d.confirming = true
+			d.message = ""
+		}
+
+		return false
+	}
+
+	d.confirming = false
+
+	switch {
+	case key != 'y' && key != 'Y':
+		d.message = "Reboot cancelled"
+	case a.config.DryRun:
+		d.message = "Dry run - would send reboot request"
+	default:
+		if err := gateway.Reboot(ctx); err != nil {
+			d.message = "Reboot failed: " + a.secrets.redact(err.Error())
+		} else {
+			d.message = "Reboot command sent successfully"
+			d.loggedOut = true
+			d.notify.reboot(ctx)
+		}
+	}
+
+	return false
+}
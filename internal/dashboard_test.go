@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// fakeScreen records the frames drawn and plays scripted key presses.
+type fakeScreen struct {
+	frames []string
+	keys   chan rune
+	closed bool
+}
+
+func newFakeScreen(keys ...rune) *fakeScreen {
+	s := &fakeScreen{keys: make(chan rune, len(keys))}
+	for _, key := range keys {
+		s.keys <- key
+	}
+
+	return s
+}
+
+func (s *fakeScreen) Draw(frame string) { s.frames = append(s.frames, frame) }
+
+func (s *fakeScreen) Keys() <-chan rune { return s.keys }
+
+func (s *fakeScreen) Close() error {
+	s.closed = true
+
+	return nil
+}
+
+func (s *fakeScreen) drew(text string) bool {
+	for _, frame := range s.frames {
+		if strings.Contains(pterm.RemoveColorFromString(frame), text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newDashboardCmd(a *app) *cli.Command {
+	return &cli.Command{
+		Name:   cmdDashboard,
+		Action: a.dashboard,
+		Flags: []cli.Flag{
+			&cli.DurationFlag{Name: ConfigInterval, Value: time.Hour},
+		},
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	assert.Empty(t, sparkline(nil))
+	assert.Equal(t, "▁▅█", sparkline([]float64{-110, -100, -90}))
+	assert.Equal(t, "▅▅", sparkline([]float64{3, 3}))
+	assert.Equal(t, "-110..-90", valueRange([]float64{-100, -110, -90}))
+}
+
+func TestFormatUptime(t *testing.T) {
+	assert.Equal(t, "0h 5m", formatUptime(5*time.Minute+30*time.Second))
+	assert.Equal(t, "2d 3h 4m", formatUptime(51*time.Hour+4*time.Minute))
+}
+
+func TestUptimeFromInfo(t *testing.T) {
+	for info, want := range map[string]time.Duration{
+		`{"time":{"upTime":3600}}`: time.Hour,
+		`{"UpTime":90}`:            90 * time.Second,
+		`{"device_app_status":[{"UpTime":"x"}],"UpTime":60}`: time.Minute,
+	} {
+		uptime, ok := uptimeFromInfo(info)
+		assert.True(t, ok, info)
+		assert.Equal(t, want, uptime, info)
+	}
+
+	for _, info := range []string{`{"device":{}}`, "not JSON", `{"UpTime":"soon"}`} {
+		_, ok := uptimeFromInfo(info)
+		assert.False(t, ok, info)
+	}
+}
+
+func TestCountClients(t *testing.T) {
+	count, ok := countClients(`{"clients":{"2.4ghz":[{}],"5.0ghz":[{},{}],"ethernet":[]}}`)
+	assert.True(t, ok)
+	assert.Equal(t, 3, count)
+
+	_, ok = countClients(`{"clients":3}`)
+	assert.False(t, ok)
+}
+
+func TestDashboard_History(t *testing.T) {
+	d := newDashboard("gateway", time.Second)
+
+	for i := range dashboardHistory + 5 {
+		result := testSignalResult()
+		result.FiveG.SINR = i
+		d.addSignal(result)
+	}
+
+	sinr := d.history["5G SINR"]
+	require.Len(t, sinr, dashboardHistory)
+	assert.InDelta(t, dashboardHistory+4, sinr[len(sinr)-1], 0)
+	assert.NotContains(t, d.history, "4G SINR")
+}
+
+func TestDashboard_Render(t *testing.T) {
+	d := newDashboard("192.168.12.1 (ARCADYAN)", 5*time.Second)
+	frame := pterm.RemoveColorFromString(d.render())
+	assert.Contains(t, frame, "Waiting for the gateway")
+	assert.Contains(t, frame, "Uptime -")
+	assert.Contains(t, frame, "Clients n/a")
+
+	d.addSignal(testSignalResult())
+	d.uptime, d.hasUptime = 26*time.Hour, true
+	d.clients, d.hasClients = 4, true
+	d.updated = time.Now()
+	d.err = errors.New("timeout")
+	d.confirming = true
+
+	frame = pterm.RemoveColorFromString(d.render())
+	for _, want := range []string{
+		"192.168.12.1 (ARCADYAN)", "Registration " + testRegState, "Uptime 1d 2h 0m",
+		"Clients 4", "refreshing every 5s", "5G", "gNB 67890", "CID 2001", "Bands n41, n71",
+		"SINR", "12 dB", "Last refresh failed: timeout", "Reboot the gateway? y/n",
+	} {
+		assert.Contains(t, frame, want)
+	}
+
+	assert.NotContains(t, frame, "4G")
+}
+
+func TestDashboard_KeysAndReboot(t *testing.T) {
+	mg := &mockGateway{signalResult: testSignalResult()}
+	a := newTestApp(mg)
+	scr := newFakeScreen('r', 'n', 'r', 'y', 'q')
+	a.newScreen = func() (screen, error) { return scr, nil }
+
+	require.NoError(t, newDashboardCmd(a).Run(t.Context(), []string{cmdDashboard}))
+
+	assert.True(t, mg.loginCalled)
+	assert.True(t, mg.signalCalled)
+	assert.True(t, mg.statusCalled)
+	assert.True(t, mg.infoCalled)
+	assert.True(t, mg.rebootCalled)
+	assert.True(t, scr.closed)
+	assert.True(t, scr.drew("Reboot the gateway? y/n"))
+	assert.True(t, scr.drew("Reboot cancelled"))
+	assert.True(t, scr.drew("Reboot command sent successfully"))
+}
+
+func TestDashboard_DryRunReboot(t *testing.T) {
+	mg := &mockGateway{}
+	a := newTestApp(mg)
+	a.config.DryRun = true
+	scr := newFakeScreen('r', 'y', keyCtrlC)
+	a.newScreen = func() (screen, error) { return scr, nil }
+
+	require.NoError(t, newDashboardCmd(a).Run(t.Context(), []string{cmdDashboard}))
+
+	assert.False(t, mg.rebootCalled)
+	assert.True(t, scr.drew("Dry run - would send reboot request"))
+	assert.True(t, scr.drew("No signal information available"))
+}
+
+func TestDashboard_Errors(t *testing.T) {
+	a, _ := newJSONTestApp(&mockGateway{})
+	require.ErrorIs(t, newDashboardCmd(a).Run(t.Context(), []string{cmdDashboard}),
+		ErrDashboardJSON)
+
+	errLogin := errors.New("login refused")
+	a = newTestApp(&mockGateway{loginErr: errLogin})
+	a.newScreen = func() (screen, error) {
+		t.Fatal("no screen without a session")
+
+		return nil, nil //nolint:nilnil
+	}
+	require.ErrorIs(t, newDashboardCmd(a).Run(t.Context(), []string{cmdDashboard}), errLogin)
+}
+
+func TestDashboard_RefreshFailureKeepsData(t *testing.T) {
+	mg := &mockGateway{signalResult: testSignalResult()}
+	a := newTestApp(mg)
+	d := newDashboard("gateway", time.Second)
+
+	a.refreshDashboard(t.Context(), mg, d, false)
+	require.NoError(t, d.err)
+
+	updated := d.updated
+	mg.signalErr = errors.New("unreachable")
+	a.refreshDashboard(t.Context(), mg, d, false)
+
+	require.ErrorIs(t, d.err, mg.signalErr)
+	assert.Equal(t, updated, d.updated)
+	assert.NotNil(t, d.signal)
+	assert.Len(t, d.history["5G RSRP"], 1)
+}
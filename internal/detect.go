@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	modelUnknown = "unknown"
+
+	// Unauthenticated endpoints each model answers.
+	arcadyanGatewayPath = "/TMI/v1/gateway?get=all"
+	nokiaNoncePath      = "/login_web_app.cgi?nonce"
+
+	maxProbeBody = 1 << 20
+
+	modelCachePerm = 0o600
+)
+
+// ErrModelUndetected is returned when no model is configured and none could be
+// detected from the gateway.
+var ErrModelUndetected = errors.New("could not detect the gateway model; set --" + ConfigModel)
+
+// detection is the gateway model guessed from the answers to unauthenticated
+// requests, along with what gave it away.
+type detection struct {
+	Host     string   `json:"host"`
+	Model    string   `json:"model"`
+	Evidence []string `json:"evidence"`
+
+	// answered is set when anything answered HTTP at Host.
+	answered bool
+}
+
+// detectModel fingerprints the gateway at host with harmless unauthenticated
+// requests. Model is modelUnknown when no known model answered; Evidence then
+// describes what did.
+func detectModel(ctx context.Context, host string, timeout time.Duration) detection {
+	result := detection{Host: host, Model: modelUnknown}
+	client := &http.Client{Timeout: timeout}
+
+	body, status, err := probeGet(ctx, client, host, arcadyanGatewayPath)
+	if err != nil {
+		// Nothing to fingerprint: spare the other probes their timeout.
+		result.Evidence = append(result.Evidence, "no answer: "+err.Error())
+
+		return result
+	}
+
+	result.answered = true
+
+	if status == http.StatusOK {
+		var doc struct {
+			Device struct {
+				Manufacturer string `json:"manufacturer"`
+				Model        string `json:"model"`
+			} `json:"device"`
+		}
+
+		if json.Unmarshal(body, &doc) == nil && doc.Device.Manufacturer != "" {
+			result.Evidence = append(result.Evidence, fmt.Sprintf(
+				"%s: manufacturer %q, model %q",
+				arcadyanGatewayPath, doc.Device.Manufacturer, doc.Device.Model))
+
+			if strings.EqualFold(doc.Device.Manufacturer, "Arcadyan") {
+				result.Model = ARCADYAN
+			}
+
+			return result
+		}
+	}
+
+	body, status, err = probeGet(ctx, client, host, nokiaNoncePath)
+	if err == nil && status == http.StatusOK {
+		var doc struct {
+			Nonce string `json:"nonce"`
+		}
+
+		if json.Unmarshal(body, &doc) == nil && doc.Nonce != "" {
+			result.Model = NOK5G21
+			result.Evidence = append(result.Evidence, nokiaNoncePath+": login nonce")
+
+			return result
+		}
+	}
+
+	result.Evidence = append(result.Evidence, fingerprintRoot(ctx, client, host))
+
+	return result
+}
+
+// fingerprintRoot describes what answers at the root of host, for gateways
+// no known model matched.
+func fingerprintRoot(ctx context.Context, client *http.Client, host string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/", nil)
+	if err != nil {
+		return err.Error()
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "no answer: " + err.Error()
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	fingerprint := fmt.Sprintf("/: status %d", resp.StatusCode)
+	if server := resp.Header.Get("Server"); server != "" {
+		fingerprint += fmt.Sprintf(", server %q", server)
+	}
+
+	return fingerprint
+}
+
+func probeGet(
+	ctx context.Context,
+	client *http.Client,
+	host, path string,
+) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+path, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GET %s: %w", path, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GET %s: %w", path, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return nil, 0, fmt.Errorf("GET %s: %w", path, err)
+	}
+
+	return body, resp.StatusCode, nil
+}
+
+// resolveModel detects the gateway model when the configuration leaves it
+// unset.
+func (c *Config) resolveModel(ctx context.Context) error {
+	if c.Model != "" {
+		return nil
+	}
+
+	detected := detectModel(ctx, c.IP, c.Timeout)
+	if detected.Model == modelUnknown {
+		err := fmt.Errorf("%w: %s: %s",
+			ErrModelUndetected, c.IP, strings.Join(detected.Evidence, "; "))
+		if !detected.answered {
+			// Nothing answered, so the model is not the problem.
+			err = fmt.Errorf("%w: %w", ErrGatewayUnreachable, err)
+		}
+
+		return err
+	}
+
+	pterm.Debug.Printfln("Detected %s at %s: %s",
+		detected.Model, c.IP, strings.Join(detected.Evidence, "; "))
+
+	c.Model = detected.Model
+
+	return nil
+}
+
+func defaultModelCachePath() string {
+	const modelCacheFileName = ".tmhi-cli-models.json"
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return modelCacheFileName
+	}
+
+	return filepath.Join(home, modelCacheFileName)
+}
+
+// modelCache remembers the model detected at each address, so that later
+// runs do not probe the gateway again. A cache without a path remembers
+// nothing.
+type modelCache struct {
+	path string
+}
+
+// load returns the model detected at address, or "" when none is known.
+func (c modelCache) load(address string) string {
+	return c.read()[address]
+}
+
+// store remembers model for address. Failing to do so only costs another
+// detection, so it is not an error.
+func (c modelCache) store(address, model string) {
+	if c.path == "" {
+		return
+	}
+
+	models := c.read()
+	if models[address] == model {
+		return
+	}
+
+	models[address] = model
+
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err == nil {
+		err = os.WriteFile(c.path, append(data, '\n'), modelCachePerm)
+	}
+
+	if err != nil {
+		pterm.Debug.Printfln("Failed to save the detected model to %s: %v", c.path, err)
+	}
+}
+
+func (c modelCache) read() map[string]string {
+	models := map[string]string{}
+	if c.path == "" {
+		return models
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return models
+	}
+
+	if err := json.Unmarshal(data, &models); err != nil {
+		pterm.Debug.Printfln("Ignoring the detected models in %s: %v", c.path, err)
+
+		return map[string]string{}
+	}
+
+	return models
+}
+
+func (a *app) detect(ctx context.Context, _ *cli.Command) error {
+	_, err := fetchWithFeedback(
+		ctx,
+		a.newSpinner,
+		fmt.Sprintf("Detecting gateway model at %s...", a.config.IP),
+		func(ctx context.Context) (detection, error) {
+			detected := detectModel(ctx, a.config.IP, a.config.Timeout)
+			if detected.Model != modelUnknown {
+				a.models.store(a.config.IP, detected.Model)
+			}
+
+			return detected, nil
+		},
+		present(a, displayDetection, func(d detection) any { return d }),
+	)
+
+	return err
+}
+
+func displayDetection(d detection) {
+	if d.Model == modelUnknown {
+		pterm.Warning.Printfln("Unknown gateway model at %s", d.Host)
+	} else {
+		pterm.Success.Printfln("%s gateway at %s", d.Model, d.Host)
+	}
+
+	tableData := pterm.TableData{{"Evidence"}}
+	for _, evidence := range d.Evidence {
+		tableData = append(tableData, []string{evidence})
+	}
+
+	pterm.Print(renderTable(tableData))
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFingerprintServer serves body at path and 404 everywhere else.
+func newFingerprintServer(t *testing.T, path, body string) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RequestURI() != path {
+			w.Header().Set("Server", "test-httpd")
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestDetectModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		want     string
+		evidence string
+	}{
+		{
+			name:     "arcadyan",
+			path:     arcadyanGatewayPath,
+			body:     `{"device":{"manufacturer":"Arcadyan","model":"KVD21"}}`,
+			want:     ARCADYAN,
+			evidence: `manufacturer "Arcadyan", model "KVD21"`,
+		},
+		{
+			name:     "other TMI gateway",
+			path:     arcadyanGatewayPath,
+			body:     `{"device":{"manufacturer":"Sagemcom","model":"Fast 5688W"}}`,
+			want:     modelUnknown,
+			evidence: `manufacturer "Sagemcom"`,
+		},
+		{
+			name:     "nokia",
+			path:     nokiaNoncePath,
+			body:     `{"nonce":"abc","pubkey":"key","randomKey":"xyz"}`,
+			want:     NOK5G21,
+			evidence: "login nonce",
+		},
+		{
+			name:     "something else",
+			path:     "/elsewhere",
+			body:     "hello",
+			want:     modelUnknown,
+			evidence: `status 404, server "test-httpd"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host := newFingerprintServer(t, tt.path, tt.body)
+
+			got := detectModel(t.Context(), host, time.Second)
+
+			assert.Equal(t, host, got.Host)
+			assert.Equal(t, tt.want, got.Model)
+			assert.Contains(t, strings.Join(got.Evidence, "; "), tt.evidence)
+		})
+	}
+}
+
+func TestDetectModel_NoAnswer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	got := detectModel(t.Context(), host, time.Second)
+
+	assert.Equal(t, modelUnknown, got.Model)
+	assert.Contains(t, got.Evidence[0], "no answer")
+}
+
+func TestConfig_ResolveModel(t *testing.T) {
+	t.Run("keeps a configured model", func(t *testing.T) {
+		config := &Config{Model: ARCADYAN, IP: "192.0.2.1", Timeout: time.Millisecond}
+
+		require.NoError(t, config.resolveModel(t.Context()))
+		assert.Equal(t, ARCADYAN, config.Model)
+	})
+
+	t.Run("detects a missing model", func(t *testing.T) {
+		host := newFingerprintServer(t, nokiaNoncePath, `{"nonce":"abc"}`)
+		config := &Config{IP: host, Timeout: time.Second}
+
+		require.NoError(t, config.resolveModel(t.Context()))
+		assert.Equal(t, NOK5G21, config.Model)
+	})
+
+	t.Run("reports the fingerprint of an unknown gateway", func(t *testing.T) {
+		host := newFingerprintServer(t, "/elsewhere", "")
+		config := &Config{IP: host, Timeout: time.Second}
+
+		err := config.resolveModel(t.Context())
+		require.ErrorIs(t, err, ErrModelUndetected)
+		assert.Contains(t, err.Error(), "status 404")
+		assert.Empty(t, config.Model)
+		assert.NotErrorIs(t, err, ErrGatewayUnreachable)
+	})
+
+	t.Run("reports a gateway that does not answer as unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		config := &Config{IP: strings.TrimPrefix(server.URL, "http://"), Timeout: time.Second}
+		server.Close()
+
+		err := config.resolveModel(t.Context())
+		require.ErrorIs(t, err, ErrModelUndetected)
+		require.ErrorIs(t, err, ErrGatewayUnreachable)
+		assert.Equal(t, ExitUnreachable, ExitCode(err))
+	})
+}
+
+func TestDetect_JSON(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	a.config.IP = newFingerprintServer(t, arcadyanGatewayPath,
+		`{"device":{"manufacturer":"Arcadyan","model":"KVD21"}}`)
+	a.config.Timeout = time.Second
+
+	require.NoError(t, a.detect(t.Context(), nil))
+
+	ok, data, _ := decodeEnvelope(t, buf)
+	require.True(t, ok)
+
+	var got detection
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, ARCADYAN, got.Model)
+	assert.Equal(t, a.config.IP, got.Host)
+}
+
+func TestApp_GatewayOpener_CachesModel(t *testing.T) {
+	var probes atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+
+		if r.URL.RequestURI() != nokiaNoncePath {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"nonce":"abc"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	a := newApp()
+	a.models = modelCache{path: filepath.Join(t.TempDir(), "models.json")}
+	host := strings.TrimPrefix(server.URL, "http://")
+	open := a.gatewayOpener("test")
+	newConfig := func() *Config {
+		return &Config{
+			IP: host, Username: "admin", Password: "secret", Timeout: time.Second, Retries: 1,
+		}
+	}
+
+	config := newConfig()
+	_, err := open(t.Context(), config)
+	require.NoError(t, err)
+	assert.Equal(t, NOK5G21, config.Model)
+	assert.Equal(t, NOK5G21, a.models.load(host))
+
+	detected := probes.Load()
+	require.Positive(t, detected)
+
+	config = newConfig()
+	_, err = open(t.Context(), config)
+	require.NoError(t, err)
+	assert.Equal(t, NOK5G21, config.Model)
+	assert.Equal(t, detected, probes.Load(), "the cached model is used without probing")
+}
+
+func TestDetect_RefreshesModelCache(t *testing.T) {
+	a, _ := newJSONTestApp(nil)
+	a.models = modelCache{path: filepath.Join(t.TempDir(), "models.json")}
+	a.config.IP = newFingerprintServer(t, arcadyanGatewayPath,
+		`{"device":{"manufacturer":"Arcadyan","model":"KVD21"}}`)
+	a.config.Timeout = time.Second
+	a.models.store(a.config.IP, NOK5G21)
+
+	require.NoError(t, a.detect(t.Context(), nil))
+	assert.Equal(t, ARCADYAN, a.models.load(a.config.IP))
+}
+
+func TestModelCache(t *testing.T) {
+	t.Run("remembers nothing without a path", func(t *testing.T) {
+		var cache modelCache
+
+		cache.store("192.0.2.1", ARCADYAN)
+		assert.Empty(t, cache.load("192.0.2.1"))
+	})
+
+	t.Run("ignores a corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "models.json")
+		require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+
+		cache := modelCache{path: path}
+		assert.Empty(t, cache.load("192.0.2.1"))
+
+		cache.store("192.0.2.1", ARCADYAN)
+		assert.Equal(t, ARCADYAN, cache.load("192.0.2.1"))
+	})
+}
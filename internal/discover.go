@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	burntsushi "github.com/BurntSushi/toml"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	procNetRoute = "/proc/net/route"
+
+	// maxScanBits bounds --cidr to a /20.
+	maxScanBits         = 12
+	maxConcurrentProbes = 32
+
+	viaDefaultRoute = "default route"
+	viaWellKnown    = "well-known address"
+	viaScan         = "scan"
+)
+
+var (
+	// ErrInvalidCIDR is returned when --cidr is not an IPv4 CIDR.
+	ErrInvalidCIDR = errors.New("invalid IPv4 CIDR")
+
+	// ErrNoDefaultRoute is returned when the routing table has no default
+	// gateway.
+	ErrNoDefaultRoute = errors.New("no default route")
+
+	// ErrCIDRTooLarge is returned when --cidr holds more addresses than a
+	// discovery may probe.
+	ErrCIDRTooLarge = fmt.Errorf("CIDR must hold at most %d addresses", 1<<maxScanBits)
+
+	// ErrNoGatewayFound is returned by `discover --save` when no responder was
+	// recognized as a known gateway model.
+	ErrNoGatewayFound = errors.New("no known gateway model found")
+)
+
+// discovery is a host that answered during `discover`, with how it was found.
+type discovery struct {
+	detection
+
+	Via string `json:"via"`
+}
+
+// discoveryCandidate is an address to fingerprint and why.
+type discoveryCandidate struct {
+	host string
+	via  string
+}
+
+// defaultGateway returns the gateway of the IPv4 default route, from
+// /proc/net/route on Linux or `route -n get default` elsewhere.
+func defaultGateway(ctx context.Context) (string, error) {
+	if file, err := os.Open(procNetRoute); err == nil {
+		defer file.Close() //nolint:errcheck
+
+		return parseProcNetRoute(file)
+	}
+
+	out, err := exec.CommandContext(ctx, "route", "-n", "get", "default").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the default route: %w", err)
+	}
+
+	return parseRouteGet(string(out))
+}
+
+// parseProcNetRoute returns the gateway of the first default route of a Linux
+// routing table, whose addresses are little-endian hexadecimal.
+func parseProcNetRoute(r io.Reader) (string, error) {
+	const (
+		destinationField = 1
+		gatewayField     = 2
+		flagsField       = 3
+		rtfGateway       = 0x2
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= flagsField || fields[destinationField] != "00000000" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[flagsField], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+
+		gateway, err := strconv.ParseUint(fields[gatewayField], 16, 32)
+		if err != nil || gateway == 0 {
+			continue
+		}
+
+		var addr [4]byte
+
+		binary.LittleEndian.PutUint32(addr[:], uint32(gateway))
+
+		return netip.AddrFrom4(addr).String(), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", procNetRoute, err)
+	}
+
+	return "", ErrNoDefaultRoute
+}
+
+// parseRouteGet returns the gateway line of `route -n get default` output.
+func parseRouteGet(out string) (string, error) {
+	for line := range strings.Lines(out) {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if ok && key == "gateway" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+
+	return "", ErrNoDefaultRoute
+}
+
+// scanHosts lists the host addresses of an IPv4 CIDR, leaving out the
+// network and broadcast addresses when there are such.
+func scanHosts(cidr string) ([]string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil || !prefix.Addr().Is4() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, cidr)
+	}
+
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > maxScanBits {
+		return nil, fmt.Errorf("%w: %s", ErrCIDRTooLarge, cidr)
+	}
+
+	size := 1 << hostBits
+
+	var hosts []string
+
+	addr := prefix.Addr()
+	for i := range size {
+		if size > 2 && (i == 0 || i == size-1) {
+			addr = addr.Next()
+
+			continue
+		}
+
+		hosts = append(hosts, addr.String())
+		addr = addr.Next()
+	}
+
+	return hosts, nil
+}
+
+// discoveryCandidates lists the default route's gateway, the well-known
+// gateway address and the hosts of cidr, each once.
+func (a *app) discoveryCandidates(ctx context.Context, cidr string) ([]discoveryCandidate, error) {
+	var candidates []discoveryCandidate
+
+	seen := map[string]bool{}
+	add := func(host, via string) {
+		if !seen[host] {
+			seen[host] = true
+			candidates = append(candidates, discoveryCandidate{host: host, via: via})
+		}
+	}
+
+	if gateway, err := a.defaultGateway(ctx); err != nil {
+		pterm.Debug.Printfln("No default route gateway: %v", err)
+	} else {
+		add(gateway, viaDefaultRoute)
+	}
+
+	add(defaultIP, viaWellKnown)
+
+	if cidr != "" {
+		hosts, err := scanHosts(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			add(host, viaScan)
+		}
+	}
+
+	return candidates, nil
+}
+
+// discoverGateways fingerprints every candidate concurrently and returns those
+// that answered, in candidate order.
+func (a *app) discoverGateways(
+	ctx context.Context,
+	candidates []discoveryCandidate,
+) []discovery {
+	results := make([]*discovery, len(candidates))
+	slots := make(chan struct{}, maxConcurrentProbes)
+
+	var wg sync.WaitGroup
+
+	for i, candidate := range candidates {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			detected := detectModel(ctx, candidate.host, a.config.Timeout)
+			if detected.answered {
+				results[i] = &discovery{detection: detected, Via: candidate.via}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	var found []discovery
+
+	for _, result := range results {
+		if result != nil {
+			found = append(found, *result)
+		}
+	}
+
+	return found
+}
+
+func (a *app) discover(ctx context.Context, cmd *cli.Command) error {
+	candidates, err := a.discoveryCandidates(ctx, cmd.String(ConfigCIDR))
+	if err != nil {
+		return err
+	}
+
+	found, err := fetchWithFeedback(
+		ctx,
+		a.newSpinner,
+		fmt.Sprintf("Probing %d addresses...", len(candidates)),
+		func(ctx context.Context) ([]discovery, error) {
+			return a.discoverGateways(ctx, candidates), nil
+		},
+		present(a, displayDiscoveries, func(found []discovery) any { return found }),
+	)
+	if err != nil || !cmd.Bool(ConfigSave) {
+		return err
+	}
+
+	chosen, err := a.chooseDiscovery(ctx, found)
+	if err != nil {
+		return err
+	}
+
+	path := cmd.String(ConfigConfig)
+	if err := saveGateway(path, a.config.Profile, chosen); err != nil {
+		return err
+	}
+
+	pterm.Success.Printfln("Saved %s gateway at %s to %s", chosen.Model, chosen.Host, path)
+
+	return nil
+}
+
+// chooseDiscovery picks the gateway to save among the recognized ones,
+// asking when there are several.
+func (a *app) chooseDiscovery(ctx context.Context, found []discovery) (discovery, error) {
+	var (
+		known   []discovery
+		options []string
+	)
+
+	for _, d := range found {
+		if d.Model != modelUnknown {
+			known = append(known, d)
+			options = append(options, fmt.Sprintf("%s (%s)", d.Host, d.Model))
+		}
+	}
+
+	switch len(known) {
+	case 0:
+		return discovery{}, ErrNoGatewayFound
+	case 1:
+		return known[0], nil
+	}
+
+	answer, err := a.choose(ctx, "Gateway to save", options, options[0])
+	if err != nil {
+		return discovery{}, err
+	}
+
+	for i, option := range options {
+		if option == answer {
+			return known[i], nil
+		}
+	}
+
+	return known[0], nil
+}
+
+// saveGateway sets the address and model of the discovered gateway in the
+// configuration file, under the selected profile if any, keeping its other
+// settings. Comments are not preserved.
+func saveGateway(path, profile string, d discovery) error {
+	doc := map[string]any{}
+
+	if _, err := burntsushi.DecodeFile(path, &doc); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read configuration: %w", err)
+	}
+
+	table := subtable(doc, "gateway")
+	if profile != "" {
+		table = subtable(subtable(doc, profilesKey), profile)
+	}
+
+	table["ip"] = d.Host
+	table["model"] = d.Model
+
+	return writeTOML(path, doc)
+}
+
+// subtable returns the table at key of doc, creating it when missing.
+func subtable(doc map[string]any, key string) map[string]any {
+	table, ok := doc[key].(map[string]any)
+	if !ok {
+		table = map[string]any{}
+		doc[key] = table
+	}
+
+	return table
+}
+
+func displayDiscoveries(found []discovery) {
+	if len(found) == 0 {
+		pterm.Warning.Println("No gateway answered")
+
+		return
+	}
+
+	tableData := pterm.TableData{{"Host", "Model", "Found via", "Evidence"}}
+	for _, d := range found {
+		tableData = append(tableData,
+			[]string{d.Host, d.Model, d.Via, strings.Join(d.Evidence, "; ")})
+	}
+
+	pterm.Print(renderTable(tableData))
+}
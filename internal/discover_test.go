@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestParseProcNetRoute(t *testing.T) {
+	const table = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask
+eth0	000CA8C0	00000000	0001	0	0	0	00FFFFFF
+eth0	00000000	010CA8C0	0003	0	0	0	00000000
+`
+
+	gateway, err := parseProcNetRoute(strings.NewReader(table))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.12.1", gateway)
+
+	_, err = parseProcNetRoute(strings.NewReader(strings.SplitAfter(table, "\n")[0]))
+	require.ErrorIs(t, err, ErrNoDefaultRoute)
+}
+
+func TestParseRouteGet(t *testing.T) {
+	const out = `   route to: default
+destination: default
+       mask: default
+    gateway: 192.168.1.254
+  interface: en0
+`
+
+	gateway, err := parseRouteGet(out)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.254", gateway)
+
+	_, err = parseRouteGet("route: writing to routing socket: not in table\n")
+	require.ErrorIs(t, err, ErrNoDefaultRoute)
+}
+
+func TestScanHosts(t *testing.T) {
+	hosts, err := scanHosts("10.0.0.7/30")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.5", "10.0.0.6"}, hosts)
+
+	hosts, err = scanHosts("10.0.0.7/32")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.7"}, hosts)
+
+	hosts, err = scanHosts("10.0.0.0/20")
+	require.NoError(t, err)
+	assert.Len(t, hosts, 4094)
+
+	_, err = scanHosts("10.0.0.0/19")
+	require.ErrorIs(t, err, ErrCIDRTooLarge)
+
+	_, err = scanHosts("fd00::/120")
+	require.ErrorIs(t, err, ErrInvalidCIDR)
+
+	_, err = scanHosts("10.0.0.1")
+	require.ErrorIs(t, err, ErrInvalidCIDR)
+}
+
+func TestDiscoveryCandidates(t *testing.T) {
+	a := newTestApp(nil)
+	a.defaultGateway = func(context.Context) (string, error) { return defaultIP, nil }
+
+	candidates, err := a.discoveryCandidates(t.Context(), "10.0.0.0/30")
+	require.NoError(t, err)
+	assert.Equal(t, []discoveryCandidate{
+		{host: defaultIP, via: viaDefaultRoute},
+		{host: "10.0.0.1", via: viaScan},
+		{host: "10.0.0.2", via: viaScan},
+	}, candidates)
+
+	a.defaultGateway = func(context.Context) (string, error) { return "", ErrNoDefaultRoute }
+
+	candidates, err = a.discoveryCandidates(t.Context(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []discoveryCandidate{{host: defaultIP, via: viaWellKnown}}, candidates)
+}
+
+// runDiscover runs discover on a with the default route leading to gateway.
+func runDiscover(t *testing.T, a *app, gateway string, args ...string) error {
+	t.Helper()
+
+	a.config.Timeout = 200 * time.Millisecond
+	a.defaultGateway = func(context.Context) (string, error) { return gateway, nil }
+
+	cmd := &cli.Command{
+		Name: cmdDiscover,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: ConfigConfig, Value: filepath.Join(t.TempDir(), "config.toml")},
+			&cli.StringFlag{Name: ConfigCIDR},
+			&cli.BoolFlag{Name: ConfigSave},
+		},
+		Action: a.discover,
+	}
+
+	return cmd.Run(t.Context(), append([]string{cmdDiscover}, args...))
+}
+
+func TestDiscover_JSON(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	host := newFingerprintServer(t, nokiaNoncePath, `{"nonce":"abc"}`)
+
+	require.NoError(t, runDiscover(t, a, host))
+
+	ok, data, _ := decodeEnvelope(t, buf)
+	require.True(t, ok)
+
+	var found []discovery
+	require.NoError(t, json.Unmarshal(data, &found))
+	require.NotEmpty(t, found)
+	assert.Equal(t, host, found[0].Host)
+	assert.Equal(t, NOK5G21, found[0].Model)
+	assert.Equal(t, viaDefaultRoute, found[0].Via)
+}
+
+func TestDiscover_Save(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+[gateway]
+ip = "10.0.0.1"
+
+[login]
+password = "s3cret"
+`), 0o644))
+
+	a := newTestApp(nil)
+	host := newFingerprintServer(t, nokiaNoncePath, `{"nonce":"abc"}`)
+
+	require.NoError(t, runDiscover(t, a, host, "--save", "--config", path))
+
+	config, err := runWithConfig(t, readFile(t, path))
+	require.NoError(t, err)
+	assert.Equal(t, host, config.IP)
+	assert.Equal(t, NOK5G21, config.Model)
+	assert.Equal(t, "s3cret", config.Password)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(configFilePerm), info.Mode().Perm())
+}
+
+func TestDiscover_SaveNothingFound(t *testing.T) {
+	a := newTestApp(nil)
+	host := newFingerprintServer(t, "/elsewhere", "")
+
+	err := runDiscover(t, a, host, "--save")
+	require.ErrorIs(t, err, ErrNoGatewayFound)
+}
+
+func TestChooseDiscovery(t *testing.T) {
+	found := []discovery{
+		{detection: detection{Host: "10.0.0.1", Model: modelUnknown}},
+		{detection: detection{Host: "10.0.0.2", Model: ARCADYAN}},
+		{detection: detection{Host: "10.0.0.3", Model: NOK5G21}},
+	}
+
+	a := newTestApp(nil)
+	scriptAnswers(a, map[string]string{"Gateway to save": "10.0.0.3 (NOK5G21)"})
+
+	chosen, err := a.chooseDiscovery(t.Context(), found)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3", chosen.Host)
+
+	chosen, err = a.chooseDiscovery(t.Context(), found[:2])
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", chosen.Host, "a single known gateway needs no prompt")
+}
+
+func TestSaveGateway_Profile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(testProfilesConfig), 0o600))
+
+	require.NoError(t, saveGateway(path, "cabin",
+		discovery{detection: detection{Host: "10.0.0.9", Model: NOK5G21}}))
+
+	config, err := runWithConfig(t, readFile(t, path), "--profile", "cabin")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.9", config.IP)
+	assert.Equal(t, NOK5G21, config.Model)
+	assert.Equal(t, "cabin-secret", config.Password)
+
+	config, err = runWithConfig(t, readFile(t, path))
+	require.NoError(t, err)
+	assert.Equal(t, "home", config.Profile, "other settings are kept")
+}
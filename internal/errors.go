@@ -1,5 +1,98 @@
 package internal
 
+import "errors"
+
+// Exit codes of tmhi-cli. check is the exception: as a monitoring plugin,
+// it exits with 1 to 3 for its WARNING, CRITICAL and UNKNOWN states only,
+// any failure of its own being UNKNOWN.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	// ExitPartialFailure is returned when several gateways were queried and
+	// some or all of them failed, but not all for being unreachable.
+	ExitPartialFailure = 2
+	ExitInvalidConfig  = 10
+	ExitUnknownModel   = 11
+	ExitAuthFailed     = 12
+	ExitUnreachable    = 13
+	ExitRequestFailed  = 14
+	ExitCancelled      = 15
+	// ExitInterrupted and ExitTerminated are the shell's codes for a process
+	// stopped by SIGINT and SIGTERM.
+	ExitInterrupted = 130
+	ExitTerminated  = 143
+)
+
+var (
+	// ErrAuthFailed is returned when the gateway rejects the credentials.
+	ErrAuthFailed = errors.New("authentication failed")
+
+	// ErrGatewayUnreachable is returned when the gateway does not answer in
+	// time.
+	ErrGatewayUnreachable = errors.New("gateway unreachable")
+
+	// ErrRequestFailed is returned when the gateway answers the request of
+	// req with an error.
+	ErrRequestFailed = errors.New("request failed")
+
+	// ErrCancelled is returned when a confirmation is declined.
+	ErrCancelled = errors.New("cancelled")
+
+	// ErrInterrupted and ErrTerminated are returned when a command is
+	// cancelled by SIGINT or SIGTERM.
+	ErrInterrupted = errors.New("interrupted")
+	ErrTerminated  = errors.New("terminated")
+)
+
+// exitStatus is the exit code of an error, and its code in JSON errors.
+type exitStatus struct {
+	err  error
+	exit int
+	code string
+}
+
+// exitStatuses are tried in order, so a cause comes before the errors it
+// can lead to: a gateway that does not answer has no model to detect. The
+// states of check come first, for they wrap its failures.
+//
+//nolint:gochecknoglobals
+var exitStatuses = []exitStatus{
+	{ErrCheckWarning, checkWarning, "check_warning"},
+	{ErrCheckCritical, checkCritical, "check_critical"},
+	{ErrCheckUnknown, checkUnknown, "check_unknown"},
+	{ErrInterrupted, ExitInterrupted, "interrupted"},
+	{ErrTerminated, ExitTerminated, "terminated"},
+	{ErrPartialFailure, ExitPartialFailure, "partial_failure"},
+	{ErrGatewayUnreachable, ExitUnreachable, "unreachable"},
+	{ErrAllGatewaysFailed, ExitPartialFailure, "all_gateways_failed"},
+	{ErrAuthFailed, ExitAuthFailed, "auth_failed"},
+	{ErrRequestFailed, ExitRequestFailed, "request_failed"},
+	{ErrInvalidConfig, ExitInvalidConfig, "invalid_config"},
+	{ErrUnknownProfile, ExitInvalidConfig, "invalid_config"},
+	{ErrModelUndetected, ExitUnknownModel, "unknown_model"},
+	{errUnknownGateway, ExitUnknownModel, "unknown_model"},
+	{ErrCancelled, ExitCancelled, "cancelled"},
+}
+
+// ExitCode returns the exit code of a run that returned err.
+func ExitCode(err error) int {
+	return statusOf(err).exit
+}
+
+func statusOf(err error) exitStatus {
+	if err == nil {
+		return exitStatus{exit: ExitOK}
+	}
+
+	for _, status := range exitStatuses {
+		if errors.Is(err, status.err) {
+			return status
+		}
+	}
+
+	return exitStatus{exit: ExitFailure, code: "error"}
+}
+
 // displayedError wraps an error that has already been reported to the user,
 // so Cmd does not print it a second time.
 type displayedError struct{ err error }
@@ -16,3 +109,20 @@ func displayed(err error) error {
 
 	return &displayedError{err: err}
 }
+
+// documentedError wraps an error that the command already described in its
+// JSON document, so Cmd does not write a second one.
+type documentedError struct{ err error }
+
+func (e *documentedError) Error() string { return e.err.Error() }
+
+func (e *documentedError) Unwrap() error { return e.err }
+
+// documented marks err as already part of the command's JSON output.
+func documented(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &documentedError{err: err}
+}
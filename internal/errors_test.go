@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,3 +22,47 @@ func TestDisplayed(t *testing.T) {
 		assert.Equal(t, "boom", err.Error())
 	})
 }
+
+func TestDocumented(t *testing.T) {
+	require.NoError(t, documented(nil))
+
+	base := errors.New("boom")
+	err := documented(base)
+	require.ErrorIs(t, err, base)
+	assert.Equal(t, "boom", err.Error())
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"other failure", errors.New("boom"), ExitFailure},
+		{"partial failure", fmt.Errorf("%w: 1 of 2", ErrPartialFailure), ExitPartialFailure},
+		{"check critical", documented(ErrCheckCritical), checkCritical},
+		{"invalid config", displayed(fmt.Errorf("%w: no IP", ErrInvalidConfig)), ExitInvalidConfig},
+		{"unknown model", fmt.Errorf("%w: %q", errUnknownGateway, "x"), ExitUnknownModel},
+		{"undetected model", ErrModelUndetected, ExitUnknownModel},
+		{"auth failed", fmt.Errorf("failed to login: %w", ErrAuthFailed), ExitAuthFailed},
+		{"unreachable", fmt.Errorf("%w: %w", ErrGatewayUnreachable, ErrCancelled), ExitUnreachable},
+		{"request failed", ErrRequestFailed, ExitRequestFailed},
+		{"cancelled", displayed(fmt.Errorf("x %w", ErrCancelled)), ExitCancelled},
+		{"interrupted", fmt.Errorf("%w: %w", ErrInterrupted, context.Canceled), ExitInterrupted},
+		{"terminated", fmt.Errorf("%w: %w", ErrTerminated, context.Canceled), ExitTerminated},
+		{"unknown profile", fmt.Errorf("%w: %q", ErrUnknownProfile, "x"), ExitInvalidConfig},
+		{"all gateways failed", fmt.Errorf("%w (2)", ErrAllGatewaysFailed), ExitPartialFailure},
+		{
+			"check failure",
+			fmt.Errorf("%w: %w", ErrCheckUnknown, ErrTerminated),
+			checkUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ExitCode(tt.err))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Fields of the serving cell compared by cellTracker.
+const (
+	cellChangeNode    = "node_id"
+	cellChangeCID     = "cid"
+	cellChangeBands   = "bands"
+	cellChangeAntenna = "antenna"
+)
+
+// recentCellEvents is the number of events kept for display when watching.
+const recentCellEvents = 5
+
+// cell is the serving cell of a radio: the tower it is attached to, and how.
+type cell struct {
+	NodeID  int      `json:"node_id"`
+	CID     int      `json:"cid"`
+	Bands   []string `json:"bands"`
+	Antenna string   `json:"antenna,omitempty"`
+}
+
+// cellEvent reports that a radio changed cell, band or antenna between two
+// samples.
+type cellEvent struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	// Changes lists the fields that differ: node_id, cid, bands, antenna.
+	Changes []string `json:"changes"`
+	From    cell     `json:"from"`
+	To      cell     `json:"to"`
+	Message string   `json:"message"`
+}
+
+// cellTracker compares each radio's serving cell with the previous sample
+// of the same radio.
+type cellTracker struct {
+	last   map[string]cell
+	recent []cellEvent
+}
+
+func newCellTracker() *cellTracker {
+	return &cellTracker{last: make(map[string]cell)}
+}
+
+// track returns an event for each radio of samples whose cell changed since
+// it was last seen. A radio seen for the first time raises no event.
+func (t *cellTracker) track(samples []signalSample) []cellEvent {
+	var events []cellEvent
+
+	for _, sample := range samples {
+		current := cell{
+			NodeID:  sample.NodeID,
+			CID:     sample.CID,
+			Bands:   sample.Bands,
+			Antenna: sample.Antenna,
+		}
+
+		previous, seen := t.last[sample.Network]
+		t.last[sample.Network] = current
+
+		if !seen {
+			continue
+		}
+
+		if changes := cellChanges(previous, current); len(changes) > 0 {
+			events = append(events,
+				newCellEvent(sample.Time, sample.Network, changes, previous, current))
+		}
+	}
+
+	t.recent = append(t.recent, events...)
+	if len(t.recent) > recentCellEvents {
+		t.recent = t.recent[len(t.recent)-recentCellEvents:]
+	}
+
+	return events
+}
+
+func cellChanges(from, to cell) []string {
+	var changes []string
+
+	if from.NodeID != to.NodeID {
+		changes = append(changes, cellChangeNode)
+	}
+
+	if from.CID != to.CID {
+		changes = append(changes, cellChangeCID)
+	}
+
+	if !slices.Equal(from.Bands, to.Bands) {
+		changes = append(changes, cellChangeBands)
+	}
+
+	if from.Antenna != to.Antenna {
+		changes = append(changes, cellChangeAntenna)
+	}
+
+	return changes
+}
+
+func newCellEvent(at time.Time, network string, changes []string, from, to cell) cellEvent {
+	verb := "switched"
+	if slices.Contains(changes, cellChangeNode) || slices.Contains(changes, cellChangeCID) {
+		verb = "moved"
+	}
+
+	return cellEvent{
+		Time:    at,
+		Network: network,
+		Changes: changes,
+		From:    from,
+		To:      to,
+		Message: strings.ToUpper(network) + " " + verb + " from " +
+			describeCell(network, from) + " to " + describeCell(network, to),
+	}
+}
+
+// describeCell returns e.g. "gNB 123 CID 4 n41 n71".
+func describeCell(network string, c cell) string {
+	node := "eNB"
+	if network == network5G {
+		node = "gNB"
+	}
+
+	parts := []string{node, strconv.Itoa(c.NodeID), "CID", strconv.Itoa(c.CID)}
+	parts = append(parts, c.Bands...)
+
+	if c.Antenna != "" {
+		parts = append(parts, "antenna "+c.Antenna)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// String returns the event as a log line.
+func (e cellEvent) String() string {
+	return e.Time.Local().Format(time.DateTime) + " " + e.Message
+}
+
+// renderCellEvents lists the latest events under the watched signal.
+func renderCellEvents(events []cellEvent) string {
+	if len(events) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+
+	out.WriteString(pterm.DefaultSection.Sprint("Cell changes"))
+
+	for _, event := range slices.Backward(events) {
+		out.WriteString(pterm.Info.Sprintln(event.String()))
+	}
+
+	return out.String()
+}
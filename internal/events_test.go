@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// cellResult returns the test signal served by gNB on CID with bands.
+func cellResult(gnb, cid int, bands ...string) *tmhi.SignalResult {
+	result := testSignalResult()
+	result.FiveG.GNBID = gnb
+	result.FiveG.CID = cid
+	result.FiveG.Bands = bands
+
+	return result
+}
+
+func TestCellTracker(t *testing.T) {
+	tracker := newCellTracker()
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	track := func(result *tmhi.SignalResult) []cellEvent {
+		at = at.Add(time.Minute)
+
+		return tracker.track(samplesFromSignal(at, result))
+	}
+
+	assert.Empty(t, track(cellResult(123, 1, "n41")), "first sample")
+	assert.Empty(t, track(cellResult(123, 1, "n41")), "same cell")
+
+	events := track(cellResult(456, 2, "n71"))
+	require.Len(t, events, 1)
+	assert.Equal(t, cellEvent{
+		Time:    at,
+		Network: network5G,
+		Changes: []string{cellChangeNode, cellChangeCID, cellChangeBands},
+		From:    cell{NodeID: 123, CID: 1, Bands: []string{"n41"}},
+		To:      cell{NodeID: 456, CID: 2, Bands: []string{"n71"}},
+		Message: "5G moved from gNB 123 CID 1 n41 to gNB 456 CID 2 n71",
+	}, events[0])
+
+	result := cellResult(456, 2, "n71", "n41")
+	result.FiveG.AntennaUsed = "external"
+	events = track(result)
+	require.Len(t, events, 1)
+	assert.Equal(t, []string{cellChangeBands, cellChangeAntenna}, events[0].Changes)
+	assert.Equal(t,
+		"5G switched from gNB 456 CID 2 n71 to gNB 456 CID 2 n71 n41 antenna external",
+		events[0].Message)
+
+	// A 4G radio showing up is not a change; its own moves are.
+	result.FourG = &tmhi.FourGSignal{ENBID: 7, SignalData: tmhi.SignalData{CID: 3}}
+	assert.Empty(t, track(result))
+
+	result.FourG.ENBID = 8
+	events = track(result)
+	require.Len(t, events, 1)
+	assert.Equal(t, "4G moved from eNB 7 CID 3 to eNB 8 CID 3", events[0].Message)
+}
+
+func TestCellTracker_Recent(t *testing.T) {
+	tracker := newCellTracker()
+
+	for gnb := range recentCellEvents + 3 {
+		tracker.track(samplesFromSignal(time.Now(), cellResult(gnb, 1)))
+	}
+
+	require.Len(t, tracker.recent, recentCellEvents)
+	assert.Equal(t, recentCellEvents+2, tracker.recent[recentCellEvents-1].To.NodeID)
+}
+
+func TestRenderCellEvents(t *testing.T) {
+	assert.Empty(t, renderCellEvents(nil))
+
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	out := pterm.RemoveColorFromString(renderCellEvents([]cellEvent{
+		{Time: at, Message: "5G moved from A to B"},
+		{Time: at.Add(time.Minute), Message: "5G moved from B to C"},
+	}))
+
+	assert.Contains(t, out, "Cell changes")
+	assert.Less(t,
+		strings.Index(out, "2026-10-01 12:01:00 5G moved from B to C"),
+		strings.Index(out, "2026-10-01 12:00:00 5G moved from A to B"),
+		"latest first")
+}
+
+func runSignalWatch(t *testing.T, a *app, results ...*tmhi.SignalResult) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	gw := &sequenceGateway{mockGateway: &mockGateway{}, cancel: cancel, results: results}
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) { return gw, nil }
+
+	cmd := &cli.Command{Name: cmdSignal, Flags: []cli.Flag{watchFlag()}, Action: a.signal}
+	require.NoError(t, cmd.Run(ctx, []string{cmdSignal, "--watch", testWatchInterval.String()}))
+}
+
+func TestSignal_WatchCellEvents(t *testing.T) {
+	a := newTestApp(nil)
+	liveArea := &recordingArea{}
+	a.newArea = func() (area, error) { return liveArea, nil }
+
+	runSignalWatch(t, a, cellResult(123, 1, "n41"), cellResult(456, 1, "n41"))
+
+	require.Len(t, liveArea.updates, 2)
+	assert.NotContains(t, liveArea.updates[0], "Cell changes")
+	assert.Contains(t, pterm.RemoveColorFromString(liveArea.updates[1]),
+		"5G moved from gNB 123 CID 1 n41 to gNB 456 CID 1 n41")
+}
+
+func TestSignal_WatchCellEventsJSON(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	runSignalWatch(t, a, cellResult(123, 1, "n41"), cellResult(123, 1, "n71"))
+
+	dec := json.NewDecoder(buf)
+
+	for i, want := range []int{0, 1} {
+		var env struct {
+			Data signalJSON `json:"data"`
+		}
+
+		require.NoError(t, dec.Decode(&env))
+		require.Len(t, env.Data.Events, want, fmt.Sprint("sample ", i))
+	}
+
+	assert.False(t, dec.More())
+}
@@ -0,0 +1,277 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultExporterListen = ":9877"
+	metricsPath           = "/metrics"
+	metricsContentType    = "text/plain; version=0.0.4; charset=utf-8"
+	exporterReadTimeout   = 10 * time.Second
+	metricPrefix          = "tmhi_"
+)
+
+// metricsCollector scrapes the gateway and renders the Prometheus text
+// exposition format. Scrapes are serialized since a gateway session is not
+// meant to be shared by concurrent requests.
+type metricsCollector struct {
+	gateway  tmhi.Gateway
+	cacheFor time.Duration
+	now      func() time.Time
+
+	mu           sync.Mutex
+	cached       string
+	cachedAt     time.Time
+	scrapes      int
+	scrapeErrors map[string]int
+}
+
+func newMetricsCollector(gateway tmhi.Gateway, cacheFor time.Duration) *metricsCollector {
+	return &metricsCollector{
+		gateway:      gateway,
+		cacheFor:     cacheFor,
+		now:          time.Now,
+		scrapeErrors: map[string]int{cmdSignal: 0, cmdStatus: 0},
+	}
+}
+
+func (c *metricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := c.collect(r.Context())
+
+	w.Header().Set("Content-Type", metricsContentType)
+	_, _ = w.Write([]byte(body))
+}
+
+// collect returns the exposition of a fresh scrape, or of the last one while
+// it is younger than cacheFor.
+func (c *metricsCollector) collect(ctx context.Context) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != "" && c.now().Sub(c.cachedAt) < c.cacheFor {
+		return c.cached
+	}
+
+	start := c.now()
+	metrics := newMetricsWriter()
+
+	signalResult, err := c.gateway.Signal(ctx)
+	if err != nil {
+		pterm.Debug.Println("signal scrape failed:", err)
+
+		c.scrapeErrors[cmdSignal]++
+	} else {
+		writeSignalMetrics(metrics, signalResult)
+	}
+
+	statusResult, err := c.gateway.Status(ctx)
+	if err != nil {
+		pterm.Debug.Println("status scrape failed:", err)
+
+		c.scrapeErrors[cmdStatus]++
+	} else {
+		writeStatusMetrics(metrics, statusResult, signalResult)
+	}
+
+	c.scrapes++
+
+	metrics.add("scrapes_total", "counter", "Number of gateway scrapes.", nil, float64(c.scrapes))
+
+	for _, operation := range []string{cmdSignal, cmdStatus} {
+		metrics.add("scrape_errors_total", "counter", "Number of failed gateway scrapes.",
+			labels{"operation": operation}, float64(c.scrapeErrors[operation]))
+	}
+
+	metrics.add("scrape_duration_seconds", "gauge", "Duration of the last gateway scrape.",
+		nil, c.now().Sub(start).Seconds())
+
+	c.cached = metrics.String()
+	c.cachedAt = c.now()
+
+	return c.cached
+}
+
+func writeSignalMetrics(metrics *metricsWriter, result *tmhi.SignalResult) {
+	if result.FourG != nil {
+		writeCellMetrics(metrics, network4G, strconv.Itoa(result.FourG.ENBID),
+			&result.FourG.SignalData)
+	}
+
+	if result.FiveG != nil {
+		writeCellMetrics(metrics, network5G, strconv.Itoa(result.FiveG.GNBID),
+			&result.FiveG.SignalData)
+	}
+}
+
+// writeCellMetrics writes the metrics of one radio. nodeID is the eNB ID for
+// 4G and the gNB ID for 5G.
+func writeCellMetrics(metrics *metricsWriter, network, nodeID string, data *tmhi.SignalData) {
+	cellLabels := labels{
+		"network": network,
+		"band":    strings.Join(data.Bands, ","),
+		"cid":     strconv.Itoa(data.CID),
+		"node_id": nodeID,
+	}
+
+	metrics.add("signal_bars", "gauge", "Signal bars shown by the gateway.", cellLabels, data.Bars)
+
+	for _, metric := range rateSignalData(data) {
+		unit := metric.rating.Metric.Unit()
+		name := "signal_" + strings.ToLower(metric.name)
+		help := metric.name + "."
+
+		if unit != "" {
+			name += "_" + strings.ToLower(unit)
+			help = metric.name + " in " + unit + "."
+		}
+
+		metrics.add(name, "gauge", help, cellLabels, metric.rating.Value)
+	}
+}
+
+func writeStatusMetrics(
+	metrics *metricsWriter,
+	status *tmhi.StatusResult,
+	signalResult *tmhi.SignalResult,
+) {
+	up := 0.0
+	if status.WebInterfaceUp {
+		up = 1
+	}
+
+	metrics.add("web_interface_up", "gauge", "Whether the gateway web interface is up.", nil, up)
+
+	registration := status.Registration
+	if registration == "" && signalResult != nil {
+		registration = signalResult.Generic.Registration
+	}
+
+	if registration != "" {
+		metrics.add("registration_info", "gauge", "Network registration state.",
+			labels{"state": registration}, 1)
+	}
+}
+
+type labels map[string]string
+
+//nolint:gochecknoglobals
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter accumulates samples and writes them grouped by family, each
+// preceded by its HELP and TYPE lines.
+type metricsWriter struct {
+	families []string
+	help     map[string]string
+	samples  map[string][]string
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{help: map[string]string{}, samples: map[string][]string{}}
+}
+
+func (m *metricsWriter) add(name, kind, help string, sampleLabels labels, value float64) {
+	name = metricPrefix + name
+	if _, ok := m.help[name]; !ok {
+		m.families = append(m.families, name)
+		m.help[name] = fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	m.samples[name] = append(m.samples[name],
+		name+formatLabels(sampleLabels)+" "+strconv.FormatFloat(value, 'g', -1, 64)+"\n")
+}
+
+func (m *metricsWriter) String() string {
+	var out strings.Builder
+
+	for _, name := range m.families {
+		out.WriteString(m.help[name])
+
+		for _, sample := range m.samples[name] {
+			out.WriteString(sample)
+		}
+	}
+
+	return out.String()
+}
+
+func formatLabels(sampleLabels labels) string {
+	if len(sampleLabels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(sampleLabels))
+	for name := range sampleLabels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(sampleLabels[name])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// serveUntilDone serves handler on listener until ctx is cancelled, then
+// shuts the server down gracefully.
+func serveUntilDone(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: exporterReadTimeout}
+
+	errCh := make(chan error, 1)
+
+	go func() { errCh <- server.Serve(listener) }()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exporterReadTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
+	}
+
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+
+	return nil
+}
+
+func (a *app) exporter(ctx context.Context, cmd *cli.Command) error {
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", cmd.String(ConfigListen))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, newMetricsCollector(gateway, cmd.Duration(ConfigCache)))
+
+	pterm.Info.Printfln("Serving metrics on http://%s%s", listener.Addr(), metricsPath)
+
+	return serveUntilDone(ctx, listener, mux)
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSignalResult() *tmhi.SignalResult {
+	return &tmhi.SignalResult{
+		FiveG: &tmhi.FiveGSignal{
+			GNBID: 67890,
+			SignalData: tmhi.SignalData{
+				Bars:  4,
+				Bands: []string{"n41", "n71"},
+				RSRP:  -95,
+				RSRQ:  -9,
+				RSSI:  -65,
+				SINR:  12,
+				CID:   2001,
+			},
+		},
+		Generic: tmhi.GenericSignalInfo{Registration: testRegState},
+	}
+}
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, metricsPath, nil)
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metricsContentType, rec.Header().Get("Content-Type"))
+
+	return rec.Body.String()
+}
+
+func TestMetricsCollector_Exposition(t *testing.T) {
+	collector := newMetricsCollector(&mockGateway{signalResult: testSignalResult()}, 0)
+
+	body := scrape(t, collector)
+
+	cell := `{band="n41,n71",cid="2001",network="5g",node_id="67890"}`
+	for _, want := range []string{
+		"# TYPE tmhi_signal_bars gauge\n",
+		"tmhi_signal_bars" + cell + " 4\n",
+		"tmhi_signal_sinr_db" + cell + " 12\n",
+		"tmhi_web_interface_up 1\n",
+		`tmhi_registration_info{state="registered"} 1` + "\n",
+		"tmhi_scrapes_total 1\n",
+		`tmhi_scrape_errors_total{operation="signal"} 0` + "\n",
+		"# TYPE tmhi_scrape_duration_seconds gauge\n",
+	} {
+		assert.Contains(t, body, want)
+	}
+
+	assert.NotContains(t, body, `network="4g"`)
+}
+
+func TestMetricsCollector_CountsErrors(t *testing.T) {
+	collector := newMetricsCollector(&mockGateway{signalErr: errors.New("down")}, 0)
+
+	scrape(t, collector)
+	body := scrape(t, collector)
+
+	assert.Contains(t, body, `tmhi_scrape_errors_total{operation="signal"} 2`)
+	assert.Contains(t, body, `tmhi_scrape_errors_total{operation="status"} 0`)
+	assert.Contains(t, body, "tmhi_scrapes_total 2\n")
+	assert.NotContains(t, body, "tmhi_signal_")
+}
+
+func TestMetricsCollector_Cache(t *testing.T) {
+	now := time.Now()
+	collector := newMetricsCollector(&mockGateway{}, time.Minute)
+	collector.now = func() time.Time { return now }
+
+	scrape(t, collector)
+	assert.Contains(t, scrape(t, collector), "tmhi_scrapes_total 1\n", "second scrape is cached")
+
+	now = now.Add(2 * time.Minute)
+
+	assert.Contains(t, scrape(t, collector), "tmhi_scrapes_total 2\n", "cache expired")
+}
+
+func TestFormatLabels_Escapes(t *testing.T) {
+	assert.Empty(t, formatLabels(nil))
+	assert.Equal(t, `{a="x\"y\\z\n",b="2"}`, formatLabels(labels{"b": "2", "a": "x\"y\\z\n"}))
+}
+
+func TestServeUntilDone(t *testing.T) {
+	listener, err := (&net.ListenConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- serveUntilDone(ctx, listener, newMetricsCollector(&mockGateway{}, 0))
+	}()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet,
+		"http://"+listener.Addr().String()+metricsPath, nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Contains(t, string(body), "tmhi_web_interface_up")
+
+	http.DefaultClient.CloseIdleConnections()
+	cancel()
+	require.NoError(t, <-errCh)
+}
@@ -0,0 +1,400 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	burntsushi "github.com/BurntSushi/toml"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	// ErrNoProfiles is returned when a fan-out file has no profile to query.
+	ErrNoProfiles = errors.New("no [profiles.<name>] section found")
+
+	// ErrFanOutWatch is returned when --watch is combined with a fan-out flag.
+	ErrFanOutWatch = errors.New("--watch cannot be combined with --all-profiles or --inventory")
+
+	// ErrPartialFailure is returned when a fan-out command failed for some of
+	// the gateways only.
+	ErrPartialFailure = errors.New("some gateways failed")
+
+	// ErrAllGatewaysFailed is returned when a fan-out command failed for every
+	// gateway.
+	ErrAllGatewaysFailed = errors.New("all gateways failed")
+
+	// ErrProfileDir is returned when --record or --replay fans out to a
+	// profile whose name cannot be a directory name.
+	ErrProfileDir = errors.New("profile name cannot name a recording directory")
+)
+
+// gatewayTarget is one gateway queried by a fan-out command.
+type gatewayTarget struct {
+	name   string
+	config *Config
+}
+
+// gatewayResult is the outcome of querying one gateway.
+type gatewayResult[T any] struct {
+	name   string
+	result T
+	err    error
+}
+
+// gatewayJSON is the JSON document of one gateway in fan-out output.
+type gatewayJSON struct {
+	Gateway string     `json:"gateway"`
+	OK      bool       `json:"ok"`
+	Data    any        `json:"data,omitempty"`
+	Error   *jsonError `json:"error,omitempty"`
+}
+
+// fanOutRequested reports whether cmd was asked to query several gateways.
+func fanOutRequested(cmd *cli.Command) bool {
+	return cmd != nil && (cmd.Bool(ConfigAllProfiles) || cmd.String(ConfigInventory) != "")
+}
+
+// profileNames returns the names of the [profiles.<name>] sections of the
+// TOML file at path, sorted.
+func profileNames(path string) ([]string, error) {
+	var doc struct {
+		Profiles map[string]any `toml:"profiles"`
+	}
+
+	if _, err := burntsushi.DecodeFile(path, &doc); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if len(doc.Profiles) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoProfiles, path)
+	}
+
+	return slices.Sorted(maps.Keys(doc.Profiles)), nil
+}
+
+// profileConfig resolves the settings of the named profile from source the
+// same way the global flags do, the command line aside. Output and safety
+// settings are taken from the current invocation.
+func (a *app) profileConfig(name string, source altsrc.Sourcer) (*Config, error) {
+	config := &Config{
+		Profile:  name,
+		IP:       defaultIP,
+		Username: defaultUser,
+		Timeout:  DefaultTimeout,
+		Output:   a.config.Output,
+		Debug:    a.config.Debug,
+		DryRun:   a.config.DryRun,
+	}
+
+	// Each profile records to, and replays from, a directory of its own.
+	if a.config.Record != "" || a.config.Replay != "" {
+		if !filepath.IsLocal(name) {
+			return nil, fmt.Errorf("%w: %q", ErrProfileDir, name)
+		}
+
+		config.Record = profileDir(a.config.Record, name)
+		config.Replay = profileDir(a.config.Replay, name)
+	}
+
+	settings := []struct {
+		key, profileKey string
+		set             func(string) error
+	}{
+		{ConfigModel, "model", func(v string) error { config.Model = v; return nil }},
+		{ConfigIP, "ip", func(v string) error { config.IP = v; return nil }},
+		{ConfigUsername, "username", func(v string) error { config.Username = v; return nil }},
+		{ConfigPassword, "password", func(v string) error { config.Password = v; return nil }},
+		{passwordFileKey, "password_file", func(v string) error {
+			config.PasswordFile = v
+
+			return nil
+		}},
+		{passwordCommandKey, "password_command", func(v string) error {
+			config.PasswordCommand = v
+
+			return nil
+		}},
+		{ConfigTimeout, "timeout", func(v string) (err error) {
+			config.Timeout, err = time.ParseDuration(v)
+
+			return err //nolint:wrapcheck
+		}},
+		{ConfigRetries, "retries", func(v string) (err error) {
+			config.Retries, err = strconv.Atoi(v)
+
+			return err //nolint:wrapcheck
+		}},
+	}
+
+	for _, setting := range settings {
+		sources := settingSources(setting.key, setting.profileKey, &name, source)
+
+		value, ok := sources.Lookup()
+		if !ok {
+			continue
+		}
+
+		if err := setting.set(value); err != nil {
+			return nil, fmt.Errorf("profile %q: invalid %s: %w", name, setting.profileKey, err)
+		}
+	}
+
+	return config, nil
+}
+
+// profileDir returns the subdirectory of dir for the named profile, or "" when
+// dir is unset.
+func profileDir(dir, name string) string {
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// fanOutTargets lists the profiles of the inventory file, or of the
+// configuration file with --all-profiles.
+func (a *app) fanOutTargets(cmd *cli.Command) ([]gatewayTarget, error) {
+	path := cmd.String(ConfigInventory)
+	if path == "" {
+		path = cmd.String(ConfigConfig)
+	}
+
+	names, err := profileNames(path)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]gatewayTarget, 0, len(names))
+
+	for _, name := range names {
+		config, err := a.profileConfig(name, altsrc.StringSourcer(path))
+		if err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, gatewayTarget{name: name, config: config})
+	}
+
+	return targets, nil
+}
+
+// queryGateways runs fetch against every target concurrently. Results are in
+// the order of targets.
+func queryGateways[T any](
+	ctx context.Context,
+	initGateway func(context.Context, *Config) (tmhi.Gateway, error),
+	targets []gatewayTarget,
+	fetch func(context.Context, tmhi.Gateway) (T, error),
+) []gatewayResult[T] {
+	results := make([]gatewayResult[T], len(targets))
+
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Go(func() {
+			results[i].name = target.name
+
+			gateway, err := initGateway(ctx, target.config)
+			if err != nil {
+				results[i].err = err
+
+				return
+			}
+
+			results[i].result, results[i].err = fetch(ctx, gateway)
+		})
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// Gateway operations in the form queryGateways calls them.
+
+func fetchInfo(ctx context.Context, g tmhi.Gateway) (*tmhi.InfoResult, error) {
+	return g.Info(ctx)
+}
+
+func fetchStatus(ctx context.Context, g tmhi.Gateway) (*tmhi.StatusResult, error) {
+	return g.Status(ctx)
+}
+
+func fetchSignal(ctx context.Context, g tmhi.Gateway) (*tmhi.SignalResult, error) {
+	return g.Signal(ctx)
+}
+
+// fanOutFailure summarizes the failed gateways in results, if any. When
+// none of them could be reached, it is ErrGatewayUnreachable too.
+func fanOutFailure[T any](results []gatewayResult[T]) error {
+	failed, unreachable := 0, 0
+
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+
+		if errors.Is(r.err, ErrGatewayUnreachable) {
+			unreachable++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return nil
+	case unreachable == len(results):
+		return fmt.Errorf("%w (%d): %w", ErrAllGatewaysFailed, failed, ErrGatewayUnreachable)
+	case failed == len(results):
+		return fmt.Errorf("%w (%d)", ErrAllGatewaysFailed, failed)
+	default:
+		return fmt.Errorf("%w: %d of %d", ErrPartialFailure, failed, len(results))
+	}
+}
+
+// fanOut queries every gateway selected by the fan-out flags and presents the
+// results together: render's combined output in text mode, one document per
+// gateway in JSON mode. A failing gateway does not stop the others.
+func fanOut[T any](
+	ctx context.Context,
+	a *app,
+	cmd *cli.Command,
+	fetch func(context.Context, tmhi.Gateway) (T, error),
+	render func([]gatewayResult[T]) string,
+	toJSON func(T) any,
+) error {
+	if watchInterval(cmd) > 0 {
+		return ErrFanOutWatch
+	}
+
+	targets, err := a.fanOutTargets(cmd)
+	if err != nil {
+		return err
+	}
+
+	spinnerInstance, err := a.newSpinner(fmt.Sprintf("Querying %d gateways...", len(targets)))
+	if err != nil {
+		return err
+	}
+
+	results := queryGateways(ctx, a.initGateway, targets, fetch)
+	failure := fanOutFailure(results)
+
+	spinnerInstance.Success()
+
+	if !a.jsonOutput() {
+		pterm.Print(a.secrets.redact(render(results)))
+
+		return failure
+	}
+
+	docs := make([]gatewayJSON, len(results))
+
+	for i, r := range results {
+		docs[i] = gatewayJSON{Gateway: r.name, OK: r.err == nil}
+		if r.err != nil {
+			docs[i].Error = a.jsonErrorOf(r.err)
+		} else {
+			docs[i].Data = toJSON(r.result)
+		}
+	}
+
+	envelope := jsonEnvelope{OK: failure == nil, Data: docs}
+	if failure != nil {
+		envelope.Error = a.jsonErrorOf(failure)
+	}
+
+	if err := a.encodeJSON(envelope); err != nil {
+		return err
+	}
+
+	return documented(failure)
+}
+
+func renderStatusResults(results []gatewayResult[*tmhi.StatusResult]) string {
+	tableData := pterm.TableData{{"Gateway", "Web interface", "Registration", "Error"}}
+
+	for _, r := range results {
+		if r.err != nil {
+			tableData = append(tableData, []string{r.name, "", "", r.err.Error()})
+
+			continue
+		}
+
+		row := []string{r.name, "up", r.result.Registration, ""}
+
+		switch {
+		case r.result.WebInterfaceUp:
+		case r.result.Error != nil:
+			row[1] = "down"
+			row[3] = r.result.Error.Error()
+		default:
+			row[1] = fmt.Sprintf("down (status %d)", r.result.StatusCode)
+		}
+
+		tableData = append(tableData, row)
+	}
+
+	return renderTable(tableData)
+}
+
+func renderSignalResults(results []gatewayResult[*tmhi.SignalResult]) string {
+	tableData := pterm.TableData{{
+		"Gateway", "Network", "Bands", "Bars", "RSRP", "RSRQ", "RSSI", "SINR", "CID", "Error",
+	}}
+
+	for _, r := range results {
+		if r.err != nil {
+			tableData = append(tableData,
+				[]string{r.name, "", "", "", "", "", "", "", "", r.err.Error()})
+
+			continue
+		}
+
+		for _, sample := range samplesFromSignal(time.Time{}, r.result) {
+			tableData = append(tableData, []string{
+				r.name,
+				sample.Network,
+				strings.Join(sample.Bands, ", "),
+				strconv.FormatFloat(sample.Bars, 'f', -1, 64),
+				strconv.Itoa(sample.RSRP),
+				strconv.Itoa(sample.RSRQ),
+				strconv.Itoa(sample.RSSI),
+				strconv.Itoa(sample.SINR),
+				strconv.Itoa(sample.CID),
+				"",
+			})
+		}
+	}
+
+	return renderTable(tableData)
+}
+
+func renderInfoResults(results []gatewayResult[*tmhi.InfoResult]) string {
+	var out strings.Builder
+
+	for _, r := range results {
+		out.WriteString(pterm.DefaultSection.Sprint(r.name))
+
+		if r.err != nil {
+			out.WriteString(pterm.Error.Sprintln(r.err))
+
+			continue
+		}
+
+		out.WriteString(pterm.DefaultBasicText.Sprintln(r.result.String()))
+	}
+
+	return out.String()
+}
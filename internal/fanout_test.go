@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+)
+
+const testInventory = `
+timeout = "7s"
+
+[profiles.home]
+model = "NOK5G21"
+password = "home-secret"
+
+[profiles.cabin]
+model = "ARCADYAN"
+ip = "10.0.0.1"
+retries = 2
+
+[profiles.office]
+model = "NOK5G21"
+ip = "10.0.1.1"
+`
+
+var errGatewayDown = errors.New("gateway down")
+
+func writeInventory(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "inventory.toml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	return path
+}
+
+// newFanOutApp returns a test app whose gateways are picked by profile name;
+// profiles missing from gateways fail to initialize.
+func newFanOutApp(gateways map[string]tmhi.Gateway) *app {
+	a := newTestApp(nil)
+	a.initGateway = func(ctx context.Context, cfg *Config) (tmhi.Gateway, error) {
+		if gw, ok := gateways[cfg.Profile]; ok {
+			return gw, nil
+		}
+
+		return nil, errGatewayDown
+	}
+
+	return a
+}
+
+func runFanOut(t *testing.T, a *app, name string, action cli.ActionFunc, args ...string) error {
+	t.Helper()
+
+	cmd := &cli.Command{Name: name, Flags: append(fanOutFlags(), watchFlag()), Action: action}
+
+	return cmd.Run(t.Context(), append([]string{name}, args...))
+}
+
+func TestProfileNames(t *testing.T) {
+	names, err := profileNames(writeInventory(t, testInventory))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cabin", "home", "office"}, names)
+
+	_, err = profileNames(writeInventory(t, "timeout = \"7s\"\n"))
+	require.ErrorIs(t, err, ErrNoProfiles)
+
+	_, err = profileNames(filepath.Join(t.TempDir(), "missing.toml"))
+	require.Error(t, err)
+}
+
+func TestProfileConfig(t *testing.T) {
+	a := newApp()
+	a.config.DryRun = true
+	source := altsrc.StringSourcer(writeInventory(t, testInventory))
+
+	config, err := a.profileConfig("cabin", source)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		Profile:  "cabin",
+		Model:    ARCADYAN,
+		IP:       "10.0.0.1",
+		Username: defaultUser,
+		Timeout:  7 * time.Second,
+		Retries:  2,
+		DryRun:   true,
+	}, config)
+
+	config, err = a.profileConfig("home", source)
+	require.NoError(t, err)
+	assert.Equal(t, defaultIP, config.IP)
+	assert.Equal(t, "home-secret", config.Password)
+
+	_, err = a.profileConfig("bad", altsrc.StringSourcer(
+		writeInventory(t, "[profiles.bad]\ntimeout = \"soon\"\n")))
+	require.ErrorContains(t, err, `profile "bad": invalid timeout`)
+
+	a.config.Record = "trace"
+	config, err = a.profileConfig("home", source)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("trace", "home"), config.Record)
+	assert.Empty(t, config.Replay)
+
+	_, err = a.profileConfig("..", altsrc.StringSourcer(
+		writeInventory(t, "[profiles.\"..\"]\nip = \"10.0.0.1\"\n")))
+	require.ErrorIs(t, err, ErrProfileDir)
+}
+
+func TestFanOut_RecordsEachProfile(t *testing.T) {
+	_, arcadyan := simulator.Start(t, simulator.Options{Model: simulator.ModelArcadyan})
+	_, nokia := simulator.Start(t, simulator.Options{Model: simulator.ModelNokia})
+	inventory := writeInventory(t, fmt.Sprintf(
+		"[profiles.attic]\nip = %q\npassword = \"x\"\n\n"+
+			"[profiles.cellar]\nip = %q\npassword = \"x\"\n", arcadyan, nokia))
+	dir := t.TempDir()
+
+	models := func(set func(*Config)) map[string]string {
+		a := newApp()
+		set(a.config)
+		t.Cleanup(a.taps.close)
+
+		cmd := &cli.Command{Flags: fanOutFlags()}
+		require.NoError(t, cmd.Set(ConfigInventory, inventory))
+
+		targets, err := a.fanOutTargets(cmd)
+		require.NoError(t, err)
+
+		results := queryGateways(t.Context(), a.gatewayOpener("test"), targets,
+			func(context.Context, tmhi.Gateway) (struct{}, error) { return struct{}{}, nil })
+
+		detected := map[string]string{}
+		for i, target := range targets {
+			require.NoError(t, results[i].err)
+			detected[target.name] = target.config.Model
+		}
+
+		return detected
+	}
+
+	want := map[string]string{"attic": ARCADYAN, "cellar": NOK5G21}
+	assert.Equal(t, want, models(func(c *Config) { c.Record = dir }))
+
+	for name := range want {
+		files, err := trafficFiles(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.NotEmpty(t, files, name)
+	}
+
+	assert.Equal(t, want, models(func(c *Config) { c.Replay = dir }),
+		"each profile replays its own gateway")
+}
+
+func TestFanOutRequested(t *testing.T) {
+	assert.False(t, fanOutRequested(nil))
+	assert.False(t, fanOutRequested(&cli.Command{}))
+}
+
+func TestFanOut_StatusJSONPartialFailure(t *testing.T) {
+	a := newFanOutApp(map[string]tmhi.Gateway{
+		"home":   &mockGateway{},
+		"office": &mockGateway{statusErr: fmt.Errorf("%w: %w", ErrAuthFailed, errGatewayDown)},
+	})
+	a.config.Output = outputJSON
+
+	var buf bytes.Buffer
+
+	a.stdout = &buf
+
+	err := runFanOut(t, a, cmdStatus, a.status, "--inventory", writeInventory(t, testInventory))
+	require.ErrorIs(t, err, ErrPartialFailure)
+
+	_, ok := errors.AsType[*documentedError](err)
+	assert.True(t, ok, "the JSON document already describes the failure")
+
+	ok, data, jsonErr := decodeEnvelope(t, &buf)
+	assert.False(t, ok)
+	require.NotNil(t, jsonErr)
+	assert.Equal(t, "some gateways failed: 2 of 3", jsonErr.Message)
+	assert.Equal(t, "partial_failure", jsonErr.Code)
+	assert.Equal(t, ExitPartialFailure, jsonErr.ExitCode)
+
+	var docs []struct {
+		Gateway string          `json:"gateway"`
+		OK      bool            `json:"ok"`
+		Data    json.RawMessage `json:"data"`
+		Error   *jsonError      `json:"error"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &docs))
+	require.Len(t, docs, 3)
+	assert.Equal(t, "cabin", docs[0].Gateway)
+	assert.False(t, docs[0].OK)
+	assert.Equal(t, &jsonError{
+		Message: errGatewayDown.Error(), Code: "error", ExitCode: ExitFailure,
+	}, docs[0].Error)
+	assert.Equal(t, "home", docs[1].Gateway)
+	assert.True(t, docs[1].OK)
+	assert.JSONEq(t, `{"web_interface_up":true}`, string(docs[1].Data))
+	assert.Equal(t, "office", docs[2].Gateway)
+	assert.Equal(t, &jsonError{
+		Message:  "authentication failed: " + errGatewayDown.Error(),
+		Code:     "auth_failed",
+		ExitCode: ExitAuthFailed,
+	}, docs[2].Error)
+}
+
+func TestFanOut_SignalText(t *testing.T) {
+	out := captureDefaultOutput(t)
+	a := newFanOutApp(map[string]tmhi.Gateway{
+		"cabin":  &mockGateway{signalResult: testSignalResult()},
+		"home":   &mockGateway{signalResult: testSignalResult()},
+		"office": &mockGateway{signalResult: testSignalResult()},
+	})
+
+	err := runFanOut(t, a, cmdSignal, a.signal, "--inventory", writeInventory(t, testInventory))
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "Gateway")
+	assert.Contains(t, out.String(), "cabin")
+	assert.Contains(t, out.String(), "office")
+	assert.Contains(t, out.String(), network5G)
+}
+
+func TestFanOut_AllFailed(t *testing.T) {
+	out := captureDefaultOutput(t)
+	a := newFanOutApp(nil)
+
+	err := runFanOut(t, a, cmdInfo, a.info, "--inventory", writeInventory(t, testInventory))
+
+	require.ErrorIs(t, err, ErrAllGatewaysFailed)
+	assert.Equal(t, ExitPartialFailure, ExitCode(err))
+	assert.Contains(t, out.String(), errGatewayDown.Error())
+}
+
+func TestFanOutFailure(t *testing.T) {
+	unreachable := fmt.Errorf("%w: %w", ErrGatewayUnreachable, context.DeadlineExceeded)
+	results := []gatewayResult[int]{{name: "home", err: unreachable}, {name: "office"}}
+
+	err := fanOutFailure(results)
+	require.ErrorIs(t, err, ErrPartialFailure)
+	assert.Equal(t, ExitPartialFailure, ExitCode(err))
+
+	results[1].err = unreachable
+	err = fanOutFailure(results)
+	require.ErrorIs(t, err, ErrAllGatewaysFailed)
+	assert.Equal(t, ExitUnreachable, ExitCode(err))
+
+	results[1].err = errGatewayDown
+	assert.Equal(t, ExitPartialFailure, ExitCode(fanOutFailure(results)))
+}
+
+func TestFanOut_RejectsWatch(t *testing.T) {
+	a := newFanOutApp(nil)
+
+	err := runFanOut(t, a, cmdStatus, a.status,
+		"--inventory", writeInventory(t, testInventory), "--watch", "1s")
+	require.ErrorIs(t, err, ErrFanOutWatch)
+}
+
+func TestFanOut_AllProfilesUsesConfigFile(t *testing.T) {
+	out := captureDefaultOutput(t)
+	configFile := writeInventory(t, testInventory)
+	a := newFanOutApp(map[string]tmhi.Gateway{
+		"cabin": &mockGateway{}, "home": &mockGateway{}, "office": &mockGateway{},
+	})
+
+	root := &cli.Command{
+		Name:     appName,
+		Flags:    []cli.Flag{&cli.StringFlag{Name: ConfigConfig}},
+		Commands: []*cli.Command{{Name: cmdStatus, Flags: fanOutFlags(), Action: a.status}},
+	}
+
+	err := root.Run(t.Context(),
+		[]string{appName, "--config", configFile, cmdStatus, "--all-profiles"})
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "home")
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrFilter is returned for a --filter expression that cannot be parsed or
+// does not fit the document.
+var ErrFilter = errors.New("invalid filter")
+
+// filterStep is one step of a filter path: an object key, an array index or
+// every element.
+type filterStep struct {
+	key   string
+	index int
+	kind  filterStepKind
+}
+
+type filterStepKind int
+
+const (
+	filterKey filterStepKind = iota
+	filterIndex
+	filterEach
+)
+
+// parseFilter parses a jq-like path: `.` followed by object keys and
+// brackets, such as `.signal.5g.sinr`, `.signal.4g.bands[0]`,
+// `.cells[].band` or `.["key with dots."]`.
+func parseFilter(expr string) ([]filterStep, error) {
+	if !strings.HasPrefix(expr, ".") {
+		return nil, fmt.Errorf("%w %q: must start with .", ErrFilter, expr)
+	}
+
+	var steps []filterStep
+
+	for i := 0; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			i++
+
+			end := i
+			for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+				end++
+			}
+
+			if end > i {
+				steps = append(steps, filterStep{key: expr[i:end], kind: filterKey})
+			} else if end < len(expr) && expr[end] == '.' {
+				return nil, fmt.Errorf("%w %q: empty key", ErrFilter, expr)
+			}
+
+			i = end
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w %q: missing ]", ErrFilter, expr)
+			}
+
+			step, ok := parseBracket(expr[i+1 : i+end])
+			if !ok {
+				return nil, fmt.Errorf("%w %q: bad brackets %s", ErrFilter, expr, expr[i:i+end+1])
+			}
+
+			steps = append(steps, step)
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("%w %q: unexpected %q", ErrFilter, expr, expr[i])
+		}
+	}
+
+	return steps, nil
+}
+
+// parseBracket parses what is between brackets: nothing, a quoted key or an
+// index, negative ones counting from the end.
+func parseBracket(inner string) (filterStep, bool) {
+	if inner == "" {
+		return filterStep{kind: filterEach}, true
+	}
+
+	if strings.HasPrefix(inner, `"`) {
+		key, err := strconv.Unquote(inner)
+
+		return filterStep{key: key, kind: filterKey}, err == nil
+	}
+
+	index, err := strconv.Atoi(inner)
+
+	return filterStep{index: index, kind: filterIndex}, err == nil
+}
+
+// applyFilter follows steps through doc. Like jq, missing keys and indexes
+// give null, and each `[]` multiplies the results.
+func applyFilter(doc any, steps []filterStep) ([]any, error) {
+	values := []any{doc}
+
+	for _, step := range steps {
+		var next []any
+
+		for _, value := range values {
+			results, err := step.apply(value)
+			if err != nil {
+				return nil, err
+			}
+
+			next = append(next, results...)
+		}
+
+		values = next
+	}
+
+	return values, nil
+}
+
+func (s filterStep) apply(value any) ([]any, error) {
+	if value == nil {
+		return []any{nil}, nil
+	}
+
+	object, isObject := value.(map[string]any)
+	array, isArray := value.([]any)
+
+	switch {
+	case isObject && s.kind == filterKey:
+		return []any{object[s.key]}, nil
+	case isObject && s.kind == filterEach:
+		values := make([]any, 0, len(object))
+		for _, key := range slices.Sorted(maps.Keys(object)) {
+			values = append(values, object[key])
+		}
+
+		return values, nil
+	case isArray && s.kind == filterEach:
+		return array, nil
+	case isArray && s.kind == filterIndex:
+		index := s.index
+		if index < 0 {
+			index += len(array)
+		}
+
+		if index < 0 || index >= len(array) {
+			return []any{nil}, nil
+		}
+
+		return []any{array[index]}, nil
+	default:
+		return nil, fmt.Errorf("%w: cannot apply %s to %s", ErrFilter, s, jsonKind(value))
+	}
+}
+
+func (s filterStep) String() string {
+	switch s.kind {
+	case filterIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	case filterEach:
+		return "[]"
+	default:
+		return strconv.Quote(s.key)
+	}
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
+
+// filterJSON applies the filter expr to the JSON document body.
+func filterJSON(body []byte, expr string) ([]any, error) {
+	steps, err := parseFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Keep numbers as the gateway wrote them.
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: response body is not JSON: %w", ErrFilter, err)
+	}
+
+	return applyFilter(doc, steps)
+}
+
+// formatFilterValue prints strings as they are, for use in scripts, and
+// other values as JSON.
+func formatFilterValue(value any, pretty bool) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	var (
+		out []byte
+		err error
+	)
+
+	if pretty {
+		out, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		out, err = json.Marshal(value)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to format value: %w", err)
+	}
+
+	return string(out), nil
+}
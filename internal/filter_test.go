@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	steps, err := parseFilter(`.signal.5g.bands[0][]["a.b"]`)
+	require.NoError(t, err)
+	assert.Equal(t, []filterStep{
+		{key: "signal", kind: filterKey},
+		{key: "5g", kind: filterKey},
+		{key: "bands", kind: filterKey},
+		{index: 0, kind: filterIndex},
+		{kind: filterEach},
+		{key: "a.b", kind: filterKey},
+	}, steps)
+
+	steps, err = parseFilter(".")
+	require.NoError(t, err)
+	assert.Empty(t, steps)
+
+	for _, expr := range []string{"", "signal", ".a..b", ".a[0", ".a[x]", `.a["b]`} {
+		_, err := parseFilter(expr)
+		require.ErrorIs(t, err, ErrFilter, expr)
+	}
+}
+
+func TestFilterJSON(t *testing.T) {
+	const body = `{"cells":[{"band":"n41","pci":123},{"band":"B2"}],"id":12345678901234567890}`
+
+	tests := []struct {
+		expr string
+		want []any
+	}{
+		{".", nil},
+		{".cells[].band", []any{"n41", "B2"}},
+		{".cells[1].pci", []any{nil}},
+		{".cells[5]", []any{nil}},
+		{".id", []any{json.Number("12345678901234567890")}},
+	}
+
+	for _, tt := range tests {
+		values, err := filterJSON([]byte(body), tt.expr)
+		require.NoError(t, err, tt.expr)
+
+		if tt.want == nil {
+			assert.Len(t, values, 1, tt.expr)
+
+			continue
+		}
+
+		assert.Equal(t, tt.want, values, tt.expr)
+	}
+
+	_, err := filterJSON([]byte(body), ".cells.band")
+	require.ErrorIs(t, err, ErrFilter)
+}
+
+func TestFormatFilterValue(t *testing.T) {
+	for _, tt := range []struct {
+		value  any
+		pretty bool
+		want   string
+	}{
+		{"n41", false, "n41"},
+		{json.Number("-95"), false, "-95"},
+		{nil, false, "null"},
+		{map[string]any{"a": []any{true}}, false, `{"a":[true]}`},
+		{[]any{"x"}, true, "[\n  \"x\"\n]"},
+	} {
+		got, err := formatFilterValue(tt.value, tt.pretty)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
@@ -1,14 +1,35 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"os"
 
 	tmhi "github.com/hugoh/tmhi-gateway/v2"
 )
 
 var errUnknownGateway = errors.New("unknown gateway")
 
+// prepareConfig completes cfg before a gateway is built from it: it resolves
+// the password, registering it with secrets for redaction, validates cfg, and
+// detects the model when none is set.
+func prepareConfig(ctx context.Context, cfg *Config, secrets *redactor) error {
+	if err := cfg.resolvePassword(ctx); err != nil {
+		return err
+	}
+
+	secrets.add(cfg.Password)
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	return cfg.resolveModel(ctx)
+}
+
 //nolint:ireturn
 func getGateway(cfg *Config, userAgent string) (tmhi.Gateway, error) {
 	gwConfig := &tmhi.GatewayConfig{
@@ -31,3 +52,158 @@ func getGateway(cfg *Config, userAgent string) (tmhi.Gateway, error) {
 		return nil, fmt.Errorf("%w: %q", errUnknownGateway, cfg.Model)
 	}
 }
+
+// openGateway returns the gateway of cfg, its errors marked with their cause
+// for the exit code. The gateway sends req bodies and headers through tap,
+// which cfg points at, when there is one.
+//
+//nolint:ireturn
+func openGateway(cfg *Config, userAgent string, tap *gatewayTap) (tmhi.Gateway, error) {
+	gateway, err := getGateway(cfg, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return classifiedGateway{Gateway: gateway, tap: tap}, nil
+}
+
+// classifiedGateway marks the network failures of a gateway as
+// ErrGatewayUnreachable, and the requests it refuses as ErrAuthFailed.
+type classifiedGateway struct {
+	tmhi.Gateway
+
+	tap *gatewayTap
+}
+
+func (g classifiedGateway) Login(ctx context.Context) error {
+	return g.call(func() error { return g.Gateway.Login(ctx) })
+}
+
+func (g classifiedGateway) Reboot(ctx context.Context) error {
+	return g.call(func() error { return g.Gateway.Reboot(ctx) })
+}
+
+func (g classifiedGateway) Request(
+	ctx context.Context,
+	method, path string,
+) (*tmhi.InfoResult, error) {
+	var result *tmhi.InfoResult
+
+	err := g.call(func() (err error) {
+		result, err = g.Gateway.Request(ctx, method, path)
+
+		return err //nolint:wrapcheck
+	})
+
+	return result, err
+}
+
+// RequestWithBody sends a request with a body and headers by giving them to
+// the request the gateway client sends through the tap, and returns the
+// response to it. It fails with ErrReqUnsupported without a tap.
+func (g classifiedGateway) RequestWithBody(
+	ctx context.Context,
+	method, path string,
+	header http.Header,
+	body []byte,
+) (*reqResponse, error) {
+	if g.tap == nil {
+		return nil, ErrReqUnsupported
+	}
+
+	injected := g.tap.inject(method, path, header, body)
+	_, err := g.Request(ctx, method, path)
+
+	// The client may fail on the response, such as an error status or a
+	// body that is not JSON, which is for the caller to judge.
+	if response := g.tap.release(injected); response != nil {
+		return response, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("%w: it sent no %s %s request", ErrReqUnsupported, method, path)
+}
+
+func (g classifiedGateway) Info(ctx context.Context) (*tmhi.InfoResult, error) {
+	var result *tmhi.InfoResult
+
+	err := g.call(func() (err error) {
+		result, err = g.Gateway.Info(ctx)
+
+		return err //nolint:wrapcheck
+	})
+
+	return result, err
+}
+
+func (g classifiedGateway) Status(ctx context.Context) (*tmhi.StatusResult, error) {
+	var result *tmhi.StatusResult
+
+	err := g.call(func() (err error) {
+		result, err = g.Gateway.Status(ctx)
+
+		return err //nolint:wrapcheck
+	})
+
+	return result, err
+}
+
+func (g classifiedGateway) Signal(ctx context.Context) (*tmhi.SignalResult, error) {
+	var result *tmhi.SignalResult
+
+	err := g.call(func() (err error) {
+		result, err = g.Gateway.Signal(ctx)
+
+		return err //nolint:wrapcheck
+	})
+
+	return result, err
+}
+
+// call runs a call of the gateway client and classifies its error. When the
+// tap could not reach the gateway, that failure replaces the error of the
+// client, which only knows that the tap hung up. When the gateway answered
+// 401 or 403, as it does to a wrong password, the error is ErrAuthFailed.
+func (g classifiedGateway) call(call func() error) error {
+	seen, err := g.tap.observe(call)
+
+	switch {
+	case err == nil:
+		return nil
+	case seen.failure != nil && isUnreachable(seen.failure):
+		err = seen.failure
+	case seen.refused:
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	}
+
+	return classifyGatewayError(err)
+}
+
+// classifyGatewayError marks err as ErrGatewayUnreachable when it wraps a
+// timeout, or a failure to dial or resolve the gateway. Other errors are
+// left as they are: their text is no reliable sign of their cause.
+func classifyGatewayError(err error) error {
+	if err != nil && isUnreachable(err) {
+		return fmt.Errorf("%w: %w", ErrGatewayUnreachable, err)
+	}
+
+	return err
+}
+
+func isUnreachable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	if netErr, ok := errors.AsType[net.Error](err); ok && netErr.Timeout() {
+		return true
+	}
+
+	_, isOpError := errors.AsType[*net.OpError](err)
+	_, isDNSError := errors.AsType[*net.DNSError](err)
+
+	return isOpError || isDNSError
+}
@@ -1,9 +1,17 @@
 package internal
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/hugoh/tmhi-cli/simulator"
 	tmhi "github.com/hugoh/tmhi-gateway/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -83,3 +91,141 @@ func TestGateway(t *testing.T) {
 		assert.IsType(t, &tmhi.NokiaGateway{}, g)
 	})
 }
+
+func TestClassifyGatewayError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	for _, err := range []error{
+		refused,
+		fmt.Errorf("failed to login: %w", refused),
+		context.DeadlineExceeded,
+		&net.DNSError{Err: "no such host", Name: "gateway.lan", IsNotFound: true},
+	} {
+		classified := classifyGatewayError(err)
+		require.ErrorIs(t, classified, ErrGatewayUnreachable, err)
+		require.ErrorIs(t, classified, err)
+	}
+
+	require.NoError(t, classifyGatewayError(nil))
+
+	// Messages are not classified: they may name anything.
+	for _, err := range []error{
+		errors.New("read tcp: i/o timeout"),
+		errors.New("login failed: status 401"),
+		context.Canceled,
+	} {
+		assert.Equal(t, err, classifyGatewayError(err))
+	}
+}
+
+func TestOpenGateway(t *testing.T) {
+	cfg := &Config{Model: NOK5G21, IP: testIP, Timeout: DefaultTimeout}
+
+	g, err := openGateway(cfg, "", nil)
+	require.NoError(t, err)
+	assert.IsType(t, classifiedGateway{}, g)
+
+	classified := classifiedGateway{Gateway: &mockGateway{
+		loginErr:  errGatewayDown,
+		statusErr: context.DeadlineExceeded,
+	}}
+
+	err = classified.Login(t.Context())
+	require.Error(t, err)
+	assert.Equal(t, ExitFailure, ExitCode(err), "an error of unknown cause")
+
+	_, err = classified.Status(t.Context())
+	require.ErrorIs(t, err, ErrGatewayUnreachable)
+
+	cfg.Model = "invalid"
+	_, err = openGateway(cfg, "", nil)
+	require.ErrorIs(t, err, errUnknownGateway)
+}
+
+var errHTTPStatus = errors.New("unexpected status")
+
+// httpGateway is a client of an Arcadyan gateway at host speaking HTTP like
+// the real one, for tests of what goes through the tap.
+type httpGateway struct {
+	mockGateway
+
+	host, username, password string
+}
+
+func (g *httpGateway) Login(ctx context.Context) error {
+	return g.send(ctx, http.MethodPost, "/TMI/v1/auth/login",
+		fmt.Sprintf(`{"username":%q,"password":%q}`, g.username, g.password))
+}
+
+func (g *httpGateway) Request(ctx context.Context, method, path string) (*tmhi.InfoResult, error) {
+	if err := g.send(ctx, method, path, ""); err != nil {
+		return nil, err
+	}
+
+	return &tmhi.InfoResult{}, nil
+}
+
+func (g *httpGateway) send(ctx context.Context, method, path, body string) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+g.host+path,
+		strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w %d", errHTTPStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// newTappedGateway returns an httpGateway for cfg behind a tap, as the CLI
+// opens gateways.
+func newTappedGateway(t *testing.T, cfg *Config) classifiedGateway {
+	t.Helper()
+
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	tapped, tap, err := taps.tap(cfg, &redactor{})
+	require.NoError(t, err)
+
+	return classifiedGateway{
+		Gateway: &httpGateway{
+			host: tapped.IP, username: tapped.Username, password: tapped.Password,
+		},
+		tap: tap,
+	}
+}
+
+func TestClassifiedGateway_Login(t *testing.T) {
+	_, gatewayHost := simulator.Start(t, simulator.Options{
+		Model: simulator.ModelArcadyan, Password: "s3cret",
+	})
+
+	gateway := newTappedGateway(t, &Config{IP: gatewayHost, Username: "admin", Password: "s3cret"})
+	require.NoError(t, gateway.Login(t.Context()))
+
+	gateway = newTappedGateway(t, &Config{IP: gatewayHost, Username: "admin", Password: "wrong"})
+	err := gateway.Login(t.Context())
+	require.ErrorIs(t, err, ErrAuthFailed)
+	assert.Equal(t, ExitAuthFailed, ExitCode(err))
+}
+
+func TestClassifiedGateway_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	gateway := newTappedGateway(t, &Config{IP: host})
+
+	_, err := gateway.Request(t.Context(), http.MethodGet, "/")
+	require.ErrorIs(t, err, ErrGatewayUnreachable)
+	assert.Contains(t, err.Error(), host, "the error is about the gateway, not the tap")
+}
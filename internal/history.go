@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	percentile95 = 0.95
+	dateLayout   = "2006-01-02"
+)
+
+// ErrTimeBound is returned when a history time bound cannot be parsed.
+var ErrTimeBound = errors.New("time must be a duration ago (e.g. 24h), a date or an RFC 3339 time")
+
+// metricSummary describes the distribution of one metric over a group of
+// samples.
+type metricSummary struct {
+	Metric string  `json:"metric"`
+	Min    float64 `json:"min"`
+	Avg    float64 `json:"avg"`
+	Max    float64 `json:"max"`
+	P95    float64 `json:"p95"`
+}
+
+// historySummary gathers the samples recorded for one network and band
+// combination.
+type historySummary struct {
+	Network string          `json:"network"`
+	Bands   []string        `json:"bands"`
+	Samples int             `json:"samples"`
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Metrics []metricSummary `json:"metrics"`
+}
+
+//nolint:gochecknoglobals
+var summarizedMetrics = []struct {
+	name  string
+	value func(signalSample) float64
+}{
+	{"Bars", func(s signalSample) float64 { return s.Bars }},
+	{"RSRP", func(s signalSample) float64 { return float64(s.RSRP) }},
+	{"RSRQ", func(s signalSample) float64 { return float64(s.RSRQ) }},
+	{"RSSI", func(s signalSample) float64 { return float64(s.RSSI) }},
+	{"SINR", func(s signalSample) float64 { return float64(s.SINR) }},
+}
+
+// parseTimeBound parses a history bound: a duration before now, a date, or
+// an RFC 3339 time. An empty value is an open bound.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if ago, err := time.ParseDuration(value); err == nil {
+		return now.Add(-ago), nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+
+	if at, err := time.ParseInLocation(dateLayout, value, now.Location()); err == nil {
+		return at, nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q", ErrTimeBound, value)
+}
+
+// summarizeHistory groups samples by network and bands, in the order each
+// group first appears.
+func summarizeHistory(samples []signalSample) []historySummary {
+	var (
+		keys   []string
+		groups = map[string][]signalSample{}
+	)
+
+	for _, sample := range samples {
+		key := sample.Network + "/" + strings.Join(sample.Bands, bandsSeparator)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		groups[key] = append(groups[key], sample)
+	}
+
+	summaries := make([]historySummary, 0, len(keys))
+
+	for _, key := range keys {
+		group := groups[key]
+		summary := historySummary{
+			Network: group[0].Network,
+			Bands:   group[0].Bands,
+			Samples: len(group),
+			From:    group[0].Time,
+			To:      group[len(group)-1].Time,
+		}
+
+		for _, metric := range summarizedMetrics {
+			values := make([]float64, len(group))
+			for i, sample := range group {
+				values[i] = metric.value(sample)
+			}
+
+			summary.Metrics = append(summary.Metrics, summarizeValues(metric.name, values))
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+func summarizeValues(name string, values []float64) metricSummary {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	// Nearest-rank percentile.
+	rank := int(math.Ceil(percentile95*float64(len(sorted)))) - 1
+
+	return metricSummary{
+		Metric: name,
+		Min:    sorted[0],
+		Avg:    sum / float64(len(sorted)),
+		Max:    sorted[len(sorted)-1],
+		P95:    sorted[max(rank, 0)],
+	}
+}
+
+func displayHistory(summaries []historySummary) {
+	if len(summaries) == 0 {
+		pterm.Warning.Println("No samples recorded in this time range")
+
+		return
+	}
+
+	for _, summary := range summaries {
+		pterm.DefaultHeader.Printfln("%s %s: %d samples from %s to %s",
+			strings.ToUpper(summary.Network),
+			strings.Join(summary.Bands, ", "),
+			summary.Samples,
+			summary.From.Local().Format(time.DateTime),
+			summary.To.Local().Format(time.DateTime))
+
+		tableData := pterm.TableData{{"Metric", "Min", "Avg", "Max", "P95"}}
+		for _, metric := range summary.Metrics {
+			tableData = append(tableData, []string{
+				metric.Metric,
+				formatSummaryValue(metric.Min),
+				formatSummaryValue(metric.Avg),
+				formatSummaryValue(metric.Max),
+				formatSummaryValue(metric.P95),
+			})
+		}
+
+		pterm.Print(renderTable(tableData))
+	}
+}
+
+func formatSummaryValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func (a *app) history(_ context.Context, cmd *cli.Command) error {
+	now := time.Now()
+
+	since, err := parseTimeBound(cmd.String(ConfigSince), now)
+	if err != nil {
+		return err
+	}
+
+	until, err := parseTimeBound(cmd.String(ConfigUntil), now)
+	if err != nil {
+		return err
+	}
+
+	samples, err := historyStore{path: cmd.String(ConfigDB)}.read(since, until)
+	if err != nil {
+		return err
+	}
+
+	summaries := summarizeHistory(samples)
+	if a.jsonOutput() {
+		return a.writeJSON(summaries)
+	}
+
+	displayHistory(summaries)
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"2026-10-01T08:00:00Z", time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+		{"2026-10-01", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeBound(tt.value, now)
+		require.NoError(t, err, tt.value)
+		assert.True(t, tt.want.Equal(got), "%s: got %s", tt.value, got)
+	}
+
+	_, err := parseTimeBound("yesterday", now)
+	require.ErrorIs(t, err, ErrTimeBound)
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var samples []signalSample
+	for i := range 20 {
+		at := base.Add(time.Duration(i) * time.Minute)
+		samples = append(samples,
+			testSample(at, network5G, []string{"n41"}, -100+i),
+			testSample(at, network4G, []string{"b2"}, -110))
+	}
+
+	samples = append(samples, testSample(base, network5G, []string{"n71"}, -90))
+
+	summaries := summarizeHistory(samples)
+	require.Len(t, summaries, 3)
+
+	n41 := summaries[0]
+	assert.Equal(t, network5G, n41.Network)
+	assert.Equal(t, []string{"n41"}, n41.Bands)
+	assert.Equal(t, 20, n41.Samples)
+	assert.Equal(t, base, n41.From)
+	assert.Equal(t, base.Add(19*time.Minute), n41.To)
+
+	require.Len(t, n41.Metrics, len(summarizedMetrics))
+	rsrp := n41.Metrics[1]
+	assert.Equal(t, metricSummary{Metric: "RSRP", Min: -100, Avg: -90.5, Max: -81, P95: -82}, rsrp)
+
+	assert.Equal(t, network4G, summaries[1].Network)
+	assert.Equal(t, 1, summaries[2].Samples)
+	assert.Equal(t, -90.0, summaries[2].Metrics[1].P95)
+}
+
+func TestHistoryCommand(t *testing.T) {
+	store := newTestHistoryStore(t)
+	require.NoError(t, store.append([]signalSample{
+		testSample(time.Now().Add(-time.Hour), network5G, []string{"n41"}, -95),
+	}))
+
+	run := func(a *app, args ...string) error {
+		cmd := &cli.Command{Name: cmdHistory, Flags: historyFlags(), Action: a.history}
+
+		return cmd.Run(t.Context(), append([]string{cmdHistory, "--db", store.path}, args...))
+	}
+
+	t.Run("JSON summaries", func(t *testing.T) {
+		a, buf := newJSONTestApp(nil)
+
+		require.NoError(t, run(a, "--since", "2h"))
+		assert.Contains(t, buf.String(), `"network":"5g"`)
+		assert.Contains(t, buf.String(), `"samples":1`)
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		a, buf := newJSONTestApp(nil)
+
+		require.NoError(t, run(a, "--until", "2h"))
+		assert.JSONEq(t, `{"ok":true,"data":[]}`, buf.String())
+	})
+
+	t.Run("text", func(t *testing.T) {
+		out := captureDefaultOutput(t)
+
+		require.NoError(t, run(newTestApp(nil)))
+		assert.Contains(t, out.String(), "RSRP")
+		assert.Contains(t, out.String(), "-95.0")
+	})
+
+	t.Run("bad bound", func(t *testing.T) {
+		require.ErrorIs(t, run(newTestApp(nil), "--since", "soon"), ErrTimeBound)
+	})
+}
@@ -33,7 +33,7 @@ func newTestApp(gw tmhi.Gateway) *app {
 	a := newApp()
 	a.newSpinner = func(_ string) (spinner, error) { return &mockSpinner{}, nil }
 	a.confirm = func(_ context.Context, _ string, defaultVal bool) (bool, error) { return defaultVal, nil }
-	a.initGateway = func(_ *Config) (tmhi.Gateway, error) { return gw, nil }
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) { return gw, nil }
 
 	return a
 }
@@ -0,0 +1,411 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultMQTTBroker          = "tcp://localhost:1883"
+	defaultMQTTTopic           = "tmhi"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+	defaultMQTTInterval        = 30 * time.Second
+	mqttKeepAlive              = 60 * time.Second
+	mqttReconnectDelay         = 10 * time.Second
+
+	mqttOnline       = "online"
+	mqttOffline      = "offline"
+	mqttRebootAction = "reboot"
+	mqttOn           = "ON"
+	mqttOff          = "OFF"
+)
+
+// ErrMQTTCommand is returned for an unknown payload on the command topic.
+var ErrMQTTCommand = errors.New("unknown MQTT command")
+
+//nolint:gochecknoglobals
+var mqttIDInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// haEntity is a Home Assistant entity announced through MQTT discovery.
+type haEntity struct {
+	component   string
+	object      string
+	name        string
+	unit        string
+	deviceClass string
+	// stateTopic is relative to the base topic; command entities have none.
+	stateTopic string
+	icon       string
+}
+
+// mqttSignalEntities are the sensors published for each radio, by the
+// topic under <topic>/<network>/.
+//
+//nolint:gochecknoglobals
+var mqttSignalEntities = []haEntity{
+	{object: "bars", name: "signal bars", icon: "mdi:signal-cellular-3"},
+	{object: "rsrp", name: "RSRP", unit: "dBm", deviceClass: "signal_strength"},
+	{object: "rsrq", name: "RSRQ", unit: "dB"},
+	{object: "rssi", name: "RSSI", unit: "dBm", deviceClass: "signal_strength"},
+	{object: "sinr", name: "SINR", unit: "dB"},
+	{object: "bands", name: "bands", icon: "mdi:radio-tower"},
+	{object: "cid", name: "cell ID", icon: "mdi:radio-tower"},
+	{object: "node_id", name: "tower ID", icon: "mdi:radio-tower"},
+}
+
+// mqttEntities returns every entity of the gateway.
+func mqttEntities() []haEntity {
+	entities := []haEntity{
+		{
+			component: "binary_sensor", object: "web_interface", name: "Web interface",
+			deviceClass: "connectivity", stateTopic: "web_interface",
+		},
+		{
+			component: "sensor", object: "registration", name: "Registration",
+			stateTopic: "registration", icon: "mdi:sim",
+		},
+		{component: "button", object: mqttRebootAction, name: "Reboot", deviceClass: "restart"},
+	}
+
+	for _, network := range []string{network4G, network5G} {
+		for _, entity := range mqttSignalEntities {
+			entity.component = "sensor"
+			entity.stateTopic = network + "/" + entity.object
+			entity.object = network + "_" + entity.object
+			entity.name = strings.ToUpper(network) + " " + entity.name
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities
+}
+
+// mqttBridge publishes the gateway state under topic and announces it to
+// Home Assistant under discoveryPrefix.
+type mqttBridge struct {
+	client          *mqttClient
+	gateway         tmhi.Gateway
+	topic           string
+	discoveryPrefix string
+	nodeID          string
+	device          map[string]any
+	dryRun          bool
+	cells           *cellTracker
+	notify          *notifier
+}
+
+func (b *mqttBridge) availabilityTopic() string { return b.topic + "/availability" }
+
+func (b *mqttBridge) commandTopic() string { return b.topic + "/command" }
+
+// discoveryConfig returns the Home Assistant discovery document of entity.
+func (b *mqttBridge) discoveryConfig(entity haEntity) map[string]any {
+	config := map[string]any{
+		"name":               entity.name,
+		"unique_id":          b.nodeID + "_" + entity.object,
+		"availability_topic": b.availabilityTopic(),
+		"device":             b.device,
+	}
+
+	if entity.stateTopic != "" {
+		config["state_topic"] = b.topic + "/" + entity.stateTopic
+	} else {
+		config["command_topic"] = b.commandTopic()
+		config["payload_press"] = entity.object
+	}
+
+	if entity.unit != "" {
+		config["unit_of_measurement"] = entity.unit
+		config["state_class"] = "measurement"
+	}
+
+	if entity.deviceClass != "" {
+		config["device_class"] = entity.deviceClass
+	}
+
+	if entity.icon != "" {
+		config["icon"] = entity.icon
+	}
+
+	return config
+}
+
+// announce publishes the retained discovery documents and marks the gateway
+// online.
+func (b *mqttBridge) announce() error {
+	for _, entity := range mqttEntities() {
+		payload, err := json.Marshal(b.discoveryConfig(entity))
+		if err != nil {
+			return fmt.Errorf("failed to encode discovery config: %w", err)
+		}
+
+		topic := strings.Join(
+			[]string{b.discoveryPrefix, entity.component, b.nodeID, entity.object, "config"}, "/")
+		if err := b.client.publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+
+	return b.client.publish(b.availabilityTopic(), []byte(mqttOnline), true)
+}
+
+// publishState fetches the signal and status, and publishes every value
+// read under its own retained topic. Cell changes are published to
+// <topic>/events as they are found.
+func (b *mqttBridge) publishState(ctx context.Context) error {
+	values := map[string]string{}
+
+	var registration string
+
+	signalResult, signalErr := b.gateway.Signal(ctx)
+	if signalErr == nil {
+		registration = signalResult.Generic.Registration
+		samples := samplesFromSignal(time.Now(), signalResult)
+
+		for _, sample := range samples {
+			for object, value := range map[string]string{
+				"bars":    strconv.FormatFloat(sample.Bars, 'f', -1, 64),
+				"rsrp":    strconv.Itoa(sample.RSRP),
+				"rsrq":    strconv.Itoa(sample.RSRQ),
+				"rssi":    strconv.Itoa(sample.RSSI),
+				"sinr":    strconv.Itoa(sample.SINR),
+				"bands":   strings.Join(sample.Bands, ","),
+				"cid":     strconv.Itoa(sample.CID),
+				"node_id": strconv.Itoa(sample.NodeID),
+			} {
+				values[sample.Network+"/"+object] = value
+			}
+		}
+
+		events := b.cells.track(samples)
+		if err := b.publishEvents(events); err != nil {
+			return err
+		}
+
+		b.notify.signal(ctx, samples, events, registration)
+	}
+
+	status, statusErr := b.gateway.Status(ctx)
+	if statusErr == nil {
+		b.notify.status(ctx, status)
+
+		values["web_interface"] = mqttOff
+		if status.WebInterfaceUp {
+			values["web_interface"] = mqttOn
+		}
+
+		if status.Registration != "" {
+			registration = status.Registration
+		}
+	}
+
+	if registration != "" {
+		values["registration"] = registration
+	}
+
+	for _, topic := range slices.Sorted(maps.Keys(values)) {
+		if err := b.client.publish(b.topic+"/"+topic, []byte(values[topic]), true); err != nil {
+			return err
+		}
+	}
+
+	if err := errors.Join(signalErr, statusErr); err != nil {
+		pterm.Warning.Println("Failed to query the gateway:", err)
+	}
+
+	return nil
+}
+
+func (b *mqttBridge) publishEvents(events []cellEvent) error {
+	for _, event := range events {
+		pterm.Info.Println(event)
+
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+
+		if err := b.client.publish(b.topic+"/events", payload, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleCommand acts on a message of the command topic. Retained messages
+// are ignored: a reboot left on the topic would otherwise reboot the
+// gateway on every connection.
+func (b *mqttBridge) handleCommand(ctx context.Context, message mqttMessage) {
+	command := strings.TrimSpace(string(message.payload))
+
+	switch {
+	case message.retained:
+		pterm.Warning.Printfln("Ignoring retained command %q on %s", command, message.topic)
+	case command != mqttRebootAction:
+		pterm.Warning.Println(fmt.Errorf("%w: %q", ErrMQTTCommand, command))
+	case b.dryRun:
+		pterm.Info.Println("Dry run - would send reboot request")
+	default:
+		if err := b.gateway.Reboot(ctx); err != nil {
+			pterm.Error.Println("Reboot failed:", err)
+		} else {
+			pterm.Success.Println("Reboot command sent successfully")
+			b.notify.reboot(ctx)
+		}
+	}
+}
+
+// run publishes the state every interval and answers commands until ctx is
+// cancelled or the broker connection is lost.
+func (b *mqttBridge) run(ctx context.Context, interval time.Duration) error {
+	if err := b.announce(); err != nil {
+		return err
+	}
+
+	publish := time.NewTicker(interval)
+	defer publish.Stop()
+
+	for {
+		if err := b.publishState(ctx); err != nil {
+			return err
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				_ = b.client.publish(b.availabilityTopic(), []byte(mqttOffline), true)
+
+				return nil
+			case <-publish.C:
+				waiting = false
+			case <-b.client.Done():
+				return b.client.Err()
+			case message := <-b.client.Messages():
+				b.handleCommand(ctx, message)
+			}
+		}
+	}
+}
+
+// mqttDevice is the Home Assistant device grouping the entities of the
+// gateway at ip.
+func mqttDevice(nodeID, ip, model string) map[string]any {
+	device := map[string]any{
+		"identifiers": []string{nodeID},
+		"name":        "T-Mobile gateway " + ip,
+	}
+
+	switch model {
+	case ARCADYAN:
+		device["manufacturer"], device["model"] = "Arcadyan", model
+	case NOK5G21:
+		device["manufacturer"], device["model"] = "Nokia", model
+	}
+
+	return device
+}
+
+func (a *app) mqtt(ctx context.Context, cmd *cli.Command) error {
+	broker, err := url.Parse(cmd.String(ConfigBroker))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMQTTBroker, err)
+	}
+
+	if password, ok := broker.User.Password(); ok {
+		a.secrets.add(password)
+	}
+
+	if _, err := mqttServer(broker); err != nil {
+		return err
+	}
+
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	notify, err := a.newNotifier(cmd)
+	if err != nil {
+		return err
+	}
+
+	topic := strings.TrimSuffix(cmd.String(ConfigTopic), "/")
+	nodeID := "tmhi_" + strings.Trim(mqttIDInvalid.ReplaceAllString(a.config.IP, "_"), "_")
+	bridge := &mqttBridge{
+		gateway:         gateway,
+		topic:           topic,
+		discoveryPrefix: strings.TrimSuffix(cmd.String(ConfigDiscoveryPrefix), "/"),
+		nodeID:          nodeID,
+		device:          mqttDevice(nodeID, a.config.IP, a.config.Model),
+		dryRun:          a.config.DryRun,
+		cells:           newCellTracker(),
+		notify:          notify,
+	}
+	opts := mqttOptions{
+		clientID:  nodeID,
+		keepAlive: mqttKeepAlive,
+		will:      mqttWill{topic: bridge.availabilityTopic(), payload: mqttOffline},
+	}
+	interval := cmd.Duration(ConfigInterval)
+
+	for {
+		err := a.runMQTTSession(ctx, broker, opts, bridge, interval)
+
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case errors.Is(err, ErrMQTTRefused):
+			return err
+		}
+
+		pterm.Warning.Printfln("MQTT connection failed, retrying in %s: %v",
+			mqttReconnectDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(mqttReconnectDelay):
+		}
+	}
+}
+
+// runMQTTSession connects to broker and runs bridge until the connection
+// ends.
+func (a *app) runMQTTSession(
+	ctx context.Context,
+	broker *url.URL,
+	opts mqttOptions,
+	bridge *mqttBridge,
+	interval time.Duration,
+) error {
+	client, err := dialMQTT(ctx, broker, opts)
+	if err != nil {
+		return err
+	}
+	defer client.Close() //nolint:errcheck
+
+	if err := client.subscribe(bridge.commandTopic()); err != nil {
+		return err
+	}
+
+	bridge.client = client
+
+	pterm.Info.Printfln("Publishing to %s under %s every %s, commands on %s",
+		broker.Redacted(), bridge.topic, interval, bridge.commandTopic())
+
+	return bridge.run(ctx, interval)
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+const (
+	mqttDefaultPort = "1883"
+	mqttTLSPort     = "8883"
+	mqttWSPort      = "80"
+	mqttWSSPort     = "443"
+	// mqttProtocolVersion is MQTT 3.1.1, without falling back on 3.1.
+	mqttProtocolVersion = 4
+	mqttSubAckFail      = 0x80
+	mqttQuiesce         = 250 // milliseconds
+)
+
+var (
+	// ErrMQTTBroker is returned for a broker URL that cannot be used.
+	ErrMQTTBroker = errors.New("invalid MQTT broker URL")
+	// ErrMQTTRefused is returned when the broker refuses the connection or
+	// a subscription.
+	ErrMQTTRefused = errors.New("MQTT broker refused")
+)
+
+// mqttSchemes are the broker URL schemes, by their default port: tcp:// and
+// mqtt:// are plain, ssl://, tls:// and mqtts:// use TLS, and ws:// and
+// wss:// go through WebSocket.
+//
+//nolint:gochecknoglobals
+var mqttSchemes = map[string]string{
+	"tcp":   mqttDefaultPort,
+	"mqtt":  mqttDefaultPort,
+	"ssl":   mqttTLSPort,
+	"tls":   mqttTLSPort,
+	"mqtts": mqttTLSPort,
+	"ws":    mqttWSPort,
+	"wss":   mqttWSSPort,
+}
+
+// mqttMessage is a message received on a subscribed topic.
+type mqttMessage struct {
+	topic   string
+	payload []byte
+	// retained is set when the broker kept the message from before we
+	// subscribed.
+	retained bool
+}
+
+// mqttWill is the message the broker publishes when the client goes away
+// without disconnecting.
+type mqttWill struct {
+	topic   string
+	payload string
+}
+
+// mqttOptions are the session settings sent with CONNECT.
+type mqttOptions struct {
+	clientID  string
+	keepAlive time.Duration
+	will      mqttWill
+}
+
+// mqttClient is one session with the broker, publishing and subscribing at
+// QoS 0 only, which is all Home Assistant needs from us. Reconnecting is
+// left to the caller, which announces the gateway again on a new session.
+type mqttClient struct {
+	client    paho.Client
+	messages  chan mqttMessage
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// mqttServer returns the broker URL without its credentials and with its
+// default port, rejecting the schemes the client cannot connect with.
+func mqttServer(broker *url.URL) (string, error) {
+	port, ok := mqttSchemes[broker.Scheme]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported scheme %q, use tcp://, ssl:// or ws://",
+			ErrMQTTBroker, broker.Scheme)
+	}
+
+	if broker.Hostname() == "" {
+		return "", fmt.Errorf("%w: missing host", ErrMQTTBroker)
+	}
+
+	if broker.Port() != "" {
+		port = broker.Port()
+	}
+
+	server := url.URL{
+		Scheme: broker.Scheme,
+		Host:   net.JoinHostPort(broker.Hostname(), port),
+		Path:   broker.Path,
+	}
+
+	return server.String(), nil
+}
+
+// dialMQTT connects to broker, authenticating with the credentials of its
+// URL, and waits for the broker to accept the session.
+func dialMQTT(ctx context.Context, broker *url.URL, opts mqttOptions) (*mqttClient, error) {
+	server, err := mqttServer(broker)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqttClient{
+		messages: make(chan mqttMessage, 1),
+		done:     make(chan struct{}),
+	}
+
+	options := paho.NewClientOptions().
+		AddBroker(server).
+		SetClientID(opts.clientID).
+		SetProtocolVersion(mqttProtocolVersion).
+		SetCleanSession(true).
+		SetKeepAlive(opts.keepAlive).
+		SetAutoReconnect(false).
+		SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) { c.fail(err) })
+
+	if opts.will.topic != "" {
+		options.SetBinaryWill(opts.will.topic, []byte(opts.will.payload), 0, true)
+	}
+
+	if broker.User != nil {
+		options.SetUsername(broker.User.Username())
+
+		if password, ok := broker.User.Password(); ok {
+			options.SetPassword(password)
+		}
+	}
+
+	c.client = paho.NewClient(options)
+
+	token, _ := c.client.Connect().(*paho.ConnectToken)
+
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		c.client.Disconnect(0)
+
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", ctx.Err())
+	}
+
+	if err := token.Error(); err != nil {
+		if code := token.ReturnCode(); code != packets.Accepted && code < packets.ErrNetworkError {
+			return nil, fmt.Errorf("%w the connection: %w", ErrMQTTRefused, err)
+		}
+
+		return nil, fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	return c, nil
+}
+
+// subscribe subscribes to topic and waits for the broker's answer. Messages
+// then arrive on Messages.
+func (c *mqttClient) subscribe(topic string) error {
+	token, _ := c.client.Subscribe(topic, 0, c.receive).(*paho.SubscribeToken)
+	if err := c.wait(token); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+	}
+
+	if token.Result()[topic] == mqttSubAckFail {
+		return fmt.Errorf("%w the subscription to %s", ErrMQTTRefused, topic)
+	}
+
+	return nil
+}
+
+func (c *mqttClient) receive(_ paho.Client, message paho.Message) {
+	select {
+	case c.messages <- mqttMessage{
+		topic:    message.Topic(),
+		payload:  message.Payload(),
+		retained: message.Retained(),
+	}:
+	case <-c.done:
+	}
+}
+
+// Messages returns the messages of the subscribed topics.
+func (c *mqttClient) Messages() <-chan mqttMessage {
+	return c.messages
+}
+
+// Done is closed when the connection ends.
+func (c *mqttClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended, once Done is closed.
+func (c *mqttClient) Err() error {
+	<-c.done
+
+	return c.err
+}
+
+func (c *mqttClient) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+// publish sends payload to topic at QoS 0.
+func (c *mqttClient) publish(topic string, payload []byte, retain bool) error {
+	if err := c.wait(c.client.Publish(topic, 0, retain, payload)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+
+	return nil
+}
+
+// wait waits for token to complete, or for the connection to end.
+func (c *mqttClient) wait(token paho.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error() //nolint:wrapcheck
+	case <-c.done:
+		return c.err
+	}
+}
+
+// Close disconnects cleanly, so the broker does not publish the will.
+func (c *mqttClient) Close() error {
+	c.client.Disconnect(mqttQuiesce)
+	c.fail(net.ErrClosed)
+
+	return nil
+}
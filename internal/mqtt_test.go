@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// rebootSignalingGateway reports reboots on a channel, for the bridge runs
+// them from its own goroutine.
+type rebootSignalingGateway struct {
+	*mockGateway
+
+	rebooted chan struct{}
+}
+
+func (g *rebootSignalingGateway) Reboot(context.Context) error {
+	close(g.rebooted)
+
+	return nil
+}
+
+func newMQTTCmd(a *app) *cli.Command {
+	return &cli.Command{Name: cmdMQTT, Flags: mqttFlags(), Action: a.mqtt}
+}
+
+func TestMQTTEntities(t *testing.T) {
+	bridge := &mqttBridge{
+		topic:   "tmhi",
+		nodeID:  "tmhi_192_168_12_1",
+		device:  mqttDevice("tmhi_192_168_12_1", "192.168.12.1", ARCADYAN),
+		cells:   newCellTracker(),
+		gateway: &mockGateway{},
+	}
+
+	configs := map[string]map[string]any{}
+	for _, entity := range mqttEntities() {
+		configs[entity.component+"/"+entity.object] = bridge.discoveryConfig(entity)
+	}
+
+	assert.Len(t, configs, 3+2*len(mqttSignalEntities))
+	assert.Equal(t, map[string]any{
+		"name":                "5G RSRP",
+		"unique_id":           "tmhi_192_168_12_1_5g_rsrp",
+		"state_topic":         "tmhi/5g/rsrp",
+		"availability_topic":  "tmhi/availability",
+		"unit_of_measurement": "dBm",
+		"state_class":         "measurement",
+		"device_class":        "signal_strength",
+		"device": map[string]any{
+			"identifiers":  []string{"tmhi_192_168_12_1"},
+			"name":         "T-Mobile gateway 192.168.12.1",
+			"manufacturer": "Arcadyan",
+			"model":        ARCADYAN,
+		},
+	}, configs["sensor/5g_rsrp"])
+
+	reboot := configs["button/reboot"]
+	assert.Equal(t, "tmhi/command", reboot["command_topic"])
+	assert.Equal(t, "reboot", reboot["payload_press"])
+	assert.NotContains(t, reboot, "state_topic")
+
+	assert.Equal(t, "tmhi/web_interface", configs["binary_sensor/web_interface"]["state_topic"])
+	assert.NotContains(t, configs["sensor/4g_cid"], "unit_of_measurement")
+}
+
+func TestMQTTBridge_HandleCommand(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	mg := &mockGateway{}
+	bridge := &mqttBridge{gateway: mg, dryRun: true}
+
+	bridge.handleCommand(t.Context(), mqttMessage{payload: []byte("reboot\n")})
+	assert.False(t, mg.rebootCalled, "dry run")
+
+	bridge.dryRun = false
+	bridge.handleCommand(t.Context(), mqttMessage{payload: []byte("reboot"), retained: true})
+	assert.False(t, mg.rebootCalled, "retained command")
+
+	bridge.handleCommand(t.Context(), mqttMessage{payload: []byte("shutdown")})
+	assert.False(t, mg.rebootCalled, "unknown command")
+
+	bridge.handleCommand(t.Context(), mqttMessage{payload: []byte("reboot")})
+	assert.True(t, mg.rebootCalled)
+
+	mg.rebootErr = errors.New("unauthorized")
+	bridge.handleCommand(t.Context(), mqttMessage{payload: []byte("reboot")})
+}
+
+func TestMQTT_PublishAndReboot(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	broker := newFakeBroker(t, packets.Accepted)
+	gw := &rebootSignalingGateway{
+		mockGateway: &mockGateway{signalResult: testSignalResult()},
+		rebooted:    make(chan struct{}),
+	}
+	a := newTestApp(gw)
+	a.config.IP = "192.168.12.1"
+	a.config.Model = NOK5G21
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- newMQTTCmd(a).Run(ctx, []string{
+			cmdMQTT, "--broker", broker.url("").String(), "--topic", "home/gw/", "--interval", "1h",
+		})
+	}()
+
+	payload, retained := broker.waitPublished(
+		"homeassistant/sensor/tmhi_192_168_12_1/5g_sinr/config")
+	assert.True(t, retained)
+
+	var config map[string]any
+	require.NoError(t, json.Unmarshal([]byte(payload), &config))
+	assert.Equal(t, "home/gw/5g/sinr", config["state_topic"])
+
+	published := broker.collect("home/gw/web_interface")
+	for topic, want := range map[string]string{
+		"home/gw/availability":  mqttOnline,
+		"home/gw/5g/rsrp":       "-95",
+		"home/gw/5g/bands":      "n41,n71",
+		"home/gw/web_interface": mqttOn,
+		"home/gw/registration":  testRegState,
+	} {
+		assert.Equal(t, want, published[topic], topic)
+	}
+
+	// A retained reboot would close rebooted twice, and panic.
+	conn := broker.conn()
+	broker.send(conn, "home/gw/command", "reboot", true)
+	broker.send(conn, "home/gw/command", "reboot", false)
+
+	select {
+	case <-gw.rebooted:
+	case <-time.After(testMQTTTimeout):
+		t.Fatal("no reboot")
+	}
+
+	cancel()
+	require.NoError(t, <-done)
+
+	payload, _ = broker.waitPublished("home/gw/availability")
+	assert.Equal(t, mqttOffline, payload)
+	nextPacket[*packets.DisconnectPacket](broker)
+}
+
+func TestMQTT_Errors(t *testing.T) {
+	a := newTestApp(&mockGateway{})
+
+	err := newMQTTCmd(a).Run(t.Context(), []string{cmdMQTT, "--broker", "http://broker"})
+	require.ErrorIs(t, err, ErrMQTTBroker)
+
+	broker := newFakeBroker(t, packets.ErrRefusedNotAuthorised)
+	err = newMQTTCmd(a).Run(t.Context(),
+		[]string{cmdMQTT, "--broker", broker.url("user:hunter2@").String()})
+	require.ErrorIs(t, err, ErrMQTTRefused)
+	assert.Equal(t, "user:"+redactedSecret+"@", a.secrets.redact("user:hunter2@"))
+}
@@ -0,0 +1,503 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	burntsushi "github.com/BurntSushi/toml"
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+// Notification events.
+const (
+	eventWebInterfaceDown = "web_interface_down"
+	eventWebInterfaceUp   = "web_interface_up"
+	eventRegistration     = "registration_changed"
+	eventSignalQuality    = "signal_quality"
+	eventCellChanged      = "cell_changed"
+	eventReboot           = "reboot"
+)
+
+// Notification hook types.
+const (
+	notifyWebhook = "webhook"
+	notifyCommand = "command"
+	notifyNtfy    = "ntfy"
+	notifyGotify  = "gotify"
+)
+
+const (
+	gotifyPriority       = 5
+	gotifyUrgentPriority = 8
+	// maxNotifyErrorBody bounds how much of a failed response is shown.
+	maxNotifyErrorBody = 512
+)
+
+//nolint:gochecknoglobals
+var notifyEvents = []string{
+	eventWebInterfaceDown, eventWebInterfaceUp, eventRegistration, eventSignalQuality,
+	eventCellChanged, eventReboot,
+}
+
+// ErrNotifyConfig is returned when a [[notify]] entry of the configuration
+// file is not usable.
+var ErrNotifyConfig = errors.New("invalid notify entry")
+
+var errNotifyHTTPStatus = errors.New("notification rejected")
+
+// notifyEvent is a change of the gateway state worth telling someone about.
+type notifyEvent struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Gateway string    `json:"gateway"`
+	Message string    `json:"message"`
+	// Network and Metric are set for signal quality changes, Network and
+	// Changes for cell changes.
+	Network string   `json:"network,omitempty"`
+	Metric  string   `json:"metric,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+	From    string   `json:"from,omitempty"`
+	To      string   `json:"to,omitempty"`
+}
+
+// urgent reports whether the event means the gateway is not working.
+func (e notifyEvent) urgent() bool {
+	return e.Type == eventWebInterfaceDown
+}
+
+// env returns the event as TMHI_EVENT_* environment variables.
+func (e notifyEvent) env() []string {
+	return []string{
+		"TMHI_EVENT_TYPE=" + e.Type,
+		"TMHI_EVENT_TIME=" + e.Time.Format(time.RFC3339),
+		"TMHI_EVENT_GATEWAY=" + e.Gateway,
+		"TMHI_EVENT_MESSAGE=" + e.Message,
+		"TMHI_EVENT_NETWORK=" + e.Network,
+		"TMHI_EVENT_METRIC=" + e.Metric,
+		"TMHI_EVENT_CHANGES=" + strings.Join(e.Changes, ","),
+		"TMHI_EVENT_FROM=" + e.From,
+		"TMHI_EVENT_TO=" + e.To,
+	}
+}
+
+// notifyConfig is a [[notify]] entry of the configuration file.
+type notifyConfig struct {
+	Type    string `toml:"type"`
+	URL     string `toml:"url"`
+	Command string `toml:"command"`
+	Token   string `toml:"token"`
+	// Events limits the hook to these events; all of them when empty.
+	Events []string `toml:"events"`
+}
+
+// readNotifyConfigs returns the [[notify]] entries of the configuration
+// file at path. A missing file has none.
+func readNotifyConfigs(path string) ([]notifyConfig, error) {
+	var doc struct {
+		Notify []notifyConfig `toml:"notify"`
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	if _, err := burntsushi.DecodeFile(path, &doc); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return doc.Notify, nil
+}
+
+// notifyTarget delivers an event somewhere.
+type notifyTarget interface {
+	send(ctx context.Context, event notifyEvent) error
+}
+
+// notifyHook is a target and the events it is sent.
+type notifyHook struct {
+	kind   string
+	events []string
+	target notifyTarget
+}
+
+func (h notifyHook) wants(event notifyEvent) bool {
+	return len(h.events) == 0 || slices.Contains(h.events, event.Type)
+}
+
+// newNotifyHook builds the hook described by config.
+func newNotifyHook(config notifyConfig, client *http.Client) (notifyHook, error) {
+	for _, event := range config.Events {
+		if !slices.Contains(notifyEvents, event) {
+			return notifyHook{}, fmt.Errorf("%w: unknown event %q, must be one of %s",
+				ErrNotifyConfig, event, strings.Join(notifyEvents, ", "))
+		}
+	}
+
+	hook := notifyHook{kind: config.Type, events: config.Events}
+
+	switch {
+	case config.Type == notifyCommand && config.Command != "":
+		hook.target = commandTarget{command: config.Command}
+	case config.Type == notifyCommand:
+		return notifyHook{}, fmt.Errorf("%w: %s needs a command", ErrNotifyConfig, config.Type)
+	case config.URL == "":
+		return notifyHook{}, fmt.Errorf("%w: type must be %s, %s, %s or %s with a url",
+			ErrNotifyConfig, notifyWebhook, notifyCommand, notifyNtfy, notifyGotify)
+	case config.Type == notifyWebhook:
+		hook.target = webhookTarget{url: config.URL, token: config.Token, client: client}
+	case config.Type == notifyNtfy:
+		hook.target = ntfyTarget{url: config.URL, token: config.Token, client: client}
+	case config.Type == notifyGotify:
+		hook.target = gotifyTarget{url: config.URL, token: config.Token, client: client}
+	default:
+		return notifyHook{}, fmt.Errorf("%w: unknown type %q, must be %s, %s, %s or %s",
+			ErrNotifyConfig, config.Type, notifyWebhook, notifyCommand, notifyNtfy, notifyGotify)
+	}
+
+	return hook, nil
+}
+
+// notifier compares each state read from the gateway with the previous one
+// and sends the changes to its hooks. A nil notifier does nothing, so
+// commands can be run without one.
+type notifier struct {
+	hooks   []notifyHook
+	gateway string
+	timeout time.Duration
+	// onError reports a failed notification; it does not stop the caller.
+	onError func(error)
+
+	seenStatus   bool
+	webUp        bool
+	registration string
+	// quality is keyed by radio, as qualityKey returns, and metric.
+	quality map[string]signal.Quality
+}
+
+// newNotifier builds the notifier of the [[notify]] entries of the
+// configuration file, reporting failed notifications as warnings.
+func (a *app) newNotifier(cmd *cli.Command) (*notifier, error) {
+	configs, err := readNotifyConfigs(cmd.String(ConfigConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	n := &notifier{
+		gateway: a.config.IP,
+		timeout: a.config.Timeout,
+		onError: func(err error) { pterm.Warning.Println(a.secrets.redact(err.Error())) },
+		quality: map[string]signal.Quality{},
+	}
+
+	for i, config := range configs {
+		a.secrets.add(config.Token)
+
+		hook, err := newNotifyHook(config, http.DefaultClient)
+		if err != nil {
+			return nil, fmt.Errorf("notify entry %d: %w", i+1, err)
+		}
+
+		n.hooks = append(n.hooks, hook)
+	}
+
+	return n, nil
+}
+
+func (n *notifier) active() bool {
+	return n != nil && len(n.hooks) > 0
+}
+
+// status raises the web interface and registration changes of result.
+func (n *notifier) status(ctx context.Context, result *tmhi.StatusResult) {
+	if !n.active() {
+		return
+	}
+
+	var events []notifyEvent
+
+	if n.seenStatus && result.WebInterfaceUp != n.webUp {
+		event := n.newEvent(eventWebInterfaceUp, "Web interface is back up")
+		if !result.WebInterfaceUp {
+			event = n.newEvent(eventWebInterfaceDown, "Web interface is down")
+		}
+
+		events = append(events, event)
+	}
+
+	n.seenStatus = true
+	n.webUp = result.WebInterfaceUp
+
+	n.send(ctx, append(events, n.registrationEvents(result.Registration)...))
+}
+
+// signal raises the signal quality and registration changes of samples,
+// and the cell changes cellTracker found in them. An empty registration is
+// unknown rather than a change.
+func (n *notifier) signal(
+	ctx context.Context,
+	samples []signalSample,
+	cells []cellEvent,
+	registration string,
+) {
+	if !n.active() {
+		return
+	}
+
+	var events []notifyEvent
+
+	for _, sample := range samples {
+		data := &tmhi.SignalData{
+			RSRP: sample.RSRP, RSRQ: sample.RSRQ, RSSI: sample.RSSI, SINR: sample.SINR,
+		}
+
+		for _, metric := range rateSignalData(data) {
+			key := qualityKey(sample) + "/" + metric.name
+			previous, seen := n.quality[key]
+			current := metric.rating.Quality
+			n.quality[key] = current
+
+			if !seen || previous == current {
+				continue
+			}
+
+			verb := "rose"
+			if current < previous {
+				verb = "dropped"
+			}
+
+			event := n.newEvent(eventSignalQuality, fmt.Sprintf("%s %s %s from %s to %s",
+				strings.ToUpper(sample.Network), metric.name, verb, previous, current))
+			event.Network, event.Metric = sample.Network, metric.name
+			event.From, event.To = previous.String(), current.String()
+			events = append(events, event)
+		}
+	}
+
+	for _, change := range cells {
+		event := n.newEvent(eventCellChanged, change.Message)
+		event.Network, event.Changes = change.Network, change.Changes
+		event.From = describeCell(change.Network, change.From)
+		event.To = describeCell(change.Network, change.To)
+		events = append(events, event)
+	}
+
+	n.send(ctx, append(events, n.registrationEvents(registration)...))
+}
+
+// qualityKey identifies the radio of sample, so that the quality of one
+// cell or antenna is not compared with that of another.
+func qualityKey(sample signalSample) string {
+	return sample.Network + "/" + strconv.Itoa(sample.CID) + "/" + sample.Antenna
+}
+
+func (n *notifier) registrationEvents(registration string) []notifyEvent {
+	if registration == "" || registration == n.registration {
+		return nil
+	}
+
+	previous := n.registration
+	n.registration = registration
+
+	if previous == "" {
+		return nil
+	}
+
+	event := n.newEvent(eventRegistration,
+		fmt.Sprintf("Registration changed from %s to %s", previous, registration))
+	event.From, event.To = previous, registration
+
+	return []notifyEvent{event}
+}
+
+// reboot raises a reboot sent to the gateway.
+func (n *notifier) reboot(ctx context.Context) {
+	if !n.active() {
+		return
+	}
+
+	n.send(ctx, []notifyEvent{n.newEvent(eventReboot, "Reboot command sent to the gateway")})
+}
+
+func (n *notifier) newEvent(eventType, message string) notifyEvent {
+	return notifyEvent{Type: eventType, Time: time.Now(), Gateway: n.gateway, Message: message}
+}
+
+// send delivers events to the hooks that want them, one at a time so a
+// command hook sees them in order.
+func (n *notifier) send(ctx context.Context, events []notifyEvent) {
+	for _, event := range events {
+		for _, hook := range n.hooks {
+			if !hook.wants(event) {
+				continue
+			}
+
+			if err := n.sendTo(ctx, hook, event); err != nil {
+				n.onError(fmt.Errorf("%s notification of %s failed: %w",
+					hook.kind, event.Type, err))
+			}
+		}
+	}
+}
+
+func (n *notifier) sendTo(ctx context.Context, hook notifyHook, event notifyEvent) error {
+	if n.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, n.timeout)
+		defer cancel()
+	}
+
+	return hook.target.send(ctx, event)
+}
+
+// notifyTitle is the title of push notifications about gateway.
+func notifyTitle(gateway string) string {
+	return strings.TrimSpace(appName + " " + gateway)
+}
+
+// postNotification posts body to url and checks the response.
+func postNotification(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	body []byte,
+	header http.Header,
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", url, err)
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 != 2 { //nolint:mnd
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxNotifyErrorBody))
+
+		return fmt.Errorf("POST %s: %w: %s: %s",
+			url, errNotifyHTTPStatus, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func bearerHeader(header http.Header, token string) http.Header {
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return header
+}
+
+// webhookTarget posts the event as JSON.
+type webhookTarget struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (t webhookTarget) send(ctx context.Context, event notifyEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return postNotification(ctx, t.client, t.url, body,
+		bearerHeader(http.Header{"Content-Type": {"application/json"}}, t.token))
+}
+
+// ntfyTarget publishes the message to an ntfy topic URL.
+type ntfyTarget struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (t ntfyTarget) send(ctx context.Context, event notifyEvent) error {
+	header := http.Header{
+		"Title": {notifyTitle(event.Gateway)},
+		"Tags":  {event.Type},
+	}
+	if event.urgent() {
+		header.Set("Priority", "high")
+	}
+
+	return postNotification(ctx, t.client, t.url, []byte(event.Message),
+		bearerHeader(header, t.token))
+}
+
+// gotifyTarget sends the message to the /message endpoint of a Gotify
+// server, token being an application token.
+type gotifyTarget struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (t gotifyTarget) send(ctx context.Context, event notifyEvent) error {
+	priority := gotifyPriority
+	if event.urgent() {
+		priority = gotifyUrgentPriority
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"title":    notifyTitle(event.Gateway),
+		"message":  event.Message,
+		"priority": priority,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	if t.token != "" {
+		header.Set("X-Gotify-Key", t.token)
+	}
+
+	return postNotification(ctx, t.client, strings.TrimSuffix(t.url, "/")+"/message", body, header)
+}
+
+// commandTarget runs a shell command with the event in TMHI_EVENT_*
+// environment variables.
+type commandTarget struct {
+	command string
+}
+
+func (t commandTarget) send(ctx context.Context, event notifyEvent) error {
+	//nolint:gosec // the command comes from the user's own configuration
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.command)
+	cmd.Env = append(os.Environ(), event.env()...)
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", strconv.Quote(t.command), err)
+	}
+
+	return nil
+}
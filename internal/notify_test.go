@@ -0,0 +1,311 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	signal "github.com/hugoh/cellular-signal/v2"
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// recordingTarget keeps the events it is sent.
+type recordingTarget struct {
+	events []notifyEvent
+	err    error
+}
+
+func (r *recordingTarget) send(_ context.Context, event notifyEvent) error {
+	r.events = append(r.events, event)
+
+	return r.err
+}
+
+func (r *recordingTarget) types() []string {
+	types := make([]string, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func newTestNotifier(target notifyTarget, events ...string) *notifier {
+	return &notifier{
+		hooks:   []notifyHook{{kind: notifyWebhook, events: events, target: target}},
+		gateway: "192.168.12.1",
+		onError: func(error) {},
+		quality: map[string]signal.Quality{},
+	}
+}
+
+func writeNotifyConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tmhi.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestReadNotifyConfigs(t *testing.T) {
+	path := writeNotifyConfig(t, `
+[[notify]]
+type = "ntfy"
+url = "https://ntfy.sh/gateway"
+events = ["web_interface_down", "reboot"]
+
+[[notify]]
+type = "command"
+command = "logger tmhi"
+`)
+
+	configs, err := readNotifyConfigs(path)
+	require.NoError(t, err)
+	assert.Equal(t, []notifyConfig{
+		{
+			Type:   notifyNtfy,
+			URL:    "https://ntfy.sh/gateway",
+			Events: []string{eventWebInterfaceDown, eventReboot},
+		},
+		{Type: notifyCommand, Command: "logger tmhi"},
+	}, configs)
+
+	configs, err = readNotifyConfigs(filepath.Join(t.TempDir(), "missing.toml"))
+	require.NoError(t, err)
+	assert.Empty(t, configs)
+
+	_, err = readNotifyConfigs(writeNotifyConfig(t, "[[notify]\n"))
+	require.Error(t, err)
+}
+
+func TestNewNotifyHook(t *testing.T) {
+	for _, config := range []notifyConfig{
+		{Type: notifyWebhook, URL: "https://example.com/hook"},
+		{Type: notifyNtfy, URL: "https://ntfy.sh/gateway", Events: []string{eventReboot}},
+		{Type: notifyGotify, URL: "https://gotify.example.com", Token: "app"},
+		{Type: notifyCommand, Command: "true"},
+	} {
+		hook, err := newNotifyHook(config, http.DefaultClient)
+		require.NoError(t, err, config.Type)
+		assert.Equal(t, config.Type, hook.kind)
+	}
+
+	for _, config := range []notifyConfig{
+		{Type: notifyWebhook},
+		{Type: notifyCommand, URL: "https://example.com/hook"},
+		{Type: "slack", URL: "https://example.com/hook"},
+		{Type: notifyWebhook, URL: "https://example.com/hook", Events: []string{"band_changed"}},
+	} {
+		_, err := newNotifyHook(config, http.DefaultClient)
+		require.ErrorIs(t, err, ErrNotifyConfig, config)
+	}
+}
+
+func TestNotifier_Status(t *testing.T) {
+	target := &recordingTarget{}
+	n := newTestNotifier(target)
+
+	for _, result := range []*tmhi.StatusResult{
+		{WebInterfaceUp: true, Registration: "registered"},
+		{WebInterfaceUp: true, Registration: "registered"},
+		{WebInterfaceUp: false},
+		{WebInterfaceUp: true, Registration: "roaming"},
+	} {
+		n.status(t.Context(), result)
+	}
+
+	assert.Equal(t,
+		[]string{eventWebInterfaceDown, eventWebInterfaceUp, eventRegistration}, target.types())
+	assert.Equal(t, "Registration changed from registered to roaming", target.events[2].Message)
+	assert.Equal(t, "192.168.12.1", target.events[0].Gateway)
+}
+
+func TestNotifier_Signal(t *testing.T) {
+	target := &recordingTarget{}
+	n := newTestNotifier(target, eventSignalQuality)
+
+	at := time.Unix(1_790_000_000, 0)
+	good := testSample(at, network5G, []string{"n41"}, -95)
+	fair := good
+	fair.SINR = 5
+
+	n.signal(t.Context(), []signalSample{good}, nil, testRegState)
+	n.signal(t.Context(), []signalSample{good}, nil, testRegState)
+	n.signal(t.Context(), []signalSample{fair}, nil, "searching")
+	n.signal(t.Context(), []signalSample{good}, nil, "")
+
+	require.Len(t, target.events, 2, "registration changes filtered out")
+	assert.Equal(t, notifyEvent{
+		Type:    eventSignalQuality,
+		Time:    target.events[0].Time,
+		Gateway: "192.168.12.1",
+		Message: "5G SINR dropped from Good to Fair",
+		Network: network5G,
+		Metric:  "SINR",
+		From:    "Good",
+		To:      "Fair",
+	}, target.events[0])
+	assert.Equal(t, "5G SINR rose from Fair to Good", target.events[1].Message)
+
+	// Another cell of the same network has a quality of its own.
+	other := fair
+	other.CID = 2002
+	n.signal(t.Context(), []signalSample{other}, nil, "")
+	n.signal(t.Context(), []signalSample{good}, nil, "")
+	assert.Len(t, target.events, 2, "no flapping between cells")
+}
+
+func TestNotifier_CellChanged(t *testing.T) {
+	target := &recordingTarget{}
+	n := newTestNotifier(target, eventCellChanged)
+	tracker := newCellTracker()
+
+	at := time.Unix(1_790_000_000, 0)
+	before := testSample(at, network5G, []string{"n41"}, -95)
+	after := testSample(at.Add(time.Minute), network5G, []string{"n71"}, -95)
+	after.NodeID = 12345
+
+	for _, sample := range []signalSample{before, after} {
+		samples := []signalSample{sample}
+		n.signal(t.Context(), samples, tracker.track(samples), "")
+	}
+
+	require.Len(t, target.events, 1)
+
+	event := target.events[0]
+	assert.Equal(t, eventCellChanged, event.Type)
+	assert.Equal(t, network5G, event.Network)
+	assert.Equal(t, []string{cellChangeNode, cellChangeBands}, event.Changes)
+	assert.Equal(t, "gNB 67890 CID 2001 n41", event.From)
+	assert.Equal(t, "gNB 12345 CID 2001 n71", event.To)
+	assert.Equal(t, "5G moved from gNB 67890 CID 2001 n41 to gNB 12345 CID 2001 n71", event.Message)
+	assert.Contains(t, event.env(), "TMHI_EVENT_CHANGES=node_id,bands")
+}
+
+func TestNotifier_Errors(t *testing.T) {
+	var reported []error
+
+	n := newTestNotifier(&recordingTarget{err: errors.New("unreachable")})
+	n.onError = func(err error) { reported = append(reported, err) }
+
+	n.reboot(t.Context())
+	require.Len(t, reported, 1)
+	assert.EqualError(t, reported[0], "webhook notification of reboot failed: unreachable")
+
+	var nilNotifier *notifier
+
+	nilNotifier.reboot(t.Context())
+	nilNotifier.status(t.Context(), &tmhi.StatusResult{})
+	nilNotifier.signal(t.Context(), nil, nil, "")
+}
+
+func testNotifyEvent() notifyEvent {
+	return notifyEvent{
+		Type:    eventWebInterfaceDown,
+		Time:    time.Unix(1_790_000_000, 0).UTC(),
+		Gateway: "192.168.12.1",
+		Message: "Web interface is down",
+	}
+}
+
+func TestNotifyTargets_HTTP(t *testing.T) {
+	var received *http.Request
+
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+
+		if strings.HasSuffix(r.URL.Path, "/full") {
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	event := testNotifyEvent()
+
+	webhook := webhookTarget{url: server.URL + "/hook", token: "tok", client: server.Client()}
+	require.NoError(t, webhook.send(t.Context(), event))
+	assert.Equal(t, "Bearer tok", received.Header.Get("Authorization"))
+	assert.JSONEq(t, `{"type":"web_interface_down","time":"2026-09-21T14:13:20Z",`+
+		`"gateway":"192.168.12.1","message":"Web interface is down"}`, string(body))
+
+	require.NoError(t, ntfyTarget{url: server.URL + "/gateway", client: server.Client()}.
+		send(t.Context(), event))
+	assert.Equal(t, "Web interface is down", string(body))
+	assert.Equal(t, "tmhi-cli 192.168.12.1", received.Header.Get("Title"))
+	assert.Equal(t, eventWebInterfaceDown, received.Header.Get("Tags"))
+	assert.Equal(t, "high", received.Header.Get("Priority"))
+	assert.Empty(t, received.Header.Get("Authorization"))
+
+	require.NoError(t, gotifyTarget{url: server.URL + "/", token: "app", client: server.Client()}.
+		send(t.Context(), event))
+	assert.Equal(t, "/message", received.URL.Path)
+	assert.Equal(t, "app", received.Header.Get("X-Gotify-Key"))
+
+	var message map[string]any
+	require.NoError(t, json.Unmarshal(body, &message))
+	assert.Equal(t, map[string]any{
+		"title": "tmhi-cli 192.168.12.1", "message": "Web interface is down", "priority": 8.0,
+	}, message)
+
+	err := ntfyTarget{url: server.URL + "/full", client: server.Client()}.send(t.Context(), event)
+	require.ErrorIs(t, err, errNotifyHTTPStatus)
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
+
+func TestCommandTarget(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event")
+	target := commandTarget{command: `echo "$TMHI_EVENT_TYPE $TMHI_EVENT_MESSAGE" > ` + out}
+
+	require.NoError(t, target.send(t.Context(), testNotifyEvent()))
+	assert.Equal(t, "web_interface_down Web interface is down\n", readFile(t, out))
+
+	require.Error(t, commandTarget{command: "exit 3"}.send(t.Context(), testNotifyEvent()))
+}
+
+func TestReboot_Notifies(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	out := filepath.Join(t.TempDir(), "event")
+	config := writeNotifyConfig(t, `
+[[notify]]
+type = "command"
+command = "echo \"$TMHI_EVENT_TYPE\" >> `+out+`"
+`)
+
+	mg := &mockGateway{}
+	a := newTestApp(mg)
+	cmd := &cli.Command{
+		Name: "reboot",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: ConfigConfig},
+			&cli.BoolFlag{Name: ConfigAutoConfirm},
+		},
+		Action: a.reboot,
+	}
+
+	require.NoError(t, cmd.Run(t.Context(), []string{"reboot", "--config", config, "--yes"}))
+	assert.True(t, mg.rebootCalled)
+	assert.Equal(t, "reboot\n", readFile(t, out))
+
+	bad := writeNotifyConfig(t, "[[notify]]\ntype = \"pager\"\nurl = \"https://x\"\n")
+	err := cmd.Run(t.Context(), []string{"reboot", "--config", bad, "--yes"})
+	require.ErrorIs(t, err, ErrNotifyConfig)
+	assert.Contains(t, err.Error(), "notify entry 1")
+}
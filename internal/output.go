@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+)
+
+// Output formats.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// jsonEnvelope is the single document written to stdout by a command in JSON
+// output mode. Scripts check ok, then read either data or error.
+type jsonEnvelope struct {
+	OK    bool       `json:"ok"`
+	Data  any        `json:"data,omitempty"`
+	Error *jsonError `json:"error,omitempty"`
+}
+
+// jsonError describes a failure. Code names the cause, and ExitCode is the
+// exit code it leads to.
+type jsonError struct {
+	Message  string `json:"message"`
+	Code     string `json:"code"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// jsonMessage reports the outcome of commands that have no result to show,
+// such as login and reboot.
+type jsonMessage struct {
+	Message string `json:"message"`
+	DryRun  bool   `json:"dry_run,omitempty"`
+}
+
+type statusJSON struct {
+	WebInterfaceUp bool   `json:"web_interface_up"`
+	StatusCode     int    `json:"status_code,omitempty"`
+	Error          string `json:"error,omitempty"`
+	Registration   string `json:"registration,omitempty"`
+}
+
+type ratingJSON struct {
+	Value   float64 `json:"value"`
+	Unit    string  `json:"unit"`
+	Quality string  `json:"quality"`
+	Stars   string  `json:"stars"`
+}
+
+type signalDataJSON struct {
+	Bars    float64               `json:"bars"`
+	Bands   []string              `json:"bands"`
+	RSRP    int                   `json:"rsrp"`
+	RSRQ    int                   `json:"rsrq"`
+	RSSI    int                   `json:"rssi"`
+	SINR    int                   `json:"sinr"`
+	CID     int                   `json:"cid"`
+	Ratings map[string]ratingJSON `json:"ratings"`
+}
+
+type fourGJSON struct {
+	ENBID int `json:"enbid"`
+	signalDataJSON
+}
+
+type fiveGJSON struct {
+	GNBID       int    `json:"gnbid"`
+	AntennaUsed string `json:"antenna_used,omitempty"`
+	signalDataJSON
+}
+
+type genericSignalJSON struct {
+	APN          string `json:"apn"`
+	HasIPv6      bool   `json:"has_ipv6"`
+	Registration string `json:"registration"`
+	Roaming      bool   `json:"roaming"`
+}
+
+type signalJSON struct {
+	FourG   *fourGJSON        `json:"4g,omitempty"`
+	FiveG   *fiveGJSON        `json:"5g,omitempty"`
+	Generic genericSignalJSON `json:"generic"`
+	// Events are the cell changes since the previous sample, when watching.
+	Events []cellEvent `json:"events,omitempty"`
+}
+
+func (a *app) jsonOutput() bool {
+	return a.config.Output == outputJSON
+}
+
+// writeJSON writes a successful envelope carrying data to stdout.
+func (a *app) writeJSON(data any) error {
+	return a.encodeJSON(jsonEnvelope{OK: true, Data: data})
+}
+
+// writeJSONError writes a failed envelope describing err to stdout.
+func (a *app) writeJSONError(err error) error {
+	return a.encodeJSON(jsonEnvelope{Error: a.jsonErrorOf(err)})
+}
+
+// jsonErrorOf returns the JSON error of err, redacted, with its code and exit
+// code.
+func (a *app) jsonErrorOf(err error) *jsonError {
+	status := statusOf(err)
+
+	return &jsonError{
+		Message:  a.secrets.redact(err.Error()),
+		Code:     status.code,
+		ExitCode: status.exit,
+	}
+}
+
+func (a *app) encodeJSON(envelope jsonEnvelope) error {
+	if err := json.NewEncoder(a.stdout).Encode(envelope); err != nil {
+		return fmt.Errorf("failed to write JSON output: %w", err)
+	}
+
+	return nil
+}
+
+// report writes msg as the command's JSON document. Text mode relies on the
+// spinner and pterm messages instead, so it writes nothing.
+func (a *app) report(msg jsonMessage) error {
+	if !a.jsonOutput() {
+		return nil
+	}
+
+	return a.writeJSON(msg)
+}
+
+// present returns the display function for a fetched result: text renders
+// it for humans, while JSON mode writes the document built by toJSON.
+func present[T any](a *app, text func(T), toJSON func(T) any) func(T) {
+	if !a.jsonOutput() {
+		return text
+	}
+
+	return func(result T) {
+		if err := a.writeJSON(toJSON(result)); err != nil {
+			pterm.Error.Println(err)
+		}
+	}
+}
+
+func statusToJSON(result *tmhi.StatusResult) any {
+	doc := statusJSON{
+		WebInterfaceUp: result.WebInterfaceUp,
+		StatusCode:     result.StatusCode,
+		Registration:   result.Registration,
+	}
+	if result.Error != nil {
+		doc.Error = result.Error.Error()
+	}
+
+	return doc
+}
+
+func signalDataToJSON(metrics *tmhi.SignalData) signalDataJSON {
+	doc := signalDataJSON{
+		Bars:    metrics.Bars,
+		Bands:   metrics.Bands,
+		RSRP:    metrics.RSRP,
+		RSRQ:    metrics.RSRQ,
+		RSSI:    metrics.RSSI,
+		SINR:    metrics.SINR,
+		CID:     metrics.CID,
+		Ratings: make(map[string]ratingJSON, signalMetricsCount),
+	}
+
+	for _, metric := range rateSignalData(metrics) {
+		doc.Ratings[strings.ToLower(metric.name)] = ratingJSON{
+			Value:   metric.rating.Value,
+			Unit:    metric.rating.Metric.Unit(),
+			Quality: metric.rating.Quality.String(),
+			Stars:   metric.rating.Quality.Stars(),
+		}
+	}
+
+	return doc
+}
+
+func signalToJSON(result *tmhi.SignalResult) any {
+	return newSignalJSON(result)
+}
+
+func newSignalJSON(result *tmhi.SignalResult) signalJSON {
+	doc := signalJSON{
+		Generic: genericSignalJSON{
+			APN:          result.Generic.APN,
+			HasIPv6:      result.Generic.HasIPv6,
+			Registration: result.Generic.Registration,
+			Roaming:      result.Generic.Roaming,
+		},
+	}
+
+	if result.FourG != nil {
+		doc.FourG = &fourGJSON{
+			ENBID:          result.FourG.ENBID,
+			signalDataJSON: signalDataToJSON(&result.FourG.SignalData),
+		}
+	}
+
+	if result.FiveG != nil {
+		doc.FiveG = &fiveGJSON{
+			GNBID:          result.FiveG.GNBID,
+			AntennaUsed:    result.FiveG.AntennaUsed,
+			signalDataJSON: signalDataToJSON(&result.FiveG.SignalData),
+		}
+	}
+
+	return doc
+}
+
+func infoToJSON(result *tmhi.InfoResult) any {
+	return bodyToJSON(result.String())
+}
+
+// bodyToJSON embeds a gateway response as-is when it is already JSON, and as
+// a string otherwise.
+func bodyToJSON(body string) any {
+	if json.Valid([]byte(body)) {
+		return json.RawMessage(body)
+	}
+
+	return body
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	capturer "github.com/zenizh/go-capturer"
+)
+
+// newJSONTestApp returns a test app in JSON output mode whose stdout is
+// captured in the returned buffer.
+func newJSONTestApp(gw tmhi.Gateway) (*app, *bytes.Buffer) {
+	a := newTestApp(gw)
+	a.config.Output = outputJSON
+
+	var buf bytes.Buffer
+
+	a.stdout = &buf
+
+	return a, &buf
+}
+
+// decodeEnvelope decodes a single JSON envelope, keeping data raw so each
+// test can decode it into the shape it expects.
+func decodeEnvelope(t *testing.T, buf *bytes.Buffer) (bool, json.RawMessage, *jsonError) {
+	t.Helper()
+
+	var envelope struct {
+		OK    bool            `json:"ok"`
+		Data  json.RawMessage `json:"data"`
+		Error *jsonError      `json:"error"`
+	}
+
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &envelope), buf.String())
+
+	return envelope.OK, envelope.Data, envelope.Error
+}
+
+func TestPresent_TextModeUsesTextDisplay(t *testing.T) {
+	a := newTestApp(nil)
+	called := false
+
+	display := present(a, func(string) { called = true }, func(string) any { return nil })
+	display("x")
+
+	assert.True(t, called)
+}
+
+func TestSignalToJSON(t *testing.T) {
+	result := &tmhi.SignalResult{
+		FiveG: &tmhi.FiveGSignal{
+			GNBID:       67890,
+			AntennaUsed: "external",
+			SignalData: tmhi.SignalData{
+				Bars:  4,
+				Bands: []string{"n41"},
+				RSRP:  -95,
+				RSRQ:  -9,
+				RSSI:  -65,
+				SINR:  12,
+				CID:   2001,
+			},
+		},
+		Generic: tmhi.GenericSignalInfo{APN: testAPN, Registration: testRegState},
+	}
+
+	doc, ok := signalToJSON(result).(signalJSON)
+	require.True(t, ok)
+	assert.Nil(t, doc.FourG)
+	require.NotNil(t, doc.FiveG)
+	assert.Equal(t, 67890, doc.FiveG.GNBID)
+	assert.Equal(t, -95, doc.FiveG.RSRP)
+	assert.Equal(t, testAPN, doc.Generic.APN)
+
+	for _, name := range []string{"rsrp", "rsrq", "rssi", "sinr"} {
+		require.Contains(t, doc.FiveG.Ratings, name)
+		assert.NotEmpty(t, doc.FiveG.Ratings[name].Quality)
+	}
+
+	encoded, err := json.Marshal(doc)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"5g":{"gnbid":67890`)
+	assert.NotContains(t, string(encoded), `"4g"`)
+}
+
+func TestStatusToJSON(t *testing.T) {
+	doc, ok := statusToJSON(&tmhi.StatusResult{
+		StatusCode: 503,
+		Error:      errors.New("connection refused"),
+	}).(statusJSON)
+	require.True(t, ok)
+	assert.False(t, doc.WebInterfaceUp)
+	assert.Equal(t, 503, doc.StatusCode)
+	assert.Equal(t, "connection refused", doc.Error)
+}
+
+func TestBodyToJSON(t *testing.T) {
+	t.Run("JSON body is embedded", func(t *testing.T) {
+		assert.Equal(t, json.RawMessage(`{"a":1}`), bodyToJSON(`{"a":1}`))
+	})
+
+	t.Run("other body is a string", func(t *testing.T) {
+		assert.Equal(t, "plain", bodyToJSON("plain"))
+	})
+}
+
+func TestJSONOutput_Commands(t *testing.T) {
+	t.Run("signal writes one document", func(t *testing.T) {
+		a, buf := newJSONTestApp(&mockGateway{})
+
+		require.NoError(t, a.signal(t.Context(), nil))
+
+		ok, data, jsonErr := decodeEnvelope(t, buf)
+		assert.True(t, ok)
+		assert.Nil(t, jsonErr)
+		assert.Contains(t, string(data), `"generic"`)
+	})
+
+	t.Run("status writes one document", func(t *testing.T) {
+		a, buf := newJSONTestApp(&mockGateway{})
+
+		require.NoError(t, a.status(t.Context(), nil))
+
+		ok, data, _ := decodeEnvelope(t, buf)
+		assert.True(t, ok)
+		assert.Contains(t, string(data), `"web_interface_up":true`)
+	})
+
+	t.Run("login reports success", func(t *testing.T) {
+		a, buf := newJSONTestApp(&mockGateway{})
+
+		require.NoError(t, a.login(t.Context(), nil))
+
+		ok, data, _ := decodeEnvelope(t, buf)
+		assert.True(t, ok)
+		assert.Contains(t, string(data), "Successfully logged in")
+	})
+
+	t.Run("reboot dry-run is flagged", func(t *testing.T) {
+		pterm.DisableOutput()
+		t.Cleanup(pterm.EnableOutput)
+
+		mg := &mockGateway{}
+		a, buf := newJSONTestApp(mg)
+		a.config.DryRun = true
+
+		require.NoError(t, a.reboot(t.Context(), newRebootCmd(true, true)))
+
+		ok, data, _ := decodeEnvelope(t, buf)
+		assert.True(t, ok)
+		assert.Contains(t, string(data), `"dry_run":true`)
+		assert.False(t, mg.rebootCalled)
+	})
+
+	t.Run("failure writes nothing to stdout", func(t *testing.T) {
+		a, buf := newJSONTestApp(&mockGateway{signalErr: errors.New("signal boom")})
+
+		require.Error(t, a.signal(t.Context(), nil))
+		assert.Empty(t, buf.String())
+	})
+}
+
+func TestWriteJSONError(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+
+	require.NoError(t, a.writeJSONError(errors.New("boom")))
+
+	ok, data, jsonErr := decodeEnvelope(t, buf)
+	assert.False(t, ok)
+	assert.Empty(t, data)
+	require.NotNil(t, jsonErr)
+	assert.Equal(t, jsonError{Message: "boom", Code: "error", ExitCode: ExitFailure}, *jsonErr)
+
+	buf.Reset()
+	require.NoError(t, a.writeJSONError(fmt.Errorf("failed to login: %w", ErrAuthFailed)))
+
+	_, _, jsonErr = decodeEnvelope(t, buf)
+	require.NotNil(t, jsonErr)
+	assert.Equal(t, "auth_failed", jsonErr.Code)
+	assert.Equal(t, ExitAuthFailed, jsonErr.ExitCode)
+}
+
+func TestCmd_JSONError(t *testing.T) {
+	oldArgs := os.Args
+	os.Args = []string{appName, "--output", outputJSON, "login"}
+
+	t.Cleanup(func() {
+		os.Args = oldArgs
+
+		pterm.SetDefaultOutput(os.Stdout)
+	})
+
+	var err error
+
+	out := capturer.CaptureOutput(func() {
+		err = Cmd("test-version")
+	})
+
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	assert.Contains(t, out, `{"ok":false,"error":{"message":"invalid configuration`)
+	assert.Contains(t, out, `"code":"invalid_config","exit_code":10}`)
+	assert.Equal(t, ExitInvalidConfig, ExitCode(err))
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	toml "github.com/urfave/cli-altsrc/v3/toml"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	profilesKey        = "profiles"
+	defaultProfileKey  = "default_profile"
+	passwordFileKey    = ConfigLogin + "password_file"
+	passwordCommandKey = ConfigLogin + "password_command"
+	envPassword        = "TMHI_PASSWORD"
+)
+
+// ErrUnknownProfile is returned when the selected profile has no section in
+// the configuration file.
+var ErrUnknownProfile = errors.New("profile not found in configuration file")
+
+// profileValueSource looks a key up in the [profiles.<name>] section of the
+// configuration file, name being the profile selected when the lookup runs.
+type profileValueSource struct {
+	key     string
+	profile *string
+	source  altsrc.Sourcer
+}
+
+func (p *profileValueSource) path() string {
+	return profilesKey + "." + *p.profile + "." + p.key
+}
+
+func (p *profileValueSource) Lookup() (string, bool) {
+	if *p.profile == "" {
+		return "", false
+	}
+
+	return toml.TOML(p.path(), p.source).Lookup()
+}
+
+func (p *profileValueSource) String() string {
+	return fmt.Sprintf("toml file %q at key %q", p.source.SourceURI(), p.path())
+}
+
+func (p *profileValueSource) GoString() string {
+	return fmt.Sprintf("profileValueSource{file:%q,keyPath:%q}", p.source.SourceURI(), p.path())
+}
+
+// configSources returns where the flag name reads its value from when not
+// given on the command line: its TMHI_* environment variable and any alias,
+// then the selected profile's profileKey, then key at the top level of the
+// configuration file.
+func (a *app) configSources(
+	name, key, profileKey string,
+	configSource altsrc.Sourcer,
+	envAliases ...string,
+) cli.ValueSourceChain {
+	envVars := append([]string{envVarName(name)}, envAliases...)
+
+	return a.tracked(name,
+		settingSources(key, profileKey, &a.config.Profile, configSource, envVars...))
+}
+
+// settingSources is configSources for the profile named by *profile.
+func settingSources(
+	key, profileKey string,
+	profile *string,
+	configSource altsrc.Sourcer,
+	envVars ...string,
+) cli.ValueSourceChain {
+	sources := make([]cli.ValueSource, 0, len(envVars)+2) //nolint:mnd
+
+	for _, name := range envVars {
+		sources = append(sources, cli.EnvVar(name))
+	}
+
+	return cli.NewValueSourceChain(append(sources,
+		&profileValueSource{key: profileKey, profile: profile, source: configSource},
+		toml.TOML(key, configSource),
+	)...)
+}
+
+// checkProfile makes sure the selected profile exists, so a typo does not
+// silently fall back to the top-level settings.
+func checkProfile(configSource altsrc.Sourcer) func(context.Context, *cli.Command, string) error {
+	return func(_ context.Context, _ *cli.Command, profile string) error {
+		if profile == "" {
+			return nil
+		}
+
+		if _, ok := toml.TOML(profilesKey+"."+profile, configSource).Lookup(); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+		}
+
+		return nil
+	}
+}
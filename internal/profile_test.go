@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+)
+
+const testProfilesConfig = `
+default_profile = "home"
+
+[gateway]
+model = "ARCADYAN"
+ip = "192.168.12.1"
+
+[login]
+password = "top-level"
+
+[profiles.home]
+model = "NOK5G21"
+ip = "192.168.12.1"
+password = "home-secret"
+
+[profiles.cabin]
+ip = "10.0.0.1"
+username = "owner"
+password = "cabin-secret"
+timeout = "10s"
+retries = 3
+`
+
+// runWithConfig parses args against the global flags with the given
+// configuration file contents and returns the resulting Config.
+func runWithConfig(t *testing.T, contents string, args ...string) (*Config, error) {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(configFile, []byte(contents), 0o600))
+
+	a := newApp()
+	root := &cli.Command{
+		Name:  appName,
+		Flags: a.flags(&configFile, altsrc.NewStringPtrSourcer(&configFile)),
+		Action: func(context.Context, *cli.Command) error {
+			return nil
+		},
+	}
+
+	err := root.Run(context.Background(), append([]string{appName, "-c", configFile}, args...))
+
+	return a.config, err
+}
+
+func TestProfile_Default(t *testing.T) {
+	config, err := runWithConfig(t, testProfilesConfig)
+	require.NoError(t, err)
+
+	assert.Equal(t, "home", config.Profile)
+	assert.Equal(t, NOK5G21, config.Model)
+	assert.Equal(t, "home-secret", config.Password)
+	assert.Equal(t, defaultUser, config.Username)
+}
+
+func TestProfile_Selected(t *testing.T) {
+	config, err := runWithConfig(t, testProfilesConfig, "--profile", "cabin")
+	require.NoError(t, err)
+
+	assert.Equal(t, "cabin", config.Profile)
+	assert.Equal(t, "10.0.0.1", config.IP)
+	assert.Equal(t, "owner", config.Username)
+	assert.Equal(t, "cabin-secret", config.Password)
+	assert.Equal(t, 10*time.Second, config.Timeout)
+	assert.Equal(t, 3, config.Retries)
+	// Not set in the profile: falls back to the top-level setting.
+	assert.Equal(t, ARCADYAN, config.Model)
+}
+
+func TestProfile_FlagsOverrideProfile(t *testing.T) {
+	config, err := runWithConfig(t, testProfilesConfig, "-p", "cabin", "--gateway.ip", "10.0.0.2")
+	require.NoError(t, err)
+
+	assert.Equal(t, "10.0.0.2", config.IP)
+	assert.Equal(t, "cabin-secret", config.Password)
+}
+
+func TestProfile_None(t *testing.T) {
+	config, err := runWithConfig(t, "[gateway]\nmodel = \"ARCADYAN\"\n")
+	require.NoError(t, err)
+
+	assert.Empty(t, config.Profile)
+	assert.Equal(t, ARCADYAN, config.Model)
+	assert.Equal(t, defaultIP, config.IP)
+}
+
+func TestProfile_Unknown(t *testing.T) {
+	_, err := runWithConfig(t, testProfilesConfig, "--profile", "office")
+
+	require.ErrorIs(t, err, ErrUnknownProfile)
+	assert.Contains(t, err.Error(), `"office"`)
+}
+
+func TestPassword_Sources(t *testing.T) {
+	t.Run("environment overrides the configuration file", func(t *testing.T) {
+		t.Setenv(envPassword, "from-env")
+
+		config, err := runWithConfig(t, testProfilesConfig)
+		require.NoError(t, err)
+		assert.Equal(t, "from-env", config.Password)
+	})
+
+	t.Run("flag overrides the environment", func(t *testing.T) {
+		t.Setenv(envPassword, "from-env")
+
+		config, err := runWithConfig(t, testProfilesConfig, "--login.password", "from-flag")
+		require.NoError(t, err)
+		assert.Equal(t, "from-flag", config.Password)
+	})
+
+	t.Run("password file and command from the profile", func(t *testing.T) {
+		config, err := runWithConfig(t, `
+[login]
+password_command = "pass show tmhi"
+
+[profiles.home]
+password_file = "/run/secrets/tmhi"
+`, "--profile", "home")
+		require.NoError(t, err)
+		assert.Empty(t, config.Password)
+		assert.Equal(t, "/run/secrets/tmhi", config.PasswordFile)
+		assert.Equal(t, "pass show tmhi", config.PasswordCommand)
+	})
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+// ptermInput asks for a line of text, offering defaultVal. Secret input is
+// masked as it is typed.
+func ptermInput(ctx context.Context, msg, defaultVal string, secret bool) (string, error) {
+	return showInteractive(ctx, func() (string, error) {
+		input := pterm.DefaultInteractiveTextInput.WithDefaultValue(defaultVal)
+		if secret {
+			input = input.WithMask("*")
+		}
+
+		return input.Show(msg) //nolint:wrapcheck
+	})
+}
+
+// ptermSelect asks to pick one of options, defaultVal being preselected.
+func ptermSelect(
+	ctx context.Context,
+	msg string,
+	options []string,
+	defaultVal string,
+) (string, error) {
+	return showInteractive(ctx, func() (string, error) {
+		selector := pterm.DefaultInteractiveSelect.
+			WithOptions(options).
+			WithDefaultOption(defaultVal)
+
+		return selector.Show(msg) //nolint:wrapcheck
+	})
+}
+
+// showInteractive runs an interactive pterm prompt, returning early with
+// ctx.Err() if ctx is cancelled before the user answers. Like ptermConfirm,
+// the prompt keeps blocking on stdin in the background.
+func showInteractive[T any](ctx context.Context, show func() (T, error)) (T, error) {
+	type answer struct {
+		value T
+		err   error
+	}
+
+	answerCh := make(chan answer, 1)
+
+	go func() {
+		value, err := show()
+		if err != nil {
+			err = fmt.Errorf("prompt failed: %w", err)
+		}
+
+		answerCh <- answer{value: value, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		var zero T
+
+		return zero, ctx.Err() //nolint:wrapcheck
+	case a := <-answerCh:
+		return a.value, a.err
+	}
+}
@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultRecordInterval = 30 * time.Second
+	historyFilePerm       = 0o600
+	bandsSeparator        = " "
+)
+
+// ErrHistoryRecord is returned when a line of the history file cannot be
+// parsed.
+var ErrHistoryRecord = errors.New("malformed history record")
+
+//nolint:gochecknoglobals
+var historyHeader = []string{
+	"time", "network", "bands", "cid", "node_id", "antenna",
+	"bars", "rsrp", "rsrq", "rssi", "sinr",
+}
+
+func defaultHistoryPath() string {
+	const historyFileName = ".tmhi-cli-history.csv"
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return historyFileName
+	}
+
+	return filepath.Join(home, historyFileName)
+}
+
+// historyStore keeps signal samples in a CSV file, one radio per line.
+type historyStore struct {
+	path string
+}
+
+// append adds samples to the end of the file, creating it with a header
+// line first if needed.
+func (s historyStore) append(samples []signalSample) error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, historyFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+
+	if info.Size() == 0 {
+		_ = writer.Write(historyHeader)
+	}
+
+	for _, sample := range samples {
+		_ = writer.Write(sampleToRecord(sample))
+	}
+
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	return nil
+}
+
+// read returns the samples taken in [since, until). A zero bound is open.
+func (s historyStore) read(since, until time.Time) ([]signalSample, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(historyHeader)
+
+	var samples []signalSample
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return samples, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+
+		if line == 1 && record[0] == historyHeader[0] {
+			continue
+		}
+
+		sample, err := recordToSample(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+
+		if (since.IsZero() || !sample.Time.Before(since)) &&
+			(until.IsZero() || sample.Time.Before(until)) {
+			samples = append(samples, sample)
+		}
+	}
+}
+
+func sampleToRecord(sample signalSample) []string {
+	return []string{
+		sample.Time.UTC().Format(time.RFC3339),
+		sample.Network,
+		strings.Join(sample.Bands, bandsSeparator),
+		strconv.Itoa(sample.CID),
+		strconv.Itoa(sample.NodeID),
+		sample.Antenna,
+		strconv.FormatFloat(sample.Bars, 'f', -1, 64),
+		strconv.Itoa(sample.RSRP),
+		strconv.Itoa(sample.RSRQ),
+		strconv.Itoa(sample.RSSI),
+		strconv.Itoa(sample.SINR),
+	}
+}
+
+func recordToSample(record []string) (signalSample, error) {
+	at, err := time.Parse(time.RFC3339, record[0])
+	if err != nil {
+		return signalSample{}, fmt.Errorf("%w: %w", ErrHistoryRecord, err)
+	}
+
+	sample := signalSample{
+		Time:    at,
+		Network: record[1],
+		Bands:   strings.Fields(record[2]),
+		Antenna: record[5],
+	}
+
+	sample.Bars, err = strconv.ParseFloat(record[6], 64)
+	if err != nil {
+		return signalSample{}, fmt.Errorf("%w: %w", ErrHistoryRecord, err)
+	}
+
+	fields := []struct {
+		value *int
+		raw   string
+	}{
+		{&sample.CID, record[3]},
+		{&sample.NodeID, record[4]},
+		{&sample.RSRP, record[7]},
+		{&sample.RSRQ, record[8]},
+		{&sample.RSSI, record[9]},
+		{&sample.SINR, record[10]},
+	}
+	for _, field := range fields {
+		if *field.value, err = strconv.Atoi(field.raw); err != nil {
+			return signalSample{}, fmt.Errorf("%w: %w", ErrHistoryRecord, err)
+		}
+	}
+
+	return sample, nil
+}
+
+// recordSignal fetches the signal once and writes it to out.
+func recordSignal(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	out sink,
+	now time.Time,
+) ([]signalSample, error) {
+	result, err := gateway.Signal(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signal: %w", err)
+	}
+
+	samples := samplesFromSignal(now, result)
+
+	return samples, out.write(ctx, samples)
+}
+
+func (a *app) record(ctx context.Context, cmd *cli.Command) error {
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	store := historyStore{path: cmd.String(ConfigDB)}
+	interval := cmd.Duration(ConfigInterval)
+	specs := cmd.StringSlice(ConfigSink)
+
+	sinks, err := a.newSinks(cmd)
+	if err != nil {
+		return err
+	}
+
+	sinks = append(multiSink{store}, sinks...)
+
+	notify, err := a.newNotifier(cmd)
+	if err != nil {
+		return err
+	}
+
+	pterm.Info.Printfln("Recording signal every %s to %s", interval,
+		strings.Join(append([]string{store.path}, specs...), ", "))
+
+	tracker := newCellTracker()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		samples, err := recordSignal(ctx, gateway, sinks, time.Now())
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// A failed output still leaves the samples in the others.
+		if err != nil {
+			pterm.Warning.Println(err)
+		}
+
+		if samples != nil {
+			events := tracker.track(samples)
+			notify.signal(ctx, samples, events, "")
+
+			if err := a.reportRecorded(samples, events); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// cellEventsJSON is the JSON document of the cell changes found while
+// recording, written after the samples that raised them.
+type cellEventsJSON struct {
+	Events []cellEvent `json:"events"`
+}
+
+func (a *app) reportRecorded(samples []signalSample, events []cellEvent) error {
+	if !a.jsonOutput() {
+		pterm.Info.Printfln("Recorded %d samples", len(samples))
+
+		for _, event := range events {
+			pterm.Info.Println(event)
+		}
+
+		return nil
+	}
+
+	if err := a.writeJSON(samples); err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	return a.writeJSON(cellEventsJSON{Events: events})
+}
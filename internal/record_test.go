@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func newTestHistoryStore(t *testing.T) historyStore {
+	t.Helper()
+
+	return historyStore{path: filepath.Join(t.TempDir(), "history.csv")}
+}
+
+func testSample(at time.Time, network string, bands []string, rsrp int) signalSample {
+	return signalSample{
+		Time:    at,
+		Network: network,
+		Bands:   bands,
+		CID:     2001,
+		NodeID:  67890,
+		Bars:    3.5,
+		RSRP:    rsrp,
+		RSRQ:    -9,
+		RSSI:    -65,
+		SINR:    12,
+	}
+}
+
+func TestHistoryStore_RoundTrip(t *testing.T) {
+	store := newTestHistoryStore(t)
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	first := testSample(base, network5G, []string{"n41", "n71"}, -95)
+	first.Antenna = "external"
+	second := testSample(base.Add(time.Minute), network4G, []string{"b2"}, -100)
+
+	require.NoError(t, store.append([]signalSample{first}))
+	require.NoError(t, store.append([]signalSample{second}))
+
+	content, err := os.ReadFile(store.path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "time,network"), "header written once")
+
+	info, err := os.Stat(store.path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(historyFilePerm), info.Mode().Perm())
+
+	samples, err := store.read(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []signalSample{first, second}, samples)
+
+	samples, err = store.read(base.Add(time.Second), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []signalSample{second}, samples)
+
+	samples, err = store.read(time.Time{}, base.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []signalSample{first}, samples)
+}
+
+func TestHistoryStore_Errors(t *testing.T) {
+	store := newTestHistoryStore(t)
+
+	_, err := store.read(time.Time{}, time.Time{})
+	require.Error(t, err, "missing file")
+
+	require.NoError(t, os.WriteFile(store.path, []byte("bad,1,2,3,4,5,6,7,8,9,10\n"), 0o600))
+
+	_, err = store.read(time.Time{}, time.Time{})
+	require.ErrorIs(t, err, ErrHistoryRecord)
+}
+
+func TestRecordSignal(t *testing.T) {
+	store := newTestHistoryStore(t)
+
+	samples, err := recordSignal(t.Context(), &mockGateway{signalResult: testSignalResult()},
+		store, time.Now())
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+
+	_, err = recordSignal(t.Context(), &mockGateway{signalErr: errors.New("down")},
+		store, time.Now())
+	require.Error(t, err)
+
+	stored, err := store.read(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, stored, 1)
+}
+
+func TestRecordCommand(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	store := newTestHistoryStore(t)
+	a := newTestApp(&mockGateway{signalResult: testSignalResult()})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	cmd := &cli.Command{Name: cmdRecord, Flags: recordFlags(), Action: a.record}
+	err := cmd.Run(ctx, []string{cmdRecord, "--db", store.path, "--interval", "1ms"})
+	require.NoError(t, err)
+
+	stored, err := store.read(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, stored)
+}
+
+func TestRecordCommand_CellEventsJSON(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	store := newTestHistoryStore(t)
+	a, buf := newJSONTestApp(nil)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	gw := &sequenceGateway{
+		mockGateway: &mockGateway{},
+		cancel:      cancel,
+		results:     []*tmhi.SignalResult{cellResult(123, 1, "n41"), cellResult(123, 2, "n41")},
+	}
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) { return gw, nil }
+
+	cmd := &cli.Command{Name: cmdRecord, Flags: recordFlags(), Action: a.record}
+	require.NoError(t, cmd.Run(ctx, []string{cmdRecord, "--db", store.path, "--interval", "1ms"}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3, "two samples, then the event")
+	assert.Contains(t, lines[2], `"changes":["cid"]`)
+	assert.Contains(t, lines[2], "5G moved from gNB 123 CID 1 n41 to gNB 123 CID 2 n41")
+}
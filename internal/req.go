@@ -0,0 +1,363 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	// ErrReqData is returned when req is given both --data and --data-file.
+	ErrReqData = errors.New("--" + ConfigData + " and --" + ConfigDataFile +
+		" cannot be used together")
+
+	// ErrReqHeader is returned for a --header that is not "Name: value".
+	ErrReqHeader = errors.New("header must be given as Name: value")
+
+	// ErrReqQuery is returned for a --query that is not "name=value".
+	ErrReqQuery = errors.New("query parameter must be given as name=value")
+
+	// ErrReqRaw is returned when --raw is combined with an option changing
+	// the body.
+	ErrReqRaw = errors.New("--" + ConfigRaw + " cannot be used with --" + ConfigPretty +
+		" or --" + ConfigFilter)
+
+	// ErrReqOutputJSON is returned for req output options that print the body
+	// as text with --output json.
+	ErrReqOutputJSON = errors.New("only --" + ConfigFilter + " can be used with --" +
+		ConfigOutput + " " + outputJSON)
+
+	// ErrNotJSON is returned by --pretty for a body that is not JSON.
+	ErrNotJSON = errors.New("response body is not JSON")
+
+	// ErrReqUnsupported is returned when req is given a body or headers the
+	// gateway client cannot send, or asked for a response it cannot return.
+	ErrReqUnsupported = errors.New("the gateway client cannot send a request body or headers, " +
+		"or return the whole response")
+)
+
+const reqOutputFilePerm = 0o644
+
+// withQuery appends the name=value parameters to the query of path.
+func withQuery(path string, params []string) (string, error) {
+	if len(params) == 0 {
+		return path, nil
+	}
+
+	query := make([]string, 0, len(params))
+
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return "", fmt.Errorf("%w: %q", ErrReqQuery, param)
+		}
+
+		query = append(query, url.QueryEscape(name)+"="+url.QueryEscape(value))
+	}
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return path + separator + strings.Join(query, "&"), nil
+}
+
+// parseHeaders parses curl-style "Name: value" headers.
+func parseHeaders(values []string) (http.Header, error) {
+	header := http.Header{}
+
+	for _, value := range values {
+		name, content, ok := strings.Cut(value, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: %q", ErrReqHeader, value)
+		}
+
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(content))
+	}
+
+	return header, nil
+}
+
+// readBody returns the request body of --data or --data-file, "-" reading
+// standard input.
+func readBody(cmd *cli.Command) ([]byte, bool, error) {
+	data, dataFile := cmd.String(ConfigData), cmd.String(ConfigDataFile)
+
+	switch {
+	case cmd.IsSet(ConfigData) && cmd.IsSet(ConfigDataFile):
+		return nil, false, ErrReqData
+	case cmd.IsSet(ConfigData):
+		return []byte(data), true, nil
+	case dataFile == "-":
+		body, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read body: %w", err)
+		}
+
+		return body, true, nil
+	case dataFile != "":
+		body, err := os.ReadFile(dataFile)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read body: %w", err)
+		}
+
+		return body, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// reqOptions are the body and headers req sends with its request.
+type reqOptions struct {
+	header  http.Header
+	body    []byte
+	hasBody bool
+	// response is set when the status line, headers or exact body of the
+	// response are printed.
+	response bool
+}
+
+// sends reports whether the request goes out with a body or headers of its
+// own.
+func (opts *reqOptions) sends() bool {
+	return opts.hasBody || len(opts.header) > 0
+}
+
+// newReqOptions returns the body and headers the req flags ask for.
+func newReqOptions(cmd *cli.Command) (*reqOptions, error) {
+	header, err := parseHeaders(cmd.StringSlice(ConfigHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	body, hasBody, err := readBody(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasBody && header.Get("Content-Type") == "" {
+		// As curl does, unless the body is JSON.
+		contentType := "application/x-www-form-urlencoded"
+		if json.Valid(body) {
+			contentType = "application/json"
+		}
+
+		header.Set("Content-Type", contentType)
+	}
+
+	return &reqOptions{
+		header:   header,
+		body:     body,
+		hasBody:  hasBody,
+		response: cmd.Bool(ConfigRaw) || cmd.Bool(ConfigInclude),
+	}, nil
+}
+
+// bodyRequester is implemented by the gateways that can send a request with
+// a body and headers on their authenticated session, returning the whole
+// response.
+type bodyRequester interface {
+	RequestWithBody(
+		ctx context.Context,
+		method, path string,
+		header http.Header,
+		body []byte,
+	) (*reqResponse, error)
+}
+
+// reqResponse is the response of the gateway to the request of req. Only the
+// body is known when it went through Request.
+type reqResponse struct {
+	proto  string
+	status int
+	header http.Header
+	body   []byte
+}
+
+// err returns the error matching the status of r, if it is one: the gateway
+// answers a request it does not authorize with 401 or 403.
+func (r *reqResponse) err() error {
+	switch {
+	case r.status == http.StatusUnauthorized || r.status == http.StatusForbidden:
+		return fmt.Errorf("%w: %d %s", ErrAuthFailed, r.status, http.StatusText(r.status))
+	case r.status >= http.StatusBadRequest:
+		return fmt.Errorf("%w: %d %s", ErrRequestFailed, r.status, http.StatusText(r.status))
+	default:
+		return nil
+	}
+}
+
+// fetchReq sends the request of req. It goes through RequestWithBody when it
+// has a body or headers, or when the whole response is printed, which a
+// gateway without it fails with ErrReqUnsupported rather than sending the
+// request without them or printing less.
+func fetchReq(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	method, path string,
+	opts *reqOptions,
+) (*reqResponse, error) {
+	if opts.sends() || opts.response {
+		return requestWithBody(ctx, gateway, method, path, opts)
+	}
+
+	result, err := gateway.Request(ctx, method, path)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return &reqResponse{body: []byte(result.String())}, nil
+}
+
+func requestWithBody(
+	ctx context.Context,
+	gateway tmhi.Gateway,
+	method, path string,
+	opts *reqOptions,
+) (*reqResponse, error) {
+	requester, ok := gateway.(bodyRequester)
+	if !ok {
+		return nil, ErrReqUnsupported
+	}
+
+	var body []byte
+	if opts.hasBody {
+		body = opts.body
+	}
+
+	response, err := requester.RequestWithBody(ctx, method, path, opts.header, body)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return response, response.err()
+}
+
+func displayReqResponse(response *reqResponse) {
+	pterm.DefaultBasicText.Println(string(response.body))
+}
+
+func reqResponseToJSON(response *reqResponse) any {
+	return bodyToJSON(string(response.body))
+}
+
+// reqOutputRequested reports whether req prints the response itself instead
+// of displaying it like other results.
+func reqOutputRequested(cmd *cli.Command) bool {
+	return cmd.Bool(ConfigRaw) || cmd.Bool(ConfigPretty) || cmd.Bool(ConfigInclude) ||
+		cmd.String(ConfigFilter) != "" || cmd.String(ConfigOutputFile) != ""
+}
+
+// checkReqOutput rejects output options that cannot be used together.
+func (a *app) checkReqOutput(cmd *cli.Command) error {
+	if cmd.Bool(ConfigRaw) && (cmd.Bool(ConfigPretty) || cmd.String(ConfigFilter) != "") {
+		return ErrReqRaw
+	}
+
+	if a.jsonOutput() && (cmd.Bool(ConfigRaw) || cmd.Bool(ConfigPretty) ||
+		cmd.Bool(ConfigInclude) || cmd.String(ConfigOutputFile) != "") {
+		return ErrReqOutputJSON
+	}
+
+	return nil
+}
+
+// writeReqOutput prints the response of req as its output options ask: the
+// body exactly as received with --raw, indented with --pretty, or the values
+// --filter selects, after the status line and headers with --include, to
+// standard output or --output-file.
+func (a *app) writeReqOutput(cmd *cli.Command, response *reqResponse) error {
+	body := response.body
+
+	filter := cmd.String(ConfigFilter)
+
+	if a.jsonOutput() {
+		values, err := filterJSON(body, filter)
+		if err != nil {
+			return err
+		}
+
+		if len(values) == 1 {
+			return a.writeJSON(values[0])
+		}
+
+		return a.writeJSON(values)
+	}
+
+	var out bytes.Buffer
+
+	if cmd.Bool(ConfigInclude) {
+		writeStatusAndHeaders(&out, response)
+	}
+
+	switch {
+	case filter != "":
+		values, err := filterJSON(body, filter)
+		if err != nil {
+			return err
+		}
+
+		for _, value := range values {
+			formatted, err := formatFilterValue(value, cmd.Bool(ConfigPretty))
+			if err != nil {
+				return err
+			}
+
+			out.WriteString(formatted + "\n")
+		}
+	case cmd.Bool(ConfigPretty):
+		if err := json.Indent(&out, bytes.TrimSpace(body), "", "  "); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotJSON, err)
+		}
+
+		out.WriteString("\n")
+	default:
+		out.Write(body)
+	}
+
+	path := cmd.String(ConfigOutputFile)
+	if path == "" {
+		if _, err := a.stdout.Write(out.Bytes()); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := os.WriteFile(path, out.Bytes(), reqOutputFilePerm); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+
+	pterm.Success.Printfln("Saved %d bytes to %s", out.Len(), path)
+
+	return nil
+}
+
+// writeStatusAndHeaders writes the status line and headers of response, as
+// curl --include does.
+func writeStatusAndHeaders(out *bytes.Buffer, response *reqResponse) {
+	fmt.Fprintf(out, "%s %d %s\n", response.proto, response.status,
+		http.StatusText(response.status))
+
+	for _, name := range slices.Sorted(maps.Keys(response.header)) {
+		for _, value := range response.header[name] {
+			fmt.Fprintf(out, "%s: %s\n", name, value)
+		}
+	}
+
+	out.WriteString("\n")
+}
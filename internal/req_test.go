@@ -0,0 +1,387 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestWithQuery(t *testing.T) {
+	path, err := withQuery("/TMI/v1/network/configuration/v2", []string{"get=ap", "x=a b"})
+	require.NoError(t, err)
+	assert.Equal(t, "/TMI/v1/network/configuration/v2?get=ap&x=a+b", path)
+
+	path, err = withQuery("/TMI/v1/gateway?get=all", []string{"lang=en"})
+	require.NoError(t, err)
+	assert.Equal(t, "/TMI/v1/gateway?get=all&lang=en", path)
+
+	path, err = withQuery("/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/", path)
+
+	_, err = withQuery("/", []string{"get"})
+	require.ErrorIs(t, err, ErrReqQuery)
+}
+
+func TestParseHeaders(t *testing.T) {
+	header, err := parseHeaders([]string{"Content-Type: application/json", "X-Test:a:b"})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "a:b", header.Get("X-Test"))
+
+	_, err = parseHeaders([]string{"no colon"})
+	require.ErrorIs(t, err, ErrReqHeader)
+}
+
+// sendingGateway is a mockGateway whose client can send a request with a
+// body and headers.
+type sendingGateway struct {
+	mockGateway
+
+	method, path string
+	header       http.Header
+	body         []byte
+	status       int
+	sendErr      error
+}
+
+func (g *sendingGateway) RequestWithBody(
+	_ context.Context,
+	method, path string,
+	header http.Header,
+	body []byte,
+) (*reqResponse, error) {
+	g.method, g.path, g.header, g.body = method, path, header, body
+	if g.sendErr != nil {
+		return nil, g.sendErr
+	}
+
+	status := g.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &reqResponse{
+		proto:  "HTTP/1.1",
+		status: status,
+		header: http.Header{"Content-Type": {"application/json"}},
+		body:   []byte(`{"ok":true}`),
+	}, nil
+}
+
+func TestReq_BodyHeadersAndQuery(t *testing.T) {
+	t.Run("query parameters are added to the path", func(t *testing.T) {
+		mg := &mockGateway{}
+
+		err := newReqCmd(newTestApp(mg)).Run(t.Context(), []string{
+			cmdReq, "--query", "get=ap", "GET", "/TMI/v1/network/configuration/v2",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "/TMI/v1/network/configuration/v2?get=ap", mg.requestPath)
+	})
+
+	t.Run("the body defaults to JSON or form content", func(t *testing.T) {
+		gw := &sendingGateway{}
+		a := newTestApp(gw)
+
+		require.NoError(t, newReqCmd(a).Run(t.Context(),
+			[]string{cmdReq, "--data", `{"led":false}`, "POST", "/led"}))
+		assert.Equal(t, http.MethodPost, gw.method)
+		assert.Equal(t, "/led", gw.path)
+		assert.JSONEq(t, `{"led":false}`, string(gw.body))
+		assert.Equal(t, "application/json", gw.header.Get("Content-Type"))
+		assert.False(t, gw.requestCalled)
+
+		require.NoError(t, newReqCmd(a).Run(t.Context(),
+			[]string{cmdReq, "--data", "led=off", "POST", "/led"}))
+		assert.Equal(t, "application/x-www-form-urlencoded", gw.header.Get("Content-Type"))
+	})
+
+	t.Run("body from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "body.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"ssid":"home"}`), 0o600))
+
+		gw := &sendingGateway{}
+
+		require.NoError(t, newReqCmd(newTestApp(gw)).Run(t.Context(), []string{
+			cmdReq, "--data-file", path, "-H", "Content-Type: text/plain", "PUT", "/wifi",
+		}))
+		assert.Equal(t, `{"ssid":"home"}`, string(gw.body))
+		assert.Equal(t, "text/plain", gw.header.Get("Content-Type"))
+	})
+
+	t.Run("headers without a body", func(t *testing.T) {
+		gw := &sendingGateway{}
+
+		require.NoError(t, newReqCmd(newTestApp(gw)).Run(t.Context(),
+			[]string{cmdReq, "-H", "Accept: application/json", "GET", "/"}))
+		assert.Equal(t, "application/json", gw.header.Get("Accept"))
+		assert.Nil(t, gw.body)
+	})
+
+	t.Run("plain requests go through Request", func(t *testing.T) {
+		gw := &sendingGateway{}
+
+		require.NoError(t, newReqCmd(newTestApp(gw)).Run(t.Context(), []string{cmdReq, "GET", "/"}))
+		assert.True(t, gw.requestCalled)
+		assert.Empty(t, gw.method)
+	})
+
+	t.Run("a client that cannot send them fails", func(t *testing.T) {
+		for _, args := range [][]string{{"--data", "x"}, {"-H", "X-Test: 1"}} {
+			mg := &mockGateway{}
+
+			err := newReqCmd(newTestApp(classifiedGateway{Gateway: mg})).Run(t.Context(),
+				append(append([]string{cmdReq}, args...), "POST", "/"))
+			require.ErrorIs(t, err, ErrReqUnsupported, strings.Join(args, " "))
+			assert.False(t, mg.requestCalled)
+		}
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"--data", "a", "--data-file", "b"},
+			{"-H", "nocolon"},
+			{"--query", "noequal"},
+		} {
+			mg := &mockGateway{}
+
+			err := newReqCmd(newTestApp(mg)).Run(t.Context(),
+				append(append([]string{cmdReq}, args...), "POST", "/"))
+			require.Error(t, err, strings.Join(args, " "))
+			assert.False(t, mg.requestCalled)
+		}
+	})
+}
+
+func TestReq_Response(t *testing.T) {
+	t.Run("the whole response is printed", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		gw := &sendingGateway{}
+		a := newTestApp(gw)
+		a.stdout = &buf
+
+		require.NoError(t, newReqCmd(a).Run(t.Context(), []string{cmdReq, "-i", "GET", "/"}))
+		assert.Equal(t, "HTTP/1.1 200 OK\nContent-Type: application/json\n\n{\"ok\":true}",
+			buf.String())
+	})
+
+	t.Run("a client that cannot return it fails", func(t *testing.T) {
+		for _, flag := range []string{"--raw", "--include"} {
+			mg := &mockGateway{}
+
+			err := newReqCmd(newTestApp(classifiedGateway{Gateway: mg})).Run(t.Context(),
+				[]string{cmdReq, flag, "GET", "/"})
+			require.ErrorIs(t, err, ErrReqUnsupported, flag)
+			assert.False(t, mg.requestCalled)
+		}
+	})
+
+	t.Run("error statuses", func(t *testing.T) {
+		for status, want := range map[int]error{
+			http.StatusUnauthorized:        ErrAuthFailed,
+			http.StatusForbidden:           ErrAuthFailed,
+			http.StatusNotFound:            ErrRequestFailed,
+			http.StatusInternalServerError: ErrRequestFailed,
+		} {
+			a := newTestApp(&sendingGateway{status: status})
+			a.stdout = io.Discard
+
+			err := newReqCmd(a).Run(t.Context(), []string{cmdReq, "--data", "x", "POST", "/"})
+			require.ErrorIs(t, err, want, status)
+		}
+	})
+
+	t.Run("network failures are classified", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		host := strings.TrimPrefix(server.URL, "http://")
+		server.Close()
+
+		a := newTestApp(newTappedGateway(t, &Config{IP: host}))
+
+		err := newReqCmd(a).Run(t.Context(), []string{cmdReq, "--data", "x", "POST", "/"})
+		require.ErrorIs(t, err, ErrGatewayUnreachable)
+	})
+}
+
+func TestReq_ThroughTap(t *testing.T) {
+	_, gatewayHost := simulator.Start(t, simulator.Options{
+		Model: simulator.ModelArcadyan, Password: "s3cret",
+	})
+	dir := t.TempDir()
+	gateway := newTappedGateway(t, &Config{IP: gatewayHost, Record: dir})
+
+	var buf bytes.Buffer
+
+	a := newTestApp(gateway)
+	a.stdout = &buf
+
+	require.NoError(t, newReqCmd(a).Run(t.Context(), []string{
+		cmdReq, "-i", "-H", "X-Test: yes", "--data", `{"username":"admin","password":"s3cret"}`,
+		"POST", "/TMI/v1/auth/login",
+	}))
+	assert.True(t, strings.HasPrefix(buf.String(), "HTTP/1.1 200 OK\n"), buf.String())
+	assert.Contains(t, buf.String(), "\nContent-Type: application/json\n")
+	assert.Contains(t, buf.String(), `"token"`)
+
+	files, err := trafficFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	var sent exchange
+	require.NoError(t, json.Unmarshal([]byte(readFile(t, files[0])), &sent))
+	assert.Equal(t, "yes", sent.RequestHeader.Get("X-Test"))
+	assert.Equal(t, "application/json", sent.RequestHeader.Get("Content-Type"))
+	assert.JSONEq(t, `{"username":"admin","password":"********"}`, sent.RequestBody)
+
+	err = newReqCmd(a).Run(t.Context(), []string{
+		cmdReq, "--data", `{"username":"admin","password":"wrong"}`, "POST", "/TMI/v1/auth/login",
+	})
+	require.ErrorIs(t, err, ErrAuthFailed)
+
+	buf.Reset()
+	require.NoError(t, newReqCmd(a).Run(t.Context(),
+		[]string{cmdReq, "--raw", "GET", arcadyanGatewayPath}))
+	assert.True(t, json.Valid(buf.Bytes()), buf.String())
+	assert.Contains(t, buf.String(), `"manufacturer":"Arcadyan"`, "the body as received")
+}
+
+// runReqOutput runs writeReqOutput on response with the req flags of args.
+func runReqOutput(t *testing.T, a *app, response *reqResponse, args ...string) error {
+	t.Helper()
+
+	cmd := &cli.Command{
+		Name:  cmdReq,
+		Flags: reqFlags(),
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			return a.writeReqOutput(cmd, response)
+		},
+	}
+
+	return cmd.Run(t.Context(), append([]string{cmdReq}, args...))
+}
+
+// bodyOnly is the response of a request that went through Request.
+func bodyOnly(body string) *reqResponse {
+	return &reqResponse{body: []byte(body)}
+}
+
+func TestWriteReqOutput(t *testing.T) {
+	const body = `{"signal":{"5g":{"bands":["n41"],"sinr":12.5},"4g":{"bands":["B2","B66"]}}}`
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"raw", []string{"--raw"}, body},
+		{"filter number", []string{"--filter", ".signal.5g.sinr"}, "12.5\n"},
+		{"filter string", []string{"--filter", ".signal.4g.bands[-1]"}, "B66\n"},
+		{"filter each", []string{"--filter", ".signal[].bands[0]"}, "B2\nn41\n"},
+		{"filter missing", []string{"--filter", ".signal.3g"}, "null\n"},
+		{"pretty", []string{"--pretty"}, "{\n  \"signal\": {\n    \"5g\": {\n" +
+			"      \"bands\": [\n        \"n41\"\n      ],\n      \"sinr\": 12.5\n    },\n" +
+			"    \"4g\": {\n      \"bands\": [\n        \"B2\",\n        \"B66\"\n      ]\n" +
+			"    }\n  }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			a := newTestApp(&mockGateway{})
+			a.stdout = &buf
+
+			require.NoError(t, runReqOutput(t, a, bodyOnly(body), tt.args...))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteReqOutput_Include(t *testing.T) {
+	var buf bytes.Buffer
+
+	a := newTestApp(&mockGateway{})
+	a.stdout = &buf
+
+	response := &reqResponse{
+		proto:  "HTTP/1.1",
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {"application/json"}, "Date": {"today"}},
+		body:   []byte(`{"ok":true}`),
+	}
+
+	require.NoError(t, runReqOutput(t, a, response, "-i"))
+	assert.Equal(t,
+		"HTTP/1.1 200 OK\nContent-Type: application/json\nDate: today\n\n{\"ok\":true}",
+		buf.String())
+}
+
+func TestWriteReqOutput_File(t *testing.T) {
+	var buf bytes.Buffer
+
+	a := newTestApp(&mockGateway{})
+	a.stdout = &buf
+	path := filepath.Join(t.TempDir(), "gateway.json")
+
+	require.NoError(t, runReqOutput(t, a, bodyOnly(`{"a":[1,2]}`), "-o", path, "--filter", ".a"))
+	assert.Empty(t, buf.String())
+	assert.Equal(t, "[1,2]\n", readFile(t, path))
+}
+
+func TestWriteReqOutput_JSON(t *testing.T) {
+	a, buf := newJSONTestApp(&mockGateway{})
+
+	require.NoError(t, runReqOutput(t, a, bodyOnly(`{"a":{"b":"c"}}`), "--filter", ".a"))
+
+	ok, data, _ := decodeEnvelope(t, buf)
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"b":"c"}`, string(data))
+}
+
+func TestWriteReqOutput_Errors(t *testing.T) {
+	a := newTestApp(&mockGateway{})
+	a.stdout = io.Discard
+
+	require.ErrorIs(t, runReqOutput(t, a, bodyOnly("<html>"), "--pretty"), ErrNotJSON)
+	require.ErrorIs(t, runReqOutput(t, a, bodyOnly("<html>"), "--filter", ".a"), ErrFilter)
+	require.ErrorIs(t, runReqOutput(t, a, bodyOnly(`{"a":1}`), "--filter", ".a.b"), ErrFilter)
+}
+
+func TestReq_OutputFlagConflicts(t *testing.T) {
+	for _, tt := range []struct {
+		json bool
+		args []string
+		want error
+	}{
+		{false, []string{"--raw", "--pretty"}, ErrReqRaw},
+		{false, []string{"--raw", "--filter", ".a"}, ErrReqRaw},
+		{true, []string{"--raw"}, ErrReqOutputJSON},
+		{true, []string{"-o", "out.json"}, ErrReqOutputJSON},
+	} {
+		mg := &mockGateway{}
+
+		a := newTestApp(mg)
+		if tt.json {
+			a, _ = newJSONTestApp(mg)
+		}
+
+		err := newReqCmd(a).Run(t.Context(),
+			append(append([]string{cmdReq}, tt.args...), "GET", "/"))
+		require.ErrorIs(t, err, tt.want, strings.Join(tt.args, " "))
+		assert.False(t, mg.requestCalled)
+	}
+}
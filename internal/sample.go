@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+)
+
+// Radio network names used in samples, metrics and events.
+const (
+	network4G = "4g"
+	network5G = "5g"
+)
+
+// signalSample is the signal of one radio at a point in time, flattened from
+// tmhi.SignalResult so it can be stored and compared over time.
+type signalSample struct {
+	Time    time.Time `json:"time"`
+	Network string    `json:"network"`
+	Bands   []string  `json:"bands"`
+	CID     int       `json:"cid"`
+	// NodeID is the eNB ID for 4G and the gNB ID for 5G.
+	NodeID  int     `json:"node_id"`
+	Antenna string  `json:"antenna,omitempty"`
+	Bars    float64 `json:"bars"`
+	RSRP    int     `json:"rsrp"`
+	RSRQ    int     `json:"rsrq"`
+	RSSI    int     `json:"rssi"`
+	SINR    int     `json:"sinr"`
+}
+
+// samplesFromSignal returns one sample per radio present in result.
+func samplesFromSignal(at time.Time, result *tmhi.SignalResult) []signalSample {
+	var samples []signalSample
+
+	if result.FourG != nil {
+		samples = append(samples,
+			newSignalSample(at, network4G, result.FourG.ENBID, &result.FourG.SignalData))
+	}
+
+	if result.FiveG != nil {
+		sample := newSignalSample(at, network5G, result.FiveG.GNBID, &result.FiveG.SignalData)
+		sample.Antenna = result.FiveG.AntennaUsed
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
+
+func newSignalSample(at time.Time, network string, nodeID int, data *tmhi.SignalData) signalSample {
+	return signalSample{
+		Time:    at,
+		Network: network,
+		Bands:   data.Bands,
+		CID:     data.CID,
+		NodeID:  nodeID,
+		Bars:    data.Bars,
+		RSRP:    data.RSRP,
+		RSRQ:    data.RSRQ,
+		RSSI:    data.RSSI,
+		SINR:    data.SINR,
+	}
+}
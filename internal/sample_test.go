@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplesFromSignal(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	result := testSignalResult()
+	result.FiveG.AntennaUsed = "external"
+	result.FourG = &tmhi.FourGSignal{ENBID: 12345, SignalData: tmhi.SignalData{RSRP: -100}}
+
+	samples := samplesFromSignal(at, result)
+
+	require.Len(t, samples, 2)
+	assert.Equal(t,
+		signalSample{Time: at, Network: network4G, NodeID: 12345, RSRP: -100}, samples[0])
+	assert.Equal(t, network5G, samples[1].Network)
+	assert.Equal(t, 67890, samples[1].NodeID)
+	assert.Equal(t, "external", samples[1].Antenna)
+	assert.Equal(t, []string{"n41", "n71"}, samples[1].Bands)
+	assert.Equal(t, 12, samples[1].SINR)
+}
+
+func TestSamplesFromSignal_Empty(t *testing.T) {
+	assert.Empty(t, samplesFromSignal(time.Now(), &tmhi.SignalResult{}))
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const redactedSecret = "********"
+
+// redactor masks known secrets, such as the resolved admin password, in the
+// text written through it so they do not leak into debug output or error
+// messages.
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// add registers secret for redaction. A nil redactor ignores it.
+func (r *redactor) add(secret string) {
+	if r == nil || secret == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.Contains(r.secrets, secret) {
+		r.secrets = append(r.secrets, secret)
+	}
+}
+
+// redact returns s with every registered secret masked.
+func (r *redactor) redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedSecret)
+	}
+
+	return s
+}
+
+// writer returns a writer redacting everything it passes on to w. Secrets
+// registered later are redacted too.
+func (r *redactor) writer(w io.Writer) io.Writer {
+	return &redactingWriter{redactor: r, w: w}
+}
+
+type redactingWriter struct {
+	redactor *redactor
+	w        io.Writer
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.redact(string(p))); err != nil {
+		return 0, err //nolint:wrapcheck
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	var r redactor
+
+	assert.Equal(t, "login as admin:s3cret", r.redact("login as admin:s3cret"))
+
+	r.add("")
+	r.add("s3cret")
+	r.add("s3cret")
+
+	assert.Len(t, r.secrets, 1)
+	assert.Equal(t, "login as admin:"+redactedSecret, r.redact("login as admin:s3cret"))
+
+	var none *redactor
+
+	none.add("s3cret")
+	assert.Equal(t, "s3cret", none.redact("s3cret"))
+}
+
+func TestRedactor_Writer(t *testing.T) {
+	var (
+		r   redactor
+		buf bytes.Buffer
+	)
+
+	w := r.writer(&buf)
+	// Secrets registered after the writer was created are redacted too.
+	r.add("s3cret")
+
+	n, err := fmt.Fprint(w, "password=s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, len("password=s3cret"), n)
+	assert.Equal(t, "password="+redactedSecret, buf.String())
+}
+
+func TestWriteJSONError_Redacted(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	a.secrets.add("s3cret")
+
+	require.NoError(t, a.writeJSONError(fmt.Errorf("login with %q failed", "s3cret")))
+
+	ok, _, jsonErr := decodeEnvelope(t, buf)
+	assert.False(t, ok)
+	assert.Equal(t, `login with "`+redactedSecret+`" failed`, jsonErr.Message)
+}
@@ -0,0 +1,533 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+)
+
+const (
+	shellPrompt = "tmhi> "
+	// maxShellHistory bounds the lines kept in the history file.
+	maxShellHistory  = 500
+	shellHistoryPerm = 0o600
+
+	shellExit = "exit"
+	shellQuit = "quit"
+	shellHelp = "help"
+)
+
+var (
+	// ErrShellCommand is returned for a shell line naming no shell command.
+	ErrShellCommand = errors.New("unknown command")
+
+	// ErrShellQuote is returned for a shell line with an unterminated quote.
+	ErrShellQuote = errors.New("unterminated quote")
+)
+
+// shellMethods are completed after req.
+//
+//nolint:gochecknoglobals
+var shellMethods = []string{"DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"}
+
+// prompt reads the lines of a shell session.
+type prompt interface {
+	// ReadLine returns the next line, or io.EOF when the session ends.
+	ReadLine(ctx context.Context) (string, error)
+	Close() error
+}
+
+func defaultShellHistoryPath() string {
+	const shellHistoryFileName = ".tmhi-cli-shell-history"
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return shellHistoryFileName
+	}
+
+	return filepath.Join(home, shellHistoryFileName)
+}
+
+// newTermPrompt returns a prompt with line editing, history and completion of
+// words when standard input is a terminal, and one reading plain lines
+// otherwise, so that commands can be piped in.
+//
+//nolint:ireturn
+func newTermPrompt(words []string, historyPath string) (prompt, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return &scannerPrompt{scanner: bufio.NewScanner(os.Stdin)}, nil
+	}
+
+	history, err := loadShellHistory(historyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, shellPrompt)
+	terminal.History = history
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+
+		return completeShellLine(line, pos, words)
+	}
+
+	return &termPrompt{fd: fd, terminal: terminal}, nil
+}
+
+// termPrompt reads lines from the terminal, in raw mode only while a line is
+// being edited so that command output is written as usual.
+type termPrompt struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+// ReadLine returns early with ctx.Err() if ctx is cancelled while waiting
+// for a line, the read itself blocking on stdin in the background as in
+// ptermConfirm.
+func (p *termPrompt) ReadLine(ctx context.Context) (string, error) {
+	if width, height, err := term.GetSize(p.fd); err == nil {
+		_ = p.terminal.SetSize(width, height)
+	}
+
+	state, err := term.MakeRaw(p.fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer term.Restore(p.fd, state) //nolint:errcheck
+
+	type readResult struct {
+		line string
+		err  error
+	}
+
+	resultCh := make(chan readResult, 1)
+
+	go func() {
+		line, err := p.terminal.ReadLine()
+		resultCh <- readResult{line: line, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err() //nolint:wrapcheck
+	case r := <-resultCh:
+		return r.line, r.err
+	}
+}
+
+func (p *termPrompt) Close() error {
+	return nil
+}
+
+// scannerPrompt reads lines from a non-interactive input.
+type scannerPrompt struct {
+	scanner *bufio.Scanner
+}
+
+func (p *scannerPrompt) ReadLine(_ context.Context) (string, error) {
+	if p.scanner.Scan() {
+		return p.scanner.Text(), nil
+	}
+
+	if err := p.scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read command: %w", err)
+	}
+
+	return "", io.EOF
+}
+
+func (p *scannerPrompt) Close() error {
+	return nil
+}
+
+// shellHistory is the line history of the shell, kept in a file across
+// sessions. It implements term.History.
+type shellHistory struct {
+	path string
+	// lines are the most recent last.
+	lines []string
+}
+
+// loadShellHistory reads the last lines of the history file at path, if it
+// exists. An empty path keeps the history in memory.
+func loadShellHistory(path string) (*shellHistory, error) {
+	history := &shellHistory{path: path}
+	if path == "" {
+		return history, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shell history: %w", err)
+	}
+
+	for line := range strings.Lines(string(data)) {
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			history.lines = append(history.lines, line)
+		}
+	}
+
+	history.trim()
+
+	return history, nil
+}
+
+// Add records line, unless it repeats the previous one, and appends it to
+// the history file. The file is rewritten when it grows past the bound.
+func (h *shellHistory) Add(line string) {
+	if line == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+
+	h.lines = append(h.lines, line)
+	rewrite := h.trim()
+
+	if h.path == "" {
+		return
+	}
+
+	if err := h.save(line, rewrite); err != nil {
+		pterm.Debug.Printfln("Shell history not saved: %v", err)
+	}
+}
+
+func (h *shellHistory) Len() int {
+	return len(h.lines)
+}
+
+func (h *shellHistory) At(idx int) string {
+	return h.lines[len(h.lines)-1-idx]
+}
+
+// trim drops the oldest lines past maxShellHistory, reporting whether there
+// were any.
+func (h *shellHistory) trim() bool {
+	if len(h.lines) <= maxShellHistory {
+		return false
+	}
+
+	h.lines = slices.Clone(h.lines[len(h.lines)-maxShellHistory:])
+
+	return true
+}
+
+func (h *shellHistory) save(line string, rewrite bool) error {
+	if rewrite {
+		data := strings.Join(h.lines, "\n") + "\n"
+
+		return os.WriteFile(h.path, []byte(data), shellHistoryPerm) //nolint:wrapcheck
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, shellHistoryPerm)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		_ = file.Close()
+
+		return err //nolint:wrapcheck
+	}
+
+	return file.Close() //nolint:wrapcheck
+}
+
+// completeShellLine completes the word before pos: a command name first, an
+// HTTP method after req. A unique match is completed with a trailing space,
+// several ones up to their common prefix.
+func completeShellLine(line string, pos int, commands []string) (string, int, bool) {
+	before := line[:pos]
+	start := strings.LastIndexAny(before, " \t") + 1
+	word := before[start:]
+	previous := strings.Fields(before[:start])
+
+	var candidates []string
+
+	switch {
+	case len(previous) == 0:
+		candidates = commands
+	case len(previous) == 1 && previous[0] == cmdReq && !strings.HasPrefix(word, "-"):
+		candidates = shellMethods
+		word = strings.ToUpper(word)
+	default:
+		return "", 0, false
+	}
+
+	var matches []string
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += " "
+	}
+
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	return prefix
+}
+
+// splitShellLine splits line into arguments at unquoted blanks. Single quotes
+// keep their content as is, double quotes and backslashes escape as in a
+// POSIX shell.
+func splitShellLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+
+			escaped = false
+		case quote == '\'':
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, ErrShellQuote
+	}
+
+	if inWord {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// shellCommands are the commands of a shell session, running the actions of
+// the CLI commands of the same name.
+func (a *app) shellCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:   cmdSignal,
+			Usage:  "Display signal strength information",
+			Flags:  []cli.Flag{watchFlag()},
+			Action: a.signal,
+		},
+		{
+			Name:   cmdStatus,
+			Usage:  "Check gateway status",
+			Flags:  []cli.Flag{watchFlag()},
+			Action: a.status,
+		},
+		{
+			Name:   cmdInfo,
+			Usage:  "Get gateway information",
+			Action: a.info,
+		},
+		{
+			Name:      cmdReq,
+			Usage:     "Make a custom HTTP request to the gateway",
+			ArgsUsage: "<HTTP method> <path>",
+			Flags:     reqFlags(),
+			Action:    a.req,
+		},
+		{
+			Name:  "reboot",
+			Usage: "Reboot the router",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    ConfigAutoConfirm,
+					Aliases: []string{"y"},
+					Usage:   "skip confirmation prompts",
+				},
+			},
+			Action: a.reboot,
+		},
+		{
+			Name:   cmdLogin,
+			Usage:  "Log in again, after a reboot or once the session expired",
+			Action: a.login,
+		},
+		{
+			Name:    shellExit,
+			Aliases: []string{shellQuit},
+			Usage:   "End the session",
+		},
+	}
+}
+
+// shellWords are the words the prompt completes first.
+func shellWords(commands []*cli.Command) []string {
+	words := []string{shellHelp}
+
+	for _, command := range commands {
+		words = append(words, command.Names()...)
+	}
+
+	slices.Sort(words)
+
+	return words
+}
+
+// shell logs in once, then runs commands read at a prompt against the same
+// gateway client and session until exit, Ctrl-D or Ctrl-C at the prompt.
+func (a *app) shell(ctx context.Context, cmd *cli.Command) error {
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	if err := runWithFeedback(
+		ctx,
+		a.newSpinner,
+		"Logging in...",
+		gateway.Login,
+		"Successfully logged in",
+	); err != nil {
+		return err
+	}
+
+	session := *a
+	session.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) {
+		return gateway, nil
+	}
+
+	p, err := a.newPrompt(shellWords(session.shellCommands()), cmd.String(ConfigHistoryFile))
+	if err != nil {
+		return err
+	}
+	defer p.Close() //nolint:errcheck
+
+	// Interrupts stop the command running, not the session: the session only
+	// ends on termination. The session context does not derive from ctx, or
+	// the shell commands would be taken for subcommands of this one.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM) //nolint:contextcheck
+	defer stop()
+
+	return session.shellLoop(ctx, p)
+}
+
+func (a *app) shellLoop(ctx context.Context, p prompt) error {
+	for {
+		line, err := p.ReadLine(ctx)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		args, err := splitShellLine(line)
+		if err != nil {
+			a.reportError(err)
+
+			continue
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if args[0] == shellExit || args[0] == shellQuit {
+			return nil
+		}
+
+		if err := a.runShellCommand(ctx, args); err != nil {
+			a.reportError(err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// runShellCommand runs one shell line, until it completes or is interrupted.
+func (a *app) runShellCommand(ctx context.Context, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	root := &cli.Command{
+		Name:        cmdShell,
+		Usage:       "Commands of the interactive shell",
+		HideVersion: true,
+		Commands:    a.shellCommands(),
+		Writer:      a.stdout,
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			return fmt.Errorf("%w: %q", ErrShellCommand, cmd.Args().First())
+		},
+		OnUsageError: func(_ context.Context, cmd *cli.Command, err error, _ bool) error {
+			_, _ = fmt.Fprintf(cmd.ErrWriter, "error: %v\n", err)
+
+			return displayed(err)
+		},
+		ExitErrHandler: func(context.Context, *cli.Command, error) {},
+	}
+
+	return root.Run(ctx, append([]string{cmdShell}, args...)) //nolint:wrapcheck
+}
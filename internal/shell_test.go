@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// scriptedPrompt returns its lines in turn, then io.EOF.
+type scriptedPrompt struct {
+	lines []string
+}
+
+func (p *scriptedPrompt) ReadLine(context.Context) (string, error) {
+	if len(p.lines) == 0 {
+		return "", io.EOF
+	}
+
+	line := p.lines[0]
+	p.lines = p.lines[1:]
+
+	return line, nil
+}
+
+func (p *scriptedPrompt) Close() error {
+	return nil
+}
+
+func newShellCmd(a *app) *cli.Command {
+	return &cli.Command{
+		Name:   cmdShell,
+		Action: a.shell,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: ConfigHistoryFile},
+		},
+	}
+}
+
+func TestSplitShellLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  signal  ", []string{"signal"}},
+		{"req GET /TMI/v1/gateway?get=all", []string{"req", "GET", "/TMI/v1/gateway?get=all"}},
+		{
+			`req --filter '.signal["5g"]' GET /`,
+			[]string{"req", "--filter", `.signal["5g"]`, "GET", "/"},
+		},
+		{`a "b c" d\ e ''`, []string{"a", "b c", "d e", ""}},
+		{`"say \"hi\""`, []string{`say "hi"`}},
+	}
+
+	for _, tt := range tests {
+		args, err := splitShellLine(tt.line)
+		require.NoError(t, err, tt.line)
+		assert.Equal(t, tt.want, args, tt.line)
+	}
+
+	for _, line := range []string{`req 'GET`, `"a`, `a\`} {
+		_, err := splitShellLine(line)
+		require.ErrorIs(t, err, ErrShellQuote, line)
+	}
+}
+
+func TestCompleteShellLine(t *testing.T) {
+	words := shellWords(newApp().shellCommands())
+
+	tests := []struct {
+		line    string
+		pos     int
+		want    string
+		wantPos int
+		ok      bool
+	}{
+		{"si", 2, "signal ", 7, true},
+		{"re", 2, "re", 2, true},
+		{"req", 3, "req ", 4, true},
+		{"req g", 5, "req GET ", 8, true},
+		{"req P", 5, "req P", 5, true},
+		{"req GET /", 9, "", 0, false},
+		{"x", 1, "", 0, false},
+		{"st /x", 2, "status  /x", 7, true},
+	}
+
+	for _, tt := range tests {
+		line, pos, ok := completeShellLine(tt.line, tt.pos, words)
+		assert.Equal(t, tt.ok, ok, tt.line)
+
+		if tt.ok {
+			assert.Equal(t, tt.want, line, tt.line)
+			assert.Equal(t, tt.wantPos, pos, tt.line)
+		}
+	}
+}
+
+func TestShellHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	require.NoError(t, os.WriteFile(path, []byte("signal\n\nstatus\n"), 0o600))
+
+	history, err := loadShellHistory(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, history.Len())
+	assert.Equal(t, "status", history.At(0))
+	assert.Equal(t, "signal", history.At(1))
+
+	history.Add("info")
+	history.Add("info")
+	history.Add("")
+	assert.Equal(t, 3, history.Len())
+	assert.Equal(t, "signal\n\nstatus\ninfo\n", readFile(t, path))
+
+	for i := range maxShellHistory {
+		history.Add(fmt.Sprintf("req GET /%d", i))
+	}
+
+	assert.Equal(t, maxShellHistory, history.Len())
+	assert.Equal(t, fmt.Sprintf("req GET /%d", maxShellHistory-1), history.At(0))
+
+	reloaded, err := loadShellHistory(path)
+	require.NoError(t, err)
+	assert.Equal(t, history.lines, reloaded.lines)
+
+	inMemory, err := loadShellHistory("")
+	require.NoError(t, err)
+	inMemory.Add("signal")
+	assert.Equal(t, 1, inMemory.Len())
+}
+
+func TestShell_Session(t *testing.T) {
+	mg := &mockGateway{}
+	a := newTestApp(mg)
+	a.stdout = io.Discard
+
+	inits := 0
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) {
+		inits++
+
+		return mg, nil
+	}
+
+	var words []string
+
+	a.newPrompt = func(w []string, _ string) (prompt, error) {
+		words = w
+
+		return &scriptedPrompt{lines: []string{
+			"",
+			"signal",
+			"req --query get=all GET /TMI/v1/gateway",
+			"req --data x POST /",
+			"bogus",
+			`req "GET`,
+			"status",
+			"exit",
+			"info",
+		}}, nil
+	}
+
+	require.NoError(t, newShellCmd(a).Run(t.Context(), []string{cmdShell}))
+
+	assert.Equal(t, 1, inits, "the session reuses one gateway client")
+	assert.True(t, mg.loginCalled)
+	assert.True(t, mg.signalCalled)
+	assert.True(t, mg.statusCalled)
+	assert.Equal(t, "/TMI/v1/gateway?get=all", mg.requestPath)
+	assert.False(t, mg.infoCalled, "lines after exit are not run")
+	assert.Contains(t, words, cmdReq)
+	assert.Contains(t, words, shellQuit)
+	assert.Contains(t, words, shellHelp)
+}
+
+func TestShell_LoginFailure(t *testing.T) {
+	errLogin := errors.New("login refused")
+	mg := &mockGateway{loginErr: errLogin}
+	a := newTestApp(mg)
+	a.newPrompt = func([]string, string) (prompt, error) {
+		t.Fatal("no prompt without a session")
+
+		return nil, nil //nolint:nilnil
+	}
+
+	err := newShellCmd(a).Run(t.Context(), []string{cmdShell})
+	require.ErrorIs(t, err, errLogin)
+}
+
+func TestShell_UnknownCommand(t *testing.T) {
+	a := newTestApp(&mockGateway{})
+
+	err := a.runShellCommand(t.Context(), []string{"bogus"})
+	require.ErrorIs(t, err, ErrShellCommand)
+	assert.Contains(t, err.Error(), `"bogus"`)
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultSimulateListen   = "127.0.0.1:8080"
+	defaultSimulateDowntime = 10 * time.Second
+)
+
+// loadSamples reads the signal samples a simulated gateway serves in turn
+// from a JSON array. No path means random samples.
+func loadSamples(path string) ([]simulator.Sample, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read samples: %w", err)
+	}
+
+	var samples []simulator.Sample
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("failed to parse samples %s: %w", path, err)
+	}
+
+	return samples, nil
+}
+
+func (a *app) simulate(ctx context.Context, cmd *cli.Command) error {
+	samples, err := loadSamples(cmd.String(ConfigSamples))
+	if err != nil {
+		return err
+	}
+
+	if err := a.config.resolvePassword(ctx); err != nil {
+		return err
+	}
+
+	a.secrets.add(a.config.Password)
+
+	model := cmd.String(ConfigSimulatedModel)
+
+	sim, err := simulator.New(simulator.Options{
+		Model:    model,
+		Username: a.config.Username,
+		Password: a.config.Password,
+		Downtime: cmd.Duration(ConfigDowntime),
+		Samples:  samples,
+		Logf: func(format string, args ...any) {
+			pterm.Debug.Printfln(format, args...)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start simulator: %w", err)
+	}
+
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", cmd.String(ConfigListen))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	pterm.Info.Printfln("Simulating a %s gateway on http://%s; use --%s %s",
+		model, listener.Addr(), ConfigIP, listener.Addr())
+
+	return serveUntilDone(ctx, listener, sim)
+}
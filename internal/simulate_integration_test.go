@@ -0,0 +1,56 @@
+//go:build integration
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulator_EndToEnd drives the real gateway clients against the
+// simulator.
+func TestSimulator_EndToEnd(t *testing.T) {
+	for _, model := range []string{ARCADYAN, NOK5G21} {
+		t.Run(model, func(t *testing.T) {
+			sim, addr := simulator.Start(t, simulator.Options{
+				Model:    model,
+				Password: "s3cret",
+				Downtime: 500 * time.Millisecond,
+			})
+
+			gateway, err := initGateway(t.Context(), &Config{
+				Model:    model,
+				IP:       addr,
+				Username: defaultUser,
+				Password: "s3cret",
+				Timeout:  time.Second,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, gateway.Login(t.Context()))
+			assert.Equal(t, 1, sim.Logins())
+
+			status, err := gateway.Status(t.Context())
+			require.NoError(t, err)
+			assert.True(t, status.WebInterfaceUp)
+
+			signal, err := gateway.Signal(t.Context())
+			require.NoError(t, err)
+			assert.NotNil(t, signal.FourG)
+
+			require.NoError(t, gateway.Reboot(t.Context()))
+			assert.Equal(t, 1, sim.Reboots())
+			assert.True(t, sim.Down())
+
+			require.Eventually(t, func() bool {
+				status, err := gateway.Status(t.Context())
+
+				return err == nil && status.WebInterfaceUp
+			}, 5*time.Second, 100*time.Millisecond)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestLoadSamples(t *testing.T) {
+	samples, err := loadSamples("")
+	require.NoError(t, err)
+	assert.Nil(t, samples)
+
+	path := filepath.Join(t.TempDir(), "samples.json")
+	require.NoError(t, os.WriteFile(path,
+		[]byte(`[{"4g":{"band":"b2","rsrp":-90}},{"5g":{"band":"n71","sinr":3}}]`), 0o600))
+
+	samples, err = loadSamples(path)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, -90, samples[0].LTE.RSRP)
+	assert.Nil(t, samples[0].NR)
+	assert.Equal(t, 3, samples[1].NR.SINR)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"4g":{}}`), 0o600))
+
+	_, err = loadSamples(path)
+	require.ErrorContains(t, err, "failed to parse samples")
+
+	_, err = loadSamples(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorContains(t, err, "failed to read samples")
+}
+
+func TestSimulator_ModelIsDetected(t *testing.T) {
+	for _, model := range []string{ARCADYAN, NOK5G21} {
+		t.Run(model, func(t *testing.T) {
+			_, addr := simulator.Start(t, simulator.Options{Model: model})
+
+			assert.Equal(t, model, detectModel(t.Context(), addr, time.Second).Model)
+		})
+	}
+}
+
+func TestSimulate_StopsWhenCancelled(t *testing.T) {
+	a := newTestApp(nil)
+	a.config.Username = defaultUser
+
+	ctx, cancel := context.WithCancel(t.Context())
+
+	cmd := &cli.Command{
+		Name:   cmdSimulate,
+		Flags:  simulateFlags(),
+		Action: a.simulate,
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- cmd.Run(ctx, []string{cmdSimulate, "--model", NOK5G21, "--listen", "127.0.0.1:0"})
+	}()
+
+	cancel()
+	require.NoError(t, <-errCh)
+}
+
+func TestSimulate_InvalidModel(t *testing.T) {
+	cmd := &cli.Command{
+		Name:   cmdSimulate,
+		Flags:  simulateFlags(),
+		Action: newTestApp(nil).simulate,
+	}
+
+	require.Error(t, cmd.Run(t.Context(), []string{cmdSimulate, "--model", "FAST5688W"}))
+}
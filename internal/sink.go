@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	influxMeasurement = "tmhi_signal"
+	statsdPrefix      = "tmhi"
+	sinkStdout        = "-"
+	// maxSinkErrorBody bounds how much of a failed write response is shown.
+	maxSinkErrorBody = 512
+)
+
+// ErrSinkSpec is returned when a sink is not one of the supported forms.
+var ErrSinkSpec = errors.New(
+	"sink must be influx:-, influx:<file>, influx:<http(s) write URL> or statsd:<host>:<port>",
+)
+
+// ErrSinkWatch is returned when signal is given --sink without --watch.
+var ErrSinkWatch = errors.New("--" + ConfigSink + " needs --" + ConfigWatch)
+
+var errSinkHTTPStatus = errors.New("write rejected")
+
+// sink receives the samples of each poll. New outputs only need to turn
+// signalSample, built from tmhi.SignalResult, into their own format.
+type sink interface {
+	write(ctx context.Context, samples []signalSample) error
+}
+
+// multiSink writes to every sink, so one failing output does not starve
+// the others.
+type multiSink []sink
+
+func (m multiSink) write(ctx context.Context, samples []signalSample) error {
+	var errs []error
+
+	for _, s := range m {
+		if err := s.write(ctx, samples); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s historyStore) write(_ context.Context, samples []signalSample) error {
+	return s.append(samples)
+}
+
+// newSinks returns the sinks of the --sink flags of cmd.
+func (a *app) newSinks(cmd *cli.Command) (multiSink, error) {
+	token := cmd.String(ConfigInfluxToken)
+	a.secrets.add(token)
+
+	client := &http.Client{Timeout: a.config.Timeout}
+	sinks := multiSink{}
+
+	for _, spec := range cmd.StringSlice(ConfigSink) {
+		s, err := parseSink(spec, token, client, a.stdout)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, s)
+	}
+
+	return sinks, nil
+}
+
+// parseSink builds the sink described by spec. token authenticates InfluxDB
+// HTTP writes when set.
+//
+//nolint:ireturn
+func parseSink(spec, token string, client *http.Client, stdout io.Writer) (sink, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+
+	switch {
+	case kind == "influx" && target == sinkStdout:
+		return writerSink{w: stdout}, nil
+	case kind == "influx" && (strings.HasPrefix(target, "http://") ||
+		strings.HasPrefix(target, "https://")):
+		return influxHTTPSink{url: target, token: token, client: client}, nil
+	case kind == "influx" && target != "":
+		return influxFileSink{path: target}, nil
+	case kind == "statsd" && target != "":
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrSinkSpec, spec, err)
+		}
+
+		return statsdSink{address: target}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrSinkSpec, spec)
+	}
+}
+
+//nolint:gochecknoglobals
+var influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// influxLines renders samples as InfluxDB line protocol, one line per
+// radio, tagged with its cell and timestamped in nanoseconds.
+func influxLines(samples []signalSample) []byte {
+	var out bytes.Buffer
+
+	for _, sample := range samples {
+		out.WriteString(influxMeasurement)
+
+		for _, tag := range [][2]string{
+			{"antenna", sample.Antenna},
+			{"bands", strings.Join(sample.Bands, ",")},
+			{"cid", strconv.Itoa(sample.CID)},
+			{"network", sample.Network},
+			{"node_id", strconv.Itoa(sample.NodeID)},
+		} {
+			if tag[1] != "" {
+				out.WriteString("," + tag[0] + "=" + influxTagEscaper.Replace(tag[1]))
+			}
+		}
+
+		fmt.Fprintf(&out, " bars=%s,rsrp=%di,rsrq=%di,rssi=%di,sinr=%di %d\n",
+			strconv.FormatFloat(sample.Bars, 'f', -1, 64),
+			sample.RSRP, sample.RSRQ, sample.RSSI, sample.SINR,
+			sample.Time.UnixNano())
+	}
+
+	return out.Bytes()
+}
+
+// writerSink writes line protocol to a stream such as stdout.
+type writerSink struct {
+	w io.Writer
+}
+
+func (s writerSink) write(_ context.Context, samples []signalSample) error {
+	if _, err := s.w.Write(influxLines(samples)); err != nil {
+		return fmt.Errorf("failed to write samples: %w", err)
+	}
+
+	return nil
+}
+
+// influxFileSink appends line protocol to a file.
+type influxFileSink struct {
+	path string
+}
+
+func (s influxFileSink) write(_ context.Context, samples []signalSample) error {
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, historyFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.path, err)
+	}
+
+	if _, err := file.Write(influxLines(samples)); err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to write %s: %w", s.path, err)
+	}
+
+	return file.Close() //nolint:wrapcheck
+}
+
+// influxHTTPSink posts line protocol to an InfluxDB write endpoint, such as
+// /api/v2/write?org=home&bucket=tmhi or /write?db=tmhi for InfluxDB 1.x.
+type influxHTTPSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s influxHTTPSink) write(ctx context.Context, samples []signalSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url,
+		bytes.NewReader(influxLines(samples)))
+	if err != nil {
+		return fmt.Errorf("failed to write samples to InfluxDB: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write samples to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 != 2 { //nolint:mnd
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxSinkErrorBody))
+
+		return fmt.Errorf("failed to write samples to InfluxDB: %w: %s: %s",
+			errSinkHTTPStatus, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// statsdLines renders samples as StatsD gauges named
+// tmhi.<network>.<metric>. StatsD reads a signed gauge as a change of the
+// current value, so negative values are set by first resetting to zero.
+func statsdLines(samples []signalSample) []byte {
+	var out bytes.Buffer
+
+	for _, sample := range samples {
+		for _, gauge := range []struct {
+			name  string
+			value float64
+		}{
+			{"bars", sample.Bars},
+			{"rsrp", float64(sample.RSRP)},
+			{"rsrq", float64(sample.RSRQ)},
+			{"rssi", float64(sample.RSSI)},
+			{"sinr", float64(sample.SINR)},
+		} {
+			name := statsdPrefix + "." + sample.Network + "." + gauge.name
+			if gauge.value < 0 {
+				out.WriteString(name + ":0|g\n")
+			}
+
+			out.WriteString(name + ":" + strconv.FormatFloat(gauge.value, 'f', -1, 64) + "|g\n")
+		}
+	}
+
+	return out.Bytes()
+}
+
+// statsdSink sends StatsD gauges over UDP, one datagram per poll.
+type statsdSink struct {
+	address string
+}
+
+func (s statsdSink) write(ctx context.Context, samples []signalSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("failed to send samples to StatsD: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	if _, err := conn.Write(statsdLines(samples)); err != nil {
+		return fmt.Errorf("failed to send samples to StatsD: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+// failingSink fails every write.
+type failingSink struct{}
+
+func (failingSink) write(context.Context, []signalSample) error {
+	return errors.New("sink down")
+}
+
+func testSinkSamples() []signalSample {
+	at := time.Unix(1_790_000_000, 0)
+	sample4G := testSample(at, network4G, []string{"B2", "B66"}, -105)
+	sample5G := testSample(at, network5G, []string{"n41"}, -95)
+	sample5G.Antenna = "Internal 1"
+
+	return []signalSample{sample4G, sample5G}
+}
+
+func TestInfluxLines(t *testing.T) {
+	assert.Equal(t,
+		`tmhi_signal,bands=B2\,B66,cid=2001,network=4g,node_id=67890 `+
+			"bars=3.5,rsrp=-105i,rsrq=-9i,rssi=-65i,sinr=12i 1790000000000000000\n"+
+			`tmhi_signal,antenna=Internal\ 1,bands=n41,cid=2001,network=5g,node_id=67890 `+
+			"bars=3.5,rsrp=-95i,rsrq=-9i,rssi=-65i,sinr=12i 1790000000000000000\n",
+		string(influxLines(testSinkSamples())))
+}
+
+func TestStatsdLines(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(statsdLines(testSinkSamples()[1:]))), "\n")
+	assert.Equal(t, []string{
+		"tmhi.5g.bars:3.5|g",
+		"tmhi.5g.rsrp:0|g", "tmhi.5g.rsrp:-95|g",
+		"tmhi.5g.rsrq:0|g", "tmhi.5g.rsrq:-9|g",
+		"tmhi.5g.rssi:0|g", "tmhi.5g.rssi:-65|g",
+		"tmhi.5g.sinr:12|g",
+	}, lines)
+}
+
+func TestParseSink(t *testing.T) {
+	var stdout bytes.Buffer
+
+	tests := []struct {
+		spec string
+		want sink
+	}{
+		{"influx:-", writerSink{w: &stdout}},
+		{"influx:/tmp/signal.lp", influxFileSink{path: "/tmp/signal.lp"}},
+		{"influx:https://influx:8086/api/v2/write?bucket=tmhi", influxHTTPSink{
+			url:    "https://influx:8086/api/v2/write?bucket=tmhi",
+			token:  "tok",
+			client: http.DefaultClient,
+		}},
+		{"statsd:localhost:8125", statsdSink{address: "localhost:8125"}},
+	}
+
+	for _, tt := range tests {
+		s, err := parseSink(tt.spec, "tok", http.DefaultClient, &stdout)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, s, tt.spec)
+	}
+
+	for _, spec := range []string{"influx:", "influx", "statsd:localhost", "graphite:x:2003"} {
+		_, err := parseSink(spec, "", http.DefaultClient, &stdout)
+		require.ErrorIs(t, err, ErrSinkSpec, spec)
+	}
+}
+
+func TestInfluxFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signal.lp")
+	s := influxFileSink{path: path}
+
+	require.NoError(t, s.write(t.Context(), testSinkSamples()[:1]))
+	require.NoError(t, s.write(t.Context(), testSinkSamples()[1:]))
+	assert.Equal(t, string(influxLines(testSinkSamples())), readFile(t, path))
+
+	require.Error(t, influxFileSink{path: t.TempDir()}.write(t.Context(), testSinkSamples()))
+}
+
+func TestInfluxHTTPSink(t *testing.T) {
+	var (
+		body          []byte
+		authorization string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		authorization = r.Header.Get("Authorization")
+
+		if r.URL.Query().Get("bucket") != "tmhi" {
+			http.Error(w, `{"message":"bucket not found"}`, http.StatusNotFound)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s := influxHTTPSink{
+		url:    server.URL + "/api/v2/write?bucket=tmhi",
+		token:  "tok",
+		client: server.Client(),
+	}
+	require.NoError(t, s.write(t.Context(), testSinkSamples()))
+	assert.Equal(t, influxLines(testSinkSamples()), body)
+	assert.Equal(t, "Token tok", authorization)
+
+	s.url = server.URL + "/api/v2/write?bucket=other"
+	err := s.write(t.Context(), testSinkSamples())
+	require.ErrorIs(t, err, errSinkHTTPStatus)
+	assert.Contains(t, err.Error(), "404 Not Found")
+	assert.Contains(t, err.Error(), "bucket not found")
+
+	body = nil
+	require.NoError(t, s.write(t.Context(), nil), "nothing to write")
+	assert.Nil(t, body)
+}
+
+func TestStatsdSink(t *testing.T) {
+	conn, err := (&net.ListenConfig{}).ListenPacket(t.Context(), "udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer conn.Close() //nolint:errcheck
+
+	s := statsdSink{address: conn.LocalAddr().String()}
+	require.NoError(t, s.write(t.Context(), testSinkSamples()))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	datagram := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(datagram)
+	require.NoError(t, err)
+	assert.Equal(t, statsdLines(testSinkSamples()), datagram[:n])
+}
+
+func TestMultiSink(t *testing.T) {
+	var stdout bytes.Buffer
+
+	err := multiSink{failingSink{}, writerSink{w: &stdout}}.write(t.Context(), testSinkSamples())
+	require.ErrorContains(t, err, "sink down")
+	assert.Equal(t, influxLines(testSinkSamples()), stdout.Bytes(), "later sinks still written")
+}
+
+func TestRecordCommand_Sinks(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	store := newTestHistoryStore(t)
+	lineFile := filepath.Join(t.TempDir(), "signal.lp")
+	a := newTestApp(&mockGateway{signalResult: testSignalResult()})
+
+	var stdout bytes.Buffer
+
+	a.stdout = &stdout
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	cmd := &cli.Command{Name: cmdRecord, Flags: recordFlags(), Action: a.record}
+	err := cmd.Run(ctx, []string{
+		cmdRecord, "--db", store.path, "--interval", "1ms",
+		"--sink", "influx:-", "--sink", "influx:" + lineFile,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(stdout.String(), "tmhi_signal,bands=n41\\,n71,"))
+	assert.True(t, strings.HasPrefix(readFile(t, lineFile), "tmhi_signal,bands=n41\\,n71,"))
+
+	stored, err := store.read(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, stored)
+
+	err = cmd.Run(t.Context(), []string{cmdRecord, "--db", store.path, "--sink", "kafka:x"})
+	require.ErrorIs(t, err, ErrSinkSpec)
+}
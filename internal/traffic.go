@@ -0,0 +1,623 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pterm/pterm"
+)
+
+const (
+	trafficDirPerm  = 0o700
+	trafficFilePerm = 0o600
+	trafficFileExt  = ".json"
+)
+
+// ErrRecordReplay is returned when both --record and --replay are given.
+var ErrRecordReplay = errors.New("--" + ConfigRecord + " and --" + ConfigReplay +
+	" cannot be used together")
+
+// ErrEmptyRecording is returned when --replay is given a directory without
+// recorded exchanges.
+var ErrEmptyRecording = errors.New("no recorded exchanges")
+
+// sensitiveKeys are the JSON and form fields whose values a recording masks,
+// compared case-insensitively.
+//
+//nolint:gochecknoglobals
+var sensitiveKeys = []string{
+	"password", "token", "sid", "csrf_token",
+	"userhash", "randomkeyhash", "response", "enckey", "enciv",
+}
+
+// sensitiveHeaders are the headers whose values a recording masks.
+//
+//nolint:gochecknoglobals
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+
+// hopHeaders are not replayed: they describe the recorded connection or a
+// body redaction may have changed.
+//
+//nolint:gochecknoglobals
+var hopHeaders = []string{"Connection", "Content-Length", "Keep-Alive", "Transfer-Encoding"}
+
+// exchange is one HTTP request to the gateway and its response.
+type exchange struct {
+	Method         string      `json:"method"`
+	URI            string      `json:"uri"`
+	RequestHeader  http.Header `json:"requestHeader,omitempty"`
+	RequestBody    string      `json:"requestBody,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader,omitempty"`
+	ResponseBody   string      `json:"responseBody,omitempty"`
+}
+
+func (e *exchange) key() string {
+	return e.Method + " " + e.URI
+}
+
+// redact masks credentials and session tokens in e, and every secret known
+// to secrets anywhere in it.
+func (e *exchange) redact(secrets *redactor) {
+	e.RequestHeader = redactHeader(e.RequestHeader)
+	e.ResponseHeader = redactHeader(e.ResponseHeader)
+	e.RequestBody = secrets.redact(redactBody(e.RequestBody))
+	e.ResponseBody = secrets.redact(redactBody(e.ResponseBody))
+	e.URI = secrets.redact(e.URI)
+}
+
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+
+	for _, name := range sensitiveHeaders {
+		for i, value := range header.Values(name) {
+			header[name][i] = redactHeaderValue(name, value)
+		}
+	}
+
+	return header
+}
+
+// redactHeaderValue masks the values of a cookie header, keeping the cookie
+// names and attributes, and the whole value of other headers.
+func redactHeaderValue(name, value string) string {
+	switch name {
+	case "Cookie":
+		cookies := strings.Split(value, ";")
+		for i, cookie := range cookies {
+			cookies[i] = redactCookie(cookie)
+		}
+
+		return strings.Join(cookies, ";")
+	case "Set-Cookie":
+		if cookie, attributes, ok := strings.Cut(value, ";"); ok {
+			return redactCookie(cookie) + ";" + attributes
+		}
+
+		return redactCookie(value)
+	default:
+		return redactedSecret
+	}
+}
+
+func redactCookie(cookie string) string {
+	name, _, ok := strings.Cut(cookie, "=")
+	if !ok {
+		return cookie
+	}
+
+	return name + "=" + redactedSecret
+}
+
+// redactBody masks the sensitive fields of a JSON or form body, leaving other
+// bodies untouched.
+func redactBody(body string) string {
+	var doc any
+	if json.Unmarshal([]byte(body), &doc) == nil {
+		if !redactJSON(doc) {
+			return body
+		}
+
+		redacted, err := json.Marshal(doc)
+		if err != nil {
+			return body
+		}
+
+		return string(redacted)
+	}
+
+	form, err := url.ParseQuery(body)
+	if err != nil || !strings.Contains(body, "=") {
+		return body
+	}
+
+	changed := false
+
+	for key := range form {
+		if isSensitiveKey(key) {
+			form.Set(key, redactedSecret)
+
+			changed = true
+		}
+	}
+
+	if !changed {
+		return body
+	}
+
+	return form.Encode()
+}
+
+// redactJSON masks sensitive fields throughout doc, reporting whether there
+// were any.
+func redactJSON(doc any) bool {
+	changed := false
+
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if _, isString := value.(string); isString && isSensitiveKey(key) {
+				v[key] = redactedSecret
+				changed = true
+			} else if redactJSON(value) {
+				changed = true
+			}
+		}
+	case []any:
+		for _, value := range v {
+			if redactJSON(value) {
+				changed = true
+			}
+		}
+	}
+
+	return changed
+}
+
+func isSensitiveKey(key string) bool {
+	return slices.Contains(sensitiveKeys, strings.ToLower(key))
+}
+
+// recorder forwards requests to the gateway at target and saves every
+// exchange, redacted, to numbered files of dir. Without a dir, it only
+// forwards.
+type recorder struct {
+	dir     string
+	target  string
+	secrets *redactor
+	client  *http.Client
+	// failed is told why a request could not be forwarded.
+	failed func(err error)
+
+	mu   sync.Mutex
+	next int
+}
+
+// newRecorder returns a recorder adding to the exchanges already in dir.
+func newRecorder(dir, target string, secrets *redactor) (*recorder, error) {
+	var existing []string
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, trafficDirPerm); err != nil {
+			return nil, fmt.Errorf("failed to create recording directory: %w", err)
+		}
+
+		var err error
+		if existing, err = trafficFiles(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &recorder{
+		dir:     dir,
+		target:  target,
+		secrets: secrets,
+		client: &http.Client{
+			// The gateway's redirects are recorded, not followed.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		failed: func(error) {},
+		next:   len(existing),
+	}, nil
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	forward, err := http.NewRequestWithContext(r.Context(), r.Method,
+		"http://"+rec.target+r.URL.RequestURI(), bytes.NewReader(requestBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	forward.Header = r.Header.Clone()
+	// Let the transport negotiate compression so bodies are recorded as text.
+	forward.Header.Del("Accept-Encoding")
+
+	resp, err := rec.client.Do(forward)
+	if err != nil {
+		rec.failed(err)
+		// Like the gateway itself, answer nothing.
+		panic(http.ErrAbortHandler)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		rec.failed(err)
+		panic(http.ErrAbortHandler)
+	}
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(responseBody)
+
+	if rec.dir == "" {
+		return
+	}
+
+	recorded := exchange{
+		Method:         r.Method,
+		URI:            r.URL.RequestURI(),
+		RequestHeader:  r.Header,
+		RequestBody:    string(requestBody),
+		Status:         resp.StatusCode,
+		ResponseHeader: resp.Header,
+		ResponseBody:   string(responseBody),
+	}
+	recorded.redact(rec.secrets)
+
+	if err := rec.save(&recorded); err != nil {
+		pterm.Error.Println(err)
+	}
+}
+
+func (rec *recorder) save(e *exchange) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to record exchange: %w", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.next++
+
+	path := filepath.Join(rec.dir, fmt.Sprintf("%04d%s", rec.next, trafficFileExt))
+	if err := os.WriteFile(path, append(data, '\n'), trafficFilePerm); err != nil {
+		return fmt.Errorf("failed to record exchange: %w", err)
+	}
+
+	return nil
+}
+
+// replayer answers requests with the exchanges of a recording: each request
+// gets the next recorded response to the same method and URI, the last one
+// being repeated once they are used up.
+type replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]exchange
+}
+
+func newReplayer(dir string) (*replayer, error) {
+	files, err := trafficFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrEmptyRecording, dir)
+	}
+
+	rep := &replayer{exchanges: map[string][]exchange{}}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+
+		var e exchange
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("failed to parse recording %s: %w", file, err)
+		}
+
+		rep.exchanges[e.key()] = append(rep.exchanges[e.key()], e)
+	}
+
+	return rep, nil
+}
+
+func (rep *replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + r.URL.RequestURI()
+
+	rep.mu.Lock()
+
+	queue := rep.exchanges[key]
+	if len(queue) == 0 {
+		rep.mu.Unlock()
+		http.Error(w, "no recorded exchange for "+key, http.StatusNotFound)
+
+		return
+	}
+
+	e := queue[0]
+	if len(queue) > 1 {
+		rep.exchanges[key] = queue[1:]
+	}
+
+	rep.mu.Unlock()
+
+	for name, values := range e.ResponseHeader {
+		if !slices.Contains(hopHeaders, http.CanonicalHeaderKey(name)) {
+			w.Header()[name] = values
+		}
+	}
+
+	w.WriteHeader(e.Status)
+	_, _ = io.WriteString(w, e.ResponseBody)
+}
+
+// trafficFiles lists the exchange files of dir in recording order.
+func trafficFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+trafficFileExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recording: %w", err)
+	}
+
+	slices.Sort(files)
+
+	return files, nil
+}
+
+// trafficTaps are the local servers standing in for gateways: they forward
+// the traffic of the gateway client, record it under --record, or replay a
+// recording under --replay.
+type trafficTaps struct {
+	mu      sync.Mutex
+	servers []*http.Server
+}
+
+// tap returns a copy of cfg pointed at a local server forwarding its traffic
+// to the gateway, recording it when --record asks for it, or replaying a
+// recording for --replay, along with the tap on that traffic. cfg itself is
+// left alone, so that it still describes the gateway.
+func (t *trafficTaps) tap(cfg *Config, secrets *redactor) (*Config, *gatewayTap, error) {
+	var (
+		handler http.Handler
+		err     error
+	)
+
+	tapped := *cfg
+	traffic := &gatewayTap{observers: map[*observation]struct{}{}}
+
+	switch {
+	case cfg.Record != "" && cfg.Replay != "":
+		return nil, nil, ErrRecordReplay
+	case cfg.Replay != "":
+		handler, err = newReplayer(cfg.Replay)
+
+		// Recordings hold no password to check against.
+		if cfg.Password == "" && cfg.PasswordFile == "" && cfg.PasswordCommand == "" {
+			tapped.Password = redactedSecret
+		}
+	default:
+		var rec *recorder
+
+		rec, err = newRecorder(cfg.Record, cfg.IP, secrets)
+		if err == nil {
+			rec.failed = traffic.failed
+			handler = rec
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	traffic.next = handler
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	server := &http.Server{Handler: traffic, ReadHeaderTimeout: exporterReadTimeout}
+
+	go func() { _ = server.Serve(listener) }()
+
+	t.mu.Lock()
+	t.servers = append(t.servers, server)
+	t.mu.Unlock()
+
+	tapped.IP = listener.Addr().String()
+
+	return &tapped, traffic, nil
+}
+
+// close stops every tap.
+func (t *trafficTaps) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, server := range t.servers {
+		_ = server.Close()
+	}
+
+	t.servers = nil
+}
+
+// gatewayTap sits between the gateway client and the gateway. It gives the
+// body and headers of req to the request the client sends, the client having
+// no way to send them, and keeps the whole response. It also tells why the
+// client failed, when the gateway could not be reached or refused it.
+type gatewayTap struct {
+	next http.Handler
+
+	mu        sync.Mutex
+	injection *injection
+	observers map[*observation]struct{}
+}
+
+// injection is the body and headers given to the requests with a method and
+// path, and the last response to them.
+type injection struct {
+	method, path string
+	header       http.Header
+	body         []byte
+	response     *reqResponse
+}
+
+// observation is what the tap saw during a call of the gateway client.
+type observation struct {
+	// failure is why a request could not be forwarded to the gateway.
+	failure error
+	// refused is set when the gateway answered 401 or 403.
+	refused bool
+}
+
+// inject gives header and body, when not nil, to the method requests of the
+// path of uri until release.
+func (t *gatewayTap) inject(method, uri string, header http.Header, body []byte) *injection {
+	path, _, _ := strings.Cut(uri, "?")
+	injected := &injection{method: method, path: path, header: header, body: body}
+
+	t.mu.Lock()
+	t.injection = injected
+	t.mu.Unlock()
+
+	return injected
+}
+
+// release stops injected and returns the last response to it, or nil when
+// no request matched.
+func (t *gatewayTap) release(injected *injection) *reqResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.injection == injected {
+		t.injection = nil
+	}
+
+	return injected.response
+}
+
+// observe runs call, returning what the tap saw meanwhile along with the
+// error of call. A nil tap sees nothing.
+func (t *gatewayTap) observe(call func() error) (observation, error) {
+	if t == nil {
+		return observation{}, call()
+	}
+
+	seen := &observation{}
+
+	t.mu.Lock()
+	t.observers[seen] = struct{}{}
+	t.mu.Unlock()
+
+	err := call()
+
+	t.mu.Lock()
+	delete(t.observers, seen)
+	t.mu.Unlock()
+
+	return *seen, err
+}
+
+func (t *gatewayTap) failed(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for seen := range t.observers {
+		seen.failure = err
+	}
+}
+
+func (t *gatewayTap) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+
+	injected := t.injection
+	if injected != nil && (r.Method != injected.method || r.URL.Path != injected.path) {
+		injected = nil
+	}
+
+	t.mu.Unlock()
+
+	if injected != nil {
+		for name, values := range injected.header {
+			r.Header[name] = values
+		}
+
+		if injected.body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(injected.body))
+			r.ContentLength = int64(len(injected.body))
+		}
+	}
+
+	captured := &capturedResponse{ResponseWriter: w, status: http.StatusOK, keep: injected != nil}
+	t.next.ServeHTTP(captured, r)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if captured.status == http.StatusUnauthorized || captured.status == http.StatusForbidden {
+		for seen := range t.observers {
+			seen.refused = true
+		}
+	}
+
+	if injected != nil {
+		injected.response = &reqResponse{
+			proto:  r.Proto,
+			status: captured.status,
+			header: w.Header().Clone(),
+			body:   captured.body.Bytes(),
+		}
+	}
+}
+
+// capturedResponse passes a response on, keeping its status, and its body
+// when keep is set.
+type capturedResponse struct {
+	http.ResponseWriter
+
+	status int
+	keep   bool
+	body   bytes.Buffer
+}
+
+func (c *capturedResponse) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *capturedResponse) Write(data []byte) (int, error) {
+	if c.keep {
+		c.body.Write(data)
+	}
+
+	return c.ResponseWriter.Write(data) //nolint:wrapcheck
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hugoh/tmhi-cli/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactBody(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{
+			name: "JSON",
+			body: `{"auth":{"expiration":1,"token":"abc"}}`,
+			want: `{"auth":{"expiration":1,"token":"********"}}`,
+		},
+		{
+			name: "form",
+			body: "nonce=n&userhash=u&response=r",
+			want: "nonce=n&response=%2A%2A%2A%2A%2A%2A%2A%2A&userhash=%2A%2A%2A%2A%2A%2A%2A%2A",
+		},
+		{
+			name: "nothing sensitive keeps formatting",
+			body: "{ \"signal\": {} }",
+			want: "{ \"signal\": {} }",
+		},
+		{name: "text", body: "<html></html>", want: "<html></html>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactBody(tt.body))
+		})
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	header := http.Header{
+		"Authorization": {"Bearer abc"},
+		"Cookie":        {"sid=abc; lang=en"},
+		"Set-Cookie":    {"sid=abc; Path=/; HttpOnly"},
+		"Accept":        {"application/json"},
+	}
+
+	redacted := redactHeader(header)
+
+	assert.Equal(t, redactedSecret, redacted.Get("Authorization"))
+	assert.Equal(t, "sid=********; lang=********", redacted.Get("Cookie"))
+	assert.Equal(t, "sid=********; Path=/; HttpOnly", redacted.Get("Set-Cookie"))
+	assert.Equal(t, "application/json", redacted.Get("Accept"))
+	assert.Equal(t, "Bearer abc", header.Get("Authorization"), "the original is kept")
+}
+
+func sendThrough(t *testing.T, host, method, path, body string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, "http://"+host+path,
+		strings.NewReader(body))
+	require.NoError(t, err)
+
+	req.Header = header
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+
+	return resp
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	_, gatewayHost := simulator.Start(t, simulator.Options{
+		Model: simulator.ModelArcadyan, Password: "s3cret",
+	})
+	dir := filepath.Join(t.TempDir(), "trace")
+	secrets := &redactor{}
+	secrets.add("s3cret")
+
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	config := &Config{IP: gatewayHost, Record: dir}
+	recording, _, err := taps.tap(config, secrets)
+	require.NoError(t, err)
+	assert.NotEqual(t, gatewayHost, recording.IP)
+	assert.Equal(t, gatewayHost, config.IP, "the given configuration is left alone")
+
+	resp := sendThrough(t, recording.IP, http.MethodPost, "/TMI/v1/auth/login",
+		`{"username":"admin","password":"s3cret"}`, http.Header{})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), redactedSecret, "the client gets the real token")
+
+	resp = sendThrough(t, recording.IP, http.MethodPost, "/TMI/v1/gateway/reset?set=reboot", "",
+		http.Header{"Authorization": {"Bearer forged"}})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	files, err := trafficFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	login := readFile(t, files[0])
+	assert.NotContains(t, login, "s3cret")
+	assert.Contains(t, login, `\"token\":\"********\"`)
+	assert.NotContains(t, readFile(t, files[1]), "forged")
+
+	info, err := os.Stat(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(trafficFilePerm), info.Mode().Perm())
+
+	config = &Config{IP: "192.0.2.1", Replay: dir}
+	replaying, _, err := taps.tap(config, secrets)
+	require.NoError(t, err)
+	assert.Equal(t, redactedSecret, replaying.Password)
+	assert.Empty(t, config.Password, "the given configuration is left alone")
+
+	for range 2 {
+		resp = sendThrough(t, replaying.IP, http.MethodPost, "/TMI/v1/auth/login", "",
+			http.Header{})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "the last exchange is repeated")
+	}
+
+	resp = sendThrough(t, replaying.IP, http.MethodGet, "/elsewhere", "", http.Header{})
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReplay_Detection(t *testing.T) {
+	_, gatewayHost := simulator.Start(t, simulator.Options{Model: simulator.ModelNokia})
+	dir := t.TempDir()
+
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	recording, _, err := taps.tap(&Config{IP: gatewayHost, Record: dir}, &redactor{})
+	require.NoError(t, err)
+	require.Equal(t, NOK5G21, detectModel(t.Context(), recording.IP, time.Second).Model)
+
+	replaying, _, err := taps.tap(&Config{Replay: dir}, &redactor{})
+	require.NoError(t, err)
+	assert.Equal(t, NOK5G21, detectModel(t.Context(), replaying.IP, time.Second).Model)
+}
+
+func TestTrafficTaps_Errors(t *testing.T) {
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	_, _, err := taps.tap(&Config{Record: t.TempDir(), Replay: t.TempDir()}, &redactor{})
+	require.ErrorIs(t, err, ErrRecordReplay)
+
+	_, _, err = taps.tap(&Config{Replay: t.TempDir()}, &redactor{})
+	require.ErrorIs(t, err, ErrEmptyRecording)
+}
+
+func TestTrafficTaps_Forward(t *testing.T) {
+	_, gatewayHost := simulator.Start(t, simulator.Options{Model: simulator.ModelArcadyan})
+
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	config, _, err := taps.tap(&Config{IP: gatewayHost}, &redactor{})
+	require.NoError(t, err)
+	assert.NotEqual(t, gatewayHost, config.IP)
+
+	resp := sendThrough(t, config.IP, http.MethodGet, arcadyanGatewayPath, "", http.Header{})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRecorder_GatewayDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	host := strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	taps := &trafficTaps{}
+	t.Cleanup(taps.close)
+
+	config, _, err := taps.tap(&Config{IP: host, Record: t.TempDir()}, &redactor{})
+	require.NoError(t, err)
+
+	_, err = http.Get("http://" + config.IP + "/") //nolint:noctx
+	require.Error(t, err, "an unreachable gateway stays unreachable")
+}
@@ -2,7 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 
 	signal "github.com/hugoh/cellular-signal/v2"
 	tmhi "github.com/hugoh/tmhi-gateway/v2"
@@ -10,24 +12,45 @@ import (
 )
 
 func displayStatusResult(result *tmhi.StatusResult) {
+	pterm.Print(renderStatusResult(result))
+}
+
+func renderStatusResult(result *tmhi.StatusResult) string {
+	var out strings.Builder
+
 	switch {
 	case result.WebInterfaceUp:
-		pterm.Success.Println("Web interface up")
+		out.WriteString(pterm.Success.Sprintln("Web interface up"))
 	case result.Error != nil:
-		pterm.Error.Println("Web interface down: " + result.Error.Error())
+		out.WriteString(pterm.Error.Sprintln("Web interface down: " + result.Error.Error()))
 	default:
-		pterm.Error.Printfln("Web interface down: status %d", result.StatusCode)
+		out.WriteString(pterm.Error.Sprintfln("Web interface down: status %d", result.StatusCode))
 	}
 
 	if result.Registration != "" {
-		pterm.Info.Println("Registration status: " + result.Registration)
+		out.WriteString(pterm.Info.Sprintln("Registration status: " + result.Registration))
 	}
+
+	return out.String()
 }
 
 func displaySignalResult(result *tmhi.SignalResult) {
+	pterm.Print(renderSignalResult(result, nil))
+}
+
+// renderSignalResult renders result as tables. When prev is not nil, each
+// metric also shows how it moved since that earlier sample.
+func renderSignalResult(result, prev *tmhi.SignalResult) string {
+	var out strings.Builder
+
 	if result.FourG != nil {
-		displaySignalMetrics("4G LTE Signal", &result.FourG.SignalData,
-			[]string{"eNBID", strconv.Itoa(result.FourG.ENBID)})
+		var prevData *tmhi.SignalData
+		if prev != nil && prev.FourG != nil {
+			prevData = &prev.FourG.SignalData
+		}
+
+		out.WriteString(renderSignalMetrics("4G LTE Signal", &result.FourG.SignalData, prevData,
+			[]string{"eNBID", strconv.Itoa(result.FourG.ENBID)}))
 	}
 
 	if result.FiveG != nil {
@@ -36,30 +59,61 @@ func displaySignalResult(result *tmhi.SignalResult) {
 			extras = append(extras, []string{"Antenna", result.FiveG.AntennaUsed})
 		}
 
+		var prevData *tmhi.SignalData
+		if prev != nil && prev.FiveG != nil {
+			prevData = &prev.FiveG.SignalData
+		}
+
 		extras = append(extras, []string{"gNBID", strconv.Itoa(result.FiveG.GNBID)})
-		displaySignalMetrics("5G Signal", &result.FiveG.SignalData, extras...)
+		out.WriteString(
+			renderSignalMetrics("5G Signal", &result.FiveG.SignalData, prevData, extras...),
+		)
 	}
 
 	if result.Generic != (tmhi.GenericSignalInfo{}) {
-		displayGenericSignalInfo(result)
+		out.WriteString(renderGenericSignalInfo(result))
 	}
 
 	if result.FourG == nil && result.FiveG == nil && result.Generic == (tmhi.GenericSignalInfo{}) {
-		pterm.Warning.Println("No signal information available")
+		out.WriteString(pterm.Warning.Sprintln("No signal information available"))
 	}
+
+	return out.String()
 }
 
 const signalMetricsCount = 6
 
-func displaySignalMetrics(header string, metrics *tmhi.SignalData, extras ...[]string) {
+// ratedMetric pairs a signal metric name with its signal.Rater rating.
+type ratedMetric struct {
+	name   string
+	rating signal.Rating
+}
+
+// rateSignalData rates the RSRP, RSRQ, RSSI and SINR values of metrics.
+func rateSignalData(metrics *tmhi.SignalData) []ratedMetric {
 	rater := signal.NewRater()
 
-	pterm.DefaultHeader.Println(header)
+	return []ratedMetric{
+		{"RSRP", rater.RateRSRP(float64(metrics.RSRP))},
+		{"RSRQ", rater.RateRSRQ(float64(metrics.RSRQ))},
+		{"RSSI", rater.RateRSSI(float64(metrics.RSSI))},
+		{"SINR", rater.RateSINR(float64(metrics.SINR))},
+	}
+}
+
+func displaySignalMetrics(header string, metrics *tmhi.SignalData, extras ...[]string) {
+	pterm.Print(renderSignalMetrics(header, metrics, nil, extras...))
+}
 
+func renderSignalMetrics(
+	header string,
+	metrics, prev *tmhi.SignalData,
+	extras ...[]string,
+) string {
 	tableData := make(pterm.TableData, 0, 2+len(extras)+signalMetricsCount)
 	tableData = append(tableData,
 		[]string{"Metric", "Value", "Rating"},
-		[]string{"Signal bars", fmt.Sprintf("%.0f", metrics.Bars), ""},
+		[]string{"Signal bars", fmt.Sprintf("%.0f", metrics.Bars) + barsDelta(metrics, prev), ""},
 	)
 
 	for _, extra := range extras {
@@ -68,35 +122,54 @@ func displaySignalMetrics(header string, metrics *tmhi.SignalData, extras ...[]s
 
 	tableData = append(tableData, []string{"Bands", fmt.Sprintf("%v", metrics.Bands), ""})
 
-	ratedMetrics := []struct {
-		name  string
-		value int
-		rate  func(float64) signal.Rating
-	}{
-		{"RSRP", metrics.RSRP, rater.RateRSRP},
-		{"RSRQ", metrics.RSRQ, rater.RateRSRQ},
-		{"RSSI", metrics.RSSI, rater.RateRSSI},
-		{"SINR", metrics.SINR, rater.RateSINR},
+	var prevRatings []ratedMetric
+	if prev != nil {
+		prevRatings = rateSignalData(prev)
 	}
-	for _, metric := range ratedMetrics {
-		rating := metric.rate(float64(metric.value))
+
+	for i, metric := range rateSignalData(metrics) {
+		value := strconv.FormatFloat(metric.rating.Value, 'f', -1, 64) + " " +
+			metric.rating.Metric.Unit()
+		if prevRatings != nil {
+			value += delta(metric.rating.Value - prevRatings[i].rating.Value)
+		}
+
 		tableData = append(tableData, []string{
 			metric.name,
-			strconv.FormatFloat(rating.Value, 'f', -1, 64) + " " + rating.Metric.Unit(),
-			rating.Quality.String() + " " + rating.Quality.Stars(),
+			value,
+			metric.rating.Quality.String() + " " + metric.rating.Quality.Stars(),
 		})
 	}
 
 	tableData = append(tableData, []string{"CID", strconv.Itoa(metrics.CID), ""})
 
-	if err := pterm.DefaultTable.WithHasHeader().WithData(tableData).Render(); err != nil {
-		pterm.Error.Println("Failed to render table:", err)
+	return pterm.DefaultHeader.Sprintln(header) + renderTable(tableData)
+}
+
+func barsDelta(metrics, prev *tmhi.SignalData) string {
+	if prev == nil {
+		return ""
 	}
+
+	return delta(metrics.Bars - prev.Bars)
 }
 
-func displayGenericSignalInfo(result *tmhi.SignalResult) {
-	pterm.DefaultHeader.Println("Generic Info")
+// delta formats the change of a metric since the previous sample. Higher is
+// better for every signal metric, so a rise is shown in green.
+func delta(diff float64) string {
+	formatted := strconv.FormatFloat(math.Abs(diff), 'f', -1, 64)
 
+	switch {
+	case diff > 0:
+		return " " + pterm.Green("↑"+formatted)
+	case diff < 0:
+		return " " + pterm.Red("↓"+formatted)
+	default:
+		return ""
+	}
+}
+
+func renderGenericSignalInfo(result *tmhi.SignalResult) string {
 	tableData := pterm.TableData{
 		{"Property", "Value"},
 		{"APN", result.Generic.APN},
@@ -105,9 +178,16 @@ func displayGenericSignalInfo(result *tmhi.SignalResult) {
 		{"Roaming", strconv.FormatBool(result.Generic.Roaming)},
 	}
 
-	if err := pterm.DefaultTable.WithHasHeader().WithData(tableData).Render(); err != nil {
-		pterm.Error.Println("Failed to render table:", err)
+	return pterm.DefaultHeader.Sprintln("Generic Info") + renderTable(tableData)
+}
+
+func renderTable(tableData pterm.TableData) string {
+	table, err := pterm.DefaultTable.WithHasHeader().WithData(tableData).Srender()
+	if err != nil {
+		return pterm.Error.Sprintln("Failed to render table:", err)
 	}
+
+	return table + "\n"
 }
 
 func displayInfoResult(result *tmhi.InfoResult) {
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+// area is a region of the terminal that can be redrawn in place.
+type area interface {
+	Update(text ...any)
+	Stop() error
+}
+
+//nolint:ireturn
+func newPtermArea() (area, error) {
+	liveArea, err := pterm.DefaultArea.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start live area: %w", err)
+	}
+
+	return liveArea, nil
+}
+
+// watchInterval returns the --watch interval of cmd, or 0 for a one-shot run.
+func watchInterval(cmd *cli.Command) time.Duration {
+	if cmd == nil {
+		return 0
+	}
+
+	return cmd.Duration(ConfigWatch)
+}
+
+// watchLoop polls fetch every interval until ctx is cancelled, reusing the
+// same gateway session. Text mode redraws render's output in place, passing
+// the previous successful sample so deltas can be shown; JSON mode writes one
+// document per sample instead. A failed poll is reported and the loop keeps
+// going, since the gateway is often briefly unreachable.
+func watchLoop[T any](
+	ctx context.Context,
+	a *app,
+	interval time.Duration,
+	fetch func(context.Context) (T, error),
+	render func(current, previous T) string,
+	toJSON func(T) any,
+) error {
+	var liveArea area
+
+	if !a.jsonOutput() {
+		var err error
+
+		liveArea, err = a.newArea()
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = liveArea.Stop() }()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		previous   T
+		lastRender string
+	)
+
+	for {
+		result, err := fetch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		switch {
+		case a.jsonOutput() && err != nil:
+			err = a.writeJSONError(err)
+		case a.jsonOutput():
+			err = a.writeJSON(toJSON(result))
+		case err != nil:
+			liveArea.Update(watchStatusLine(interval) + pterm.Error.Sprintln(err) + lastRender)
+
+			err = nil
+		default:
+			lastRender = render(result, previous)
+			previous = result
+
+			liveArea.Update(watchStatusLine(interval) + lastRender)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchedSignal is a signal sample with the cell changes since the
+// previous one.
+type watchedSignal struct {
+	result *tmhi.SignalResult
+	events []cellEvent
+	// sinkErr is why the sample could not be written to every sink.
+	sinkErr error
+}
+
+// watchSignal runs watchLoop on the signal, reporting the cell changes
+// between samples: below the tables in text mode, in the events field of
+// each sample in JSON mode. Quality and registration changes go to notify,
+// and every sample to out.
+func watchSignal(
+	ctx context.Context,
+	a *app,
+	gateway tmhi.Gateway,
+	interval time.Duration,
+	notify *notifier,
+	out sink,
+) error {
+	tracker := newCellTracker()
+
+	return watchLoop(ctx, a, interval,
+		func(ctx context.Context) (*watchedSignal, error) {
+			result, err := gateway.Signal(ctx)
+			if err != nil {
+				return nil, err //nolint:wrapcheck
+			}
+
+			samples := samplesFromSignal(time.Now(), result)
+			events := tracker.track(samples)
+			notify.signal(ctx, samples, events, result.Generic.Registration)
+
+			// A failed output still leaves the sample to display.
+			sinkErr := out.write(ctx, samples)
+			if sinkErr != nil && a.jsonOutput() {
+				pterm.Warning.Println(sinkErr)
+			}
+
+			return &watchedSignal{result: result, events: events, sinkErr: sinkErr}, nil
+		},
+		func(current, previous *watchedSignal) string {
+			var prev *tmhi.SignalResult
+			if previous != nil {
+				prev = previous.result
+			}
+
+			text := renderSignalResult(current.result, prev) + renderCellEvents(tracker.recent)
+			if current.sinkErr != nil {
+				text += pterm.Warning.Sprintln(current.sinkErr)
+			}
+
+			return text
+		},
+		func(current *watchedSignal) any {
+			doc := newSignalJSON(current.result)
+			doc.Events = current.events
+
+			return doc
+		},
+	)
+}
+
+func watchStatusLine(interval time.Duration) string {
+	return pterm.Gray(fmt.Sprintf(
+		"Updated %s, refreshing every %s (Ctrl+C to stop)",
+		time.Now().Format(time.TimeOnly),
+		interval,
+	)) + "\n\n"
+}
@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+const testWatchInterval = time.Millisecond
+
+// recordingArea records every redraw instead of touching the terminal.
+type recordingArea struct {
+	updates []string
+	stopped bool
+}
+
+func (r *recordingArea) Update(text ...any) {
+	var b strings.Builder
+	for _, t := range text {
+		b.WriteString(t.(string)) //nolint:forcetypeassert
+	}
+
+	r.updates = append(r.updates, b.String())
+}
+
+func (r *recordingArea) Stop() error {
+	r.stopped = true
+
+	return nil
+}
+
+// sequenceGateway serves its signal results in turn and cancels the run once
+// they are exhausted.
+type sequenceGateway struct {
+	*mockGateway
+
+	cancel  context.CancelFunc
+	results []*tmhi.SignalResult
+}
+
+func (g *sequenceGateway) Signal(ctx context.Context) (*tmhi.SignalResult, error) {
+	if len(g.results) == 0 {
+		g.cancel()
+
+		return nil, ctx.Err() //nolint:wrapcheck
+	}
+
+	result := g.results[0]
+	g.results = g.results[1:]
+
+	return result, nil
+}
+
+// countingFetch returns a fetch function that yields results in turn and
+// cancels the watch once they are exhausted.
+func countingFetch(cancel context.CancelFunc, results ...int) func(context.Context) (int, error) {
+	calls := 0
+
+	return func(context.Context) (int, error) {
+		calls++
+		if calls == len(results) {
+			cancel()
+		}
+
+		if results[calls-1] < 0 {
+			return 0, errors.New("poll failed")
+		}
+
+		return results[calls-1], nil
+	}
+}
+
+func renderInts(current, previous int) string {
+	return strconv.Itoa(current) + " after " + strconv.Itoa(previous)
+}
+
+func intToJSON(v int) any { return v }
+
+func TestWatchLoop_RedrawsWithPrevious(t *testing.T) {
+	a := newTestApp(nil)
+	liveArea := &recordingArea{}
+	a.newArea = func() (area, error) { return liveArea, nil }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	err := watchLoop(ctx, a, testWatchInterval, countingFetch(cancel, 1, 2, 3, 4),
+		renderInts, intToJSON)
+	require.NoError(t, err)
+
+	require.Len(t, liveArea.updates, 3, "the poll racing with cancellation is not drawn")
+	assert.Contains(t, liveArea.updates[0], "1 after 0")
+	assert.Contains(t, liveArea.updates[2], "3 after 2")
+	assert.True(t, liveArea.stopped)
+}
+
+func TestWatchLoop_KeepsGoingAfterFailure(t *testing.T) {
+	a := newTestApp(nil)
+	liveArea := &recordingArea{}
+	a.newArea = func() (area, error) { return liveArea, nil }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	err := watchLoop(ctx, a, testWatchInterval, countingFetch(cancel, 1, -1, 2, 0),
+		renderInts, intToJSON)
+	require.NoError(t, err)
+
+	require.Len(t, liveArea.updates, 3)
+	assert.Contains(t, liveArea.updates[1], "poll failed")
+	assert.Contains(t, liveArea.updates[1], "1 after 0", "last good sample stays visible")
+	assert.Contains(t, liveArea.updates[2], "2 after 1")
+}
+
+func TestWatchLoop_JSONWritesOneDocumentPerSample(t *testing.T) {
+	a, buf := newJSONTestApp(nil)
+	a.newArea = func() (area, error) { return nil, errors.New("no area in JSON mode") }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	err := watchLoop(ctx, a, testWatchInterval, countingFetch(cancel, 1, -1, 2, 0),
+		renderInts, intToJSON)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"ok":true,"data":1}`, lines[0])
+	assert.JSONEq(t,
+		`{"ok":false,"error":{"message":"poll failed","code":"error","exit_code":1}}`, lines[1])
+	assert.JSONEq(t, `{"ok":true,"data":2}`, lines[2])
+}
+
+func TestWatchLoop_AreaError(t *testing.T) {
+	a := newTestApp(nil)
+	a.newArea = func() (area, error) { return nil, errors.New("no terminal") }
+
+	err := watchLoop(t.Context(), a, testWatchInterval,
+		func(context.Context) (int, error) { return 0, nil }, renderInts, intToJSON)
+	require.ErrorContains(t, err, "no terminal")
+}
+
+func TestSignal_Watch(t *testing.T) {
+	mg := &mockGateway{}
+	a := newTestApp(mg)
+	liveArea := &recordingArea{}
+	a.newArea = func() (area, error) { return liveArea, nil }
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	cmd := &cli.Command{Name: cmdSignal, Flags: []cli.Flag{watchFlag()}, Action: a.signal}
+	err := cmd.Run(ctx, []string{cmdSignal, "--watch", testWatchInterval.String()})
+	require.NoError(t, err, "cancellation is a clean exit")
+	assert.True(t, mg.signalCalled)
+	assert.NotEmpty(t, liveArea.updates)
+}
+
+func TestSignal_WatchSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signal.lp")
+	a := newTestApp(nil)
+	a.newArea = func() (area, error) { return &recordingArea{}, nil }
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	gw := &sequenceGateway{
+		mockGateway: &mockGateway{},
+		cancel:      cancel,
+		results:     []*tmhi.SignalResult{cellResult(123, 1, "n41"), cellResult(123, 2, "n41")},
+	}
+	a.initGateway = func(context.Context, *Config) (tmhi.Gateway, error) { return gw, nil }
+
+	newCmd := func() *cli.Command {
+		return &cli.Command{
+			Name:   cmdSignal,
+			Flags:  append([]cli.Flag{watchFlag()}, sinkFlags()...),
+			Action: a.signal,
+		}
+	}
+
+	require.NoError(t, newCmd().Run(ctx, []string{
+		cmdSignal, "--watch", testWatchInterval.String(), "--sink", "influx:" + path,
+	}))
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, path)), "\n")
+	require.Len(t, lines, 2, "one line per sample")
+	assert.Contains(t, lines[0], "cid=1")
+	assert.Contains(t, lines[1], "cid=2")
+
+	err := newCmd().Run(t.Context(), []string{cmdSignal, "--sink", "influx:" + path})
+	require.ErrorIs(t, err, ErrSinkWatch)
+}
+
+func TestWatchInterval(t *testing.T) {
+	assert.Zero(t, watchInterval(nil))
+	assert.Zero(t, watchInterval(&cli.Command{}))
+}
+
+func TestRenderSignalResult_Deltas(t *testing.T) {
+	captureDefaultOutput(t)
+
+	sample := func(rsrp int) *tmhi.SignalResult {
+		return &tmhi.SignalResult{FourG: &tmhi.FourGSignal{
+			SignalData: tmhi.SignalData{Bars: 3, RSRP: rsrp, SINR: 10},
+		}}
+	}
+
+	out := renderSignalResult(sample(-95), sample(-100))
+	assert.Contains(t, out, "↑5")
+
+	out = renderSignalResult(sample(-100), sample(-95))
+	assert.Contains(t, out, "↓5")
+
+	out = renderSignalResult(sample(-100), nil)
+	assert.NotContains(t, out, "↑")
+	assert.NotContains(t, out, "↓")
+}
@@ -0,0 +1,316 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	tmhi "github.com/hugoh/tmhi-gateway/v2"
+	"github.com/pterm/pterm"
+	"github.com/urfave/cli/v3"
+)
+
+// Watchdog defaults.
+const (
+	defaultWatchdogInterval   = time.Minute
+	defaultWatchdogFailures   = 3
+	defaultWatchdogCooldown   = 15 * time.Minute
+	defaultWatchdogMaxReboots = 3
+	rebootCapWindow           = 24 * time.Hour
+)
+
+// ErrProbeSpec is returned when a watchdog probe is not one of the supported
+// forms.
+var ErrProbeSpec = errors.New(
+	"probe must be icmp:<host>, tcp:<host>:<port>, dns:<name> or an http(s):// URL",
+)
+
+var errProbeHTTPStatus = errors.New("server error")
+
+//nolint:gochecknoglobals
+var defaultWatchdogProbes = []string{"tcp:1.1.1.1:53", "tcp:8.8.8.8:53", "dns:example.com"}
+
+// probe is a single connectivity check.
+type probe struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// parseProbe builds the probe described by spec. Each attempt is bounded by
+// timeout.
+func parseProbe(spec string, timeout time.Duration) (probe, error) {
+	kind, target, _ := strings.Cut(spec, ":")
+
+	switch {
+	case kind == "icmp" && target != "":
+		return probe{name: spec, run: func(ctx context.Context) error {
+			return pingProbe(ctx, target, timeout)
+		}}, nil
+	case kind == "tcp" && target != "":
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return probe{}, fmt.Errorf("%w: %q: %w", ErrProbeSpec, spec, err)
+		}
+
+		return probe{name: spec, run: func(ctx context.Context) error {
+			return tcpProbe(ctx, target, timeout)
+		}}, nil
+	case kind == "dns" && target != "":
+		return probe{name: spec, run: func(ctx context.Context) error {
+			return dnsProbe(ctx, target, timeout)
+		}}, nil
+	case kind == "http" || kind == "https":
+		return probe{name: spec, run: func(ctx context.Context) error {
+			return httpProbe(ctx, spec, timeout)
+		}}, nil
+	default:
+		return probe{}, fmt.Errorf("%w: %q", ErrProbeSpec, spec)
+	}
+}
+
+// pingProbe sends a single echo request with the system ping, which unlike
+// a raw ICMP socket does not need elevated privileges. The deadline of ctx
+// bounds the wait: -W takes seconds on Linux but milliseconds on macOS and
+// the BSDs.
+func pingProbe(ctx context.Context, host string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	//nolint:gosec // host comes from the user's own configuration
+	if err := exec.CommandContext(ctx, "ping", "-c", "1", host).Run(); err != nil {
+		return fmt.Errorf("ping %s: %w", host, err)
+	}
+
+	return nil
+}
+
+func tcpProbe(ctx context.Context, address string, timeout time.Duration) error {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", address, err)
+	}
+
+	return conn.Close() //nolint:wrapcheck
+}
+
+func dnsProbe(ctx context.Context, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, name); err != nil {
+		return fmt.Errorf("lookup %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// httpProbe succeeds on any response below 500: reaching the server at all
+// proves the connection works.
+func httpProbe(ctx context.Context, url string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("GET %s: %w: status %d", url, errProbeHTTPStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// watchdogOptions controls when the watchdog reboots the gateway.
+type watchdogOptions struct {
+	interval   time.Duration
+	failures   int
+	cooldown   time.Duration
+	maxReboots int
+	retries    int
+	dryRun     bool
+}
+
+// watchdog reboots the gateway after enough consecutive failed checks. A
+// check fails when every probe fails, so one unreachable target does not
+// trigger a reboot on its own.
+type watchdog struct {
+	gateway tmhi.Gateway
+	probes  []probe
+	opts    watchdogOptions
+	now     func() time.Time
+	notify  *notifier
+
+	failures int
+	reboots  []time.Time
+}
+
+// checkConnectivity reports whether any probe succeeds, retrying each failed
+// probe up to the configured number of retries.
+func (w *watchdog) checkConnectivity(ctx context.Context) bool {
+	for _, p := range w.probes {
+		for attempt := 0; attempt <= w.opts.retries; attempt++ {
+			err := p.run(ctx)
+			if err == nil {
+				pterm.Debug.Printfln("probe %s succeeded", p.name)
+
+				return true
+			}
+
+			pterm.Debug.Printfln("probe %s failed: %v", p.name, err)
+		}
+	}
+
+	return false
+}
+
+// step runs one check and reboots the gateway if the failure threshold is
+// reached and neither the cool-down nor the daily cap holds it back. A check
+// cut short by the end of ctx does not count.
+func (w *watchdog) step(ctx context.Context) {
+	connected := w.checkConnectivity(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if connected {
+		if w.failures > 0 {
+			pterm.Success.Printfln("Connectivity restored after %d failed checks", w.failures)
+		}
+
+		w.failures = 0
+
+		return
+	}
+
+	w.failures++
+	pterm.Warning.Printfln("Connectivity check failed (%d/%d)", w.failures, w.opts.failures)
+
+	if w.failures < w.opts.failures {
+		return
+	}
+
+	now := w.now()
+	w.pruneReboots(now)
+
+	if n := len(w.reboots); n > 0 && now.Sub(w.reboots[n-1]) < w.opts.cooldown {
+		pterm.Warning.Printfln("Not rebooting: still in the %s cool-down", w.opts.cooldown)
+
+		return
+	}
+
+	if len(w.reboots) >= w.opts.maxReboots {
+		pterm.Warning.Printfln("Not rebooting: %d reboots in the last 24h", len(w.reboots))
+
+		return
+	}
+
+	w.reboots = append(w.reboots, now)
+	w.failures = 0
+
+	if w.opts.dryRun {
+		pterm.Info.Println("Dry run - would reboot the gateway")
+
+		return
+	}
+
+	if err := w.gateway.Reboot(ctx); err != nil {
+		pterm.Error.Println("Reboot failed:", err)
+
+		return
+	}
+
+	pterm.Success.Println("Reboot command sent successfully")
+	w.notify.reboot(ctx)
+}
+
+// pruneReboots forgets reboots older than the daily cap window.
+func (w *watchdog) pruneReboots(now time.Time) {
+	kept := w.reboots[:0]
+
+	for _, at := range w.reboots {
+		if now.Sub(at) < rebootCapWindow {
+			kept = append(kept, at)
+		}
+	}
+
+	w.reboots = kept
+}
+
+// run checks connectivity every interval until ctx is cancelled.
+func (w *watchdog) run(ctx context.Context) error {
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		w.step(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *app) watchdog(ctx context.Context, cmd *cli.Command) error {
+	gateway, err := a.initGateway(ctx, a.config)
+	if err != nil {
+		return err
+	}
+
+	specs := cmd.StringSlice(ConfigWatchdogProbe)
+	if len(specs) == 0 {
+		specs = defaultWatchdogProbes
+	}
+
+	probes := make([]probe, 0, len(specs))
+
+	for _, spec := range specs {
+		p, err := parseProbe(spec, a.config.Timeout)
+		if err != nil {
+			return err
+		}
+
+		probes = append(probes, p)
+	}
+
+	notify, err := a.newNotifier(cmd)
+	if err != nil {
+		return err
+	}
+
+	dog := &watchdog{
+		gateway: gateway,
+		probes:  probes,
+		now:     time.Now,
+		notify:  notify,
+		opts: watchdogOptions{
+			interval:   cmd.Duration(ConfigWatchdogInterval),
+			failures:   cmd.Int(ConfigWatchdogFailures),
+			cooldown:   cmd.Duration(ConfigWatchdogCooldown),
+			maxReboots: cmd.Int(ConfigWatchdogMaxReboots),
+			retries:    a.config.Retries,
+			dryRun:     a.config.DryRun,
+		},
+	}
+
+	pterm.Info.Printfln("Watching connectivity every %s with %d probes",
+		dog.opts.interval, len(probes))
+
+	return dog.run(ctx)
+}
@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	altsrc "github.com/urfave/cli-altsrc/v3"
+	"github.com/urfave/cli/v3"
+)
+
+const testProbeTimeout = time.Second
+
+var errProbeDown = errors.New("down")
+
+// fixedProbe returns a probe whose result is read from *up on every run.
+func fixedProbe(up *bool) probe {
+	return probe{name: "fixed", run: func(context.Context) error {
+		if *up {
+			return nil
+		}
+
+		return errProbeDown
+	}}
+}
+
+// newWatchdogCmd builds the watchdog command as wired by cmd_builder.go, with
+// a config file that does not exist.
+func newWatchdogCmd(t *testing.T, a *app) *cli.Command {
+	t.Helper()
+
+	noConfig := altsrc.StringSourcer(filepath.Join(t.TempDir(), "missing.toml"))
+
+	return &cli.Command{Name: cmdWatchdog, Flags: watchdogFlags(noConfig), Action: a.watchdog}
+}
+
+func newTestWatchdog(mg *mockGateway, up *bool, now *time.Time) *watchdog {
+	return &watchdog{
+		gateway: mg,
+		probes:  []probe{fixedProbe(up)},
+		now:     func() time.Time { return *now },
+		opts: watchdogOptions{
+			interval:   time.Minute,
+			failures:   2,
+			cooldown:   10 * time.Minute,
+			maxReboots: 2,
+		},
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	for _, spec := range []string{
+		"icmp:8.8.8.8", "tcp:1.1.1.1:53", "dns:example.com", "http://example.com", "https://x",
+	} {
+		p, err := parseProbe(spec, testProbeTimeout)
+		require.NoError(t, err, spec)
+		assert.Equal(t, spec, p.name)
+	}
+
+	for _, spec := range []string{"", "tcp:1.1.1.1", "udp:1.1.1.1:53", "icmp:", "dns:"} {
+		_, err := parseProbe(spec, testProbeTimeout)
+		require.ErrorIs(t, err, ErrProbeSpec, spec)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	listener, err := (&net.ListenConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+
+	p, err := parseProbe("tcp:"+address, testProbeTimeout)
+	require.NoError(t, err)
+	require.NoError(t, p.run(t.Context()))
+
+	require.NoError(t, listener.Close())
+	require.Error(t, p.run(t.Context()))
+}
+
+func TestHTTPProbe(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	p, err := parseProbe(server.URL, testProbeTimeout)
+	require.NoError(t, err)
+	require.NoError(t, p.run(t.Context()), "any response below 500 proves connectivity")
+
+	status = http.StatusBadGateway
+
+	require.ErrorIs(t, p.run(t.Context()), errProbeHTTPStatus)
+}
+
+func TestWatchdog_RebootsAfterConsecutiveFailures(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	mg := &mockGateway{}
+	up := false
+	now := time.Now()
+	dog := newTestWatchdog(mg, &up, &now)
+
+	dog.step(t.Context())
+	assert.False(t, mg.rebootCalled, "one failure is below the threshold")
+
+	up = true
+
+	dog.step(t.Context())
+	assert.Zero(t, dog.failures, "success resets the failure count")
+
+	up = false
+
+	dog.step(t.Context())
+	dog.step(t.Context())
+	assert.True(t, mg.rebootCalled)
+	assert.Zero(t, dog.failures)
+}
+
+func TestWatchdog_CancelledCheckDoesNotCount(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	mg := &mockGateway{}
+	now := time.Now()
+	dog := newTestWatchdog(mg, new(bool), &now)
+	dog.failures = dog.opts.failures - 1
+
+	ctx, cancel := context.WithCancel(t.Context())
+	dog.probes = []probe{{name: "interrupted", run: func(ctx context.Context) error {
+		cancel()
+
+		return ctx.Err()
+	}}}
+
+	dog.step(ctx)
+	assert.False(t, mg.rebootCalled, "no reboot for a check cut short")
+	assert.Equal(t, dog.opts.failures-1, dog.failures)
+}
+
+func TestWatchdog_CooldownAndDailyCap(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	mg := &mockGateway{}
+	up := false
+	now := time.Now()
+	dog := newTestWatchdog(mg, &up, &now)
+
+	failTwice := func() {
+		dog.step(t.Context())
+		dog.step(t.Context())
+	}
+
+	failTwice()
+	require.Len(t, dog.reboots, 1)
+
+	now = now.Add(time.Minute)
+
+	failTwice()
+	assert.Len(t, dog.reboots, 1, "cool-down holds back the second reboot")
+
+	now = now.Add(dog.opts.cooldown)
+
+	failTwice()
+	assert.Len(t, dog.reboots, 2)
+
+	now = now.Add(dog.opts.cooldown)
+
+	failTwice()
+	assert.Len(t, dog.reboots, 2, "daily cap reached")
+
+	now = now.Add(rebootCapWindow)
+
+	failTwice()
+	assert.Len(t, dog.reboots, 1, "old reboots no longer count towards the cap")
+}
+
+func TestWatchdog_DryRunDoesNotReboot(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	mg := &mockGateway{}
+	up := false
+	now := time.Now()
+	dog := newTestWatchdog(mg, &up, &now)
+	dog.opts.dryRun = true
+
+	dog.step(t.Context())
+	dog.step(t.Context())
+
+	assert.False(t, mg.rebootCalled)
+	assert.Len(t, dog.reboots, 1, "dry-run reboots still count towards the cool-down")
+}
+
+func TestWatchdog_RetriesProbes(t *testing.T) {
+	calls := 0
+	dog := &watchdog{
+		probes: []probe{{name: "flaky", run: func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errProbeDown
+			}
+
+			return nil
+		}}},
+		opts: watchdogOptions{retries: 2},
+	}
+
+	assert.True(t, dog.checkConnectivity(t.Context()))
+	assert.Equal(t, 3, calls)
+}
+
+func TestWatchdogCommand_InvalidProbe(t *testing.T) {
+	a := newTestApp(&mockGateway{})
+	a.config.Timeout = testProbeTimeout
+
+	cmd := newWatchdogCmd(t, a)
+	err := cmd.Run(t.Context(), []string{cmdWatchdog, "--" + ConfigWatchdogProbe, "bogus"})
+	require.ErrorIs(t, err, ErrProbeSpec)
+}
+
+func TestWatchdogCommand_StopsOnCancel(t *testing.T) {
+	pterm.DisableOutput()
+	t.Cleanup(pterm.EnableOutput)
+
+	listener, err := (&net.ListenConfig{}).Listen(t.Context(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	a := newTestApp(&mockGateway{})
+	a.config.Timeout = testProbeTimeout
+
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+
+	cmd := newWatchdogCmd(t, a)
+	err = cmd.Run(ctx, []string{
+		cmdWatchdog, "--" + ConfigWatchdogProbe, "tcp:" + listener.Addr().String(),
+	})
+	require.NoError(t, err)
+}
+
+func TestPositive(t *testing.T) {
+	require.NoError(t, positive(1))
+	require.ErrorIs(t, positive(time.Duration(0)), ErrNotPositive)
+}
@@ -10,8 +10,7 @@ import (
 var version = "dev"
 
 func main() {
-	err := internal.Cmd(version)
-	if err != nil {
-		os.Exit(1)
+	if err := internal.Cmd(version); err != nil {
+		os.Exit(internal.ExitCode(err))
 	}
 }
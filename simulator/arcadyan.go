@@ -0,0 +1,167 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Arcadyan web API paths.
+const (
+	arcadyanLoginPath   = "/TMI/v1/auth/login"
+	arcadyanGatewayPath = "/TMI/v1/gateway?get=all"
+	arcadyanSignalPath  = "/TMI/v1/gateway?get=signal"
+	arcadyanRebootPath  = "/TMI/v1/gateway/reset?set=reboot"
+	arcadyanClientsPath = "/TMI/v1/network/telemetry?get=clients"
+
+	sessionLifetime = time.Hour
+)
+
+func (s *Server) arcadyanRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /":                      serveIndex,
+		"POST " + arcadyanLoginPath:  s.arcadyanLogin,
+		"GET " + arcadyanGatewayPath: s.arcadyanGateway,
+		"GET " + arcadyanSignalPath:  s.arcadyanSignal,
+		"POST " + arcadyanRebootPath: s.arcadyanReboot,
+		"GET " + arcadyanClientsPath: s.arcadyanClients,
+	}
+}
+
+func (s *Server) arcadyanLogin(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !s.validPassword(credentials.Username, credentials.Password) {
+		http.Error(w, `{"result":{"error":"Unauthorized"}}`, http.StatusUnauthorized)
+
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"auth": map[string]any{
+			"expiration":       time.Now().Add(sessionLifetime).Unix(),
+			"refreshCountLeft": 4,
+			"refreshCountMax":  4,
+			"token":            s.newSession(),
+		},
+	})
+}
+
+func (s *Server) arcadyanGateway(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"device": map[string]any{
+			"friendlyName":    "5G Gateway",
+			"hardwareVersion": "R01",
+			"isEnabled":       true,
+			"macId":           simulatedMAC,
+			"manufacturer":    "Arcadyan",
+			"model":           "KVD21",
+			"name":            "5G Gateway",
+			"role":            "gateway",
+			"serial":          simulatedSerial,
+			"softwareVersion": simulatedVersion,
+			"type":            "HSID",
+		},
+		"signal": arcadyanSignal(s.sample()),
+		"time": map[string]any{
+			"localTime": time.Now().Unix(),
+			"upTime":    int(time.Since(s.started).Seconds()),
+		},
+	})
+}
+
+func (s *Server) arcadyanSignal(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{"signal": arcadyanSignal(s.sample())})
+}
+
+func arcadyanSignal(sample Sample) map[string]any {
+	signal := map[string]any{
+		"generic": map[string]any{
+			"apn":          simulatedAPN,
+			"hasIPv6":      true,
+			"registration": "registered",
+			"roaming":      false,
+		},
+	}
+
+	if sample.LTE != nil {
+		cell := arcadyanCell(sample.LTE)
+		cell["eNBID"] = sample.LTE.NodeID
+		signal["4g"] = cell
+	}
+
+	if sample.NR != nil {
+		cell := arcadyanCell(sample.NR)
+		cell["gNBID"] = sample.NR.NodeID
+		cell["antennaUsed"] = "Internal_directional"
+		signal["5g"] = cell
+	}
+
+	return signal
+}
+
+func arcadyanCell(c *Cell) map[string]any {
+	return map[string]any{
+		"bands": []string{strings.ToLower(c.Band)},
+		"bars":  c.Bars,
+		"cid":   c.CID,
+		"rsrp":  c.RSRP,
+		"rsrq":  c.RSRQ,
+		"rssi":  c.RSSI,
+		"sinr":  c.SINR,
+	}
+}
+
+func (s *Server) arcadyanReboot(w http.ResponseWriter, r *http.Request) {
+	if !s.arcadyanAuthorized(w, r) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	s.reboot()
+}
+
+// arcadyanClients serves the devices connected to the gateway.
+func (s *Server) arcadyanClients(w http.ResponseWriter, r *http.Request) {
+	if !s.arcadyanAuthorized(w, r) {
+		return
+	}
+
+	client := func(name, ip, mac string) map[string]any {
+		return map[string]any{"connected": true, "ipv4": ip, "mac": mac, "name": name}
+	}
+
+	writeJSON(w, map[string]any{
+		"clients": map[string]any{
+			"2.4ghz": []any{client("thermostat", "192.168.12.150", "02:00:00:00:01:50")},
+			"5.0ghz": []any{
+				client("laptop", "192.168.12.151", "02:00:00:00:01:51"),
+				client("phone", "192.168.12.152", "02:00:00:00:01:52"),
+			},
+			"ethernet": []any{client("desktop", "192.168.12.153", "02:00:00:00:01:53")},
+		},
+	})
+}
+
+// arcadyanAuthorized checks the bearer token of r, answering 401 when it is
+// not the current session's.
+func (s *Server) arcadyanAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !s.validToken(token) {
+		http.Error(w, `{"result":{"error":"Unauthorized"}}`, http.StatusUnauthorized)
+
+		return false
+	}
+
+	return true
+}
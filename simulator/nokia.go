@@ -0,0 +1,123 @@
+package simulator
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Nokia 5G21 web API paths.
+const (
+	nokiaNoncePath     = "/login_web_app.cgi?nonce"
+	nokiaLoginPath     = "/login_web_app.cgi"
+	nokiaRadioPath     = "/fastmile_radio_status_web_app.cgi"
+	nokiaDevicePath    = "/dashboard_device_info_status_web_app.cgi"
+	nokiaDeviceAllPath = "/device_status_web_app.cgi?getroot"
+	nokiaRebootPath    = "/reboot_web_app.cgi"
+
+	nokiaSessionCookie = "sid"
+)
+
+func (s *Server) nokiaRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /":                     serveIndex,
+		"GET " + nokiaNoncePath:     nokiaNonce,
+		"POST " + nokiaLoginPath:    s.nokiaLogin,
+		"GET " + nokiaRadioPath:     s.nokiaRadio,
+		"GET " + nokiaDevicePath:    s.nokiaDevice,
+		"GET " + nokiaDeviceAllPath: s.nokiaDevice,
+		"POST " + nokiaRebootPath:   s.nokiaReboot,
+	}
+}
+
+func nokiaNonce(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"nonce":      randomToken(),
+		"pubkey":     "",
+		"randomKey":  randomToken(),
+		"iterations": 1,
+	})
+}
+
+// nokiaLogin accepts any complete challenge response: it only carries hashes
+// of the password.
+func (s *Server) nokiaLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	for _, field := range []string{"userhash", "response", "nonce"} {
+		if r.PostForm.Get(field) == "" {
+			writeJSON(w, map[string]any{"result": 1})
+
+			return
+		}
+	}
+
+	sid := s.newSession()
+
+	http.SetCookie(w, &http.Cookie{Name: nokiaSessionCookie, Value: sid, Path: "/"})
+	writeJSON(w, map[string]any{"result": 0, "sid": sid, "token": sid})
+}
+
+func (s *Server) nokiaRadio(w http.ResponseWriter, _ *http.Request) {
+	sample := s.sample()
+
+	doc := map[string]any{
+		"apn_cfg":            []any{map[string]any{"APN": simulatedAPN}},
+		"connection_status":  []any{map[string]any{"ConnectionStatus": 0}},
+		"cell_LTE_stats_cfg": []any{},
+		"cell_5G_stats_cfg":  []any{},
+	}
+
+	if sample.LTE != nil {
+		stat := nokiaCell(sample.LTE)
+		stat["Band"] = strings.ToUpper(sample.LTE.Band)
+		doc["cell_LTE_stats_cfg"] = []any{map[string]any{"stat": stat}}
+	}
+
+	if sample.NR != nil {
+		stat := nokiaCell(sample.NR)
+		stat["Band"] = strings.ToLower(sample.NR.Band)
+		doc["cell_5G_stats_cfg"] = []any{map[string]any{"stat": stat}}
+	}
+
+	writeJSON(w, doc)
+}
+
+func nokiaCell(c *Cell) map[string]any {
+	return map[string]any{
+		"SignalStrengthLevel": c.Bars,
+		"PhysicalCellID":      c.CID,
+		"NodeID":              c.NodeID,
+		"RSRPCurrent":         c.RSRP,
+		"RSRQCurrent":         c.RSRQ,
+		"RSSICurrent":         c.RSSI,
+		"SNRCurrent":          c.SINR,
+	}
+}
+
+func (s *Server) nokiaDevice(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, map[string]any{
+		"Manufacturer":    "Nokia",
+		"ModelName":       "5G21",
+		"SerialNumber":    simulatedSerial,
+		"SoftwareVersion": simulatedVersion,
+		"MACAddress":      simulatedMAC,
+		"UpTime":          int(time.Since(s.started).Seconds()),
+	})
+}
+
+func (s *Server) nokiaReboot(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(nokiaSessionCookie)
+	if err != nil || !s.validToken(cookie.Value) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	writeJSON(w, map[string]any{"result": 0})
+	s.reboot()
+}
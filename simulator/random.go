@@ -0,0 +1,58 @@
+package simulator
+
+import "math/rand/v2"
+
+// randomSample returns typical 4G and 5G signals to start drifting from.
+func randomSample() Sample {
+	return Sample{
+		LTE: &Cell{
+			Band: "b66", Bars: 3, CID: 1001, NodeID: 12345,
+			RSRP: -100, RSRQ: -11, RSSI: -70, SINR: 8,
+		},
+		NR: &Cell{
+			Band: "n41", Bars: 4, CID: 2001, NodeID: 67890,
+			RSRP: -90, RSRQ: -10, RSSI: -62, SINR: 14,
+		},
+	}
+}
+
+// drift moves every measurement of s by a small random step, within the
+// range a modem reports.
+func (s Sample) drift() Sample {
+	return Sample{LTE: s.LTE.drift(), NR: s.NR.drift()}
+}
+
+func (c *Cell) drift() *Cell {
+	if c == nil {
+		return nil
+	}
+
+	d := *c
+	d.RSRP = step(c.RSRP, -140, -44)
+	d.RSRQ = step(c.RSRQ, -20, -3)
+	d.RSSI = step(c.RSSI, -110, -40)
+	d.SINR = step(c.SINR, -10, 30)
+	d.Bars = bars(d.RSRP)
+
+	return &d
+}
+
+func step(value, lowest, highest int) int {
+	return min(max(value+rand.IntN(5)-2, lowest), highest) //nolint:gosec,mnd
+}
+
+// bars rates rsrp the way the gateways' displays do, from 1 to 5 bars.
+func bars(rsrp int) float64 {
+	switch {
+	case rsrp >= -80:
+		return 5
+	case rsrp >= -90:
+		return 4
+	case rsrp >= -100:
+		return 3
+	case rsrp >= -110:
+		return 2
+	default:
+		return 1
+	}
+}
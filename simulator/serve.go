@@ -0,0 +1,59 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Values shared by both models.
+const (
+	simulatedAPN     = "FBB.HOME"
+	simulatedMAC     = "00:00:5E:00:53:01"
+	simulatedSerial  = "SIMULATED"
+	simulatedVersion = "1.0.0-sim"
+
+	readHeaderTimeout = 5 * time.Second
+)
+
+// TB is the part of testing.TB Start uses.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...any)
+	Cleanup(f func())
+}
+
+// Start serves a simulated gateway on a free local port until the test ends.
+// It returns the gateway and its host:port address.
+func Start(tb TB, opts Options) (*Server, string) {
+	tb.Helper()
+
+	s, err := New(opts)
+	if err != nil {
+		tb.Fatalf("simulator: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("simulator: %v", err)
+	}
+
+	server := &http.Server{Handler: s, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() { _ = server.Serve(listener) }()
+
+	tb.Cleanup(func() { _ = server.Close() })
+
+	return s, listener.Addr().String()
+}
+
+func serveIndex(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = w.Write([]byte("<html><title>Simulated gateway</title></html>\n"))
+}
+
+func writeJSON(w http.ResponseWriter, doc any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,233 @@
+// Package simulator serves fake T-Mobile Home Internet gateway web APIs, so
+// that tmhi-cli and programs built on tmhi-gateway can be exercised without a
+// gateway.
+//
+// It answers login, information, status, signal and reboot requests of the
+// Arcadyan and Nokia 5G21 web interfaces, the Arcadyan client list, as well as
+// the unauthenticated requests model detection relies on. Only the fields
+// clients read are served. After a reboot, the simulated gateway drops every
+// connection for Options.Downtime, as a real one would while restarting.
+package simulator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Models the simulator can play.
+const (
+	ModelArcadyan = "ARCADYAN"
+	ModelNokia    = "NOK5G21"
+)
+
+// DefaultUsername is the admin username accepted when Options.Username is
+// empty.
+const DefaultUsername = "admin"
+
+// ErrUnknownModel is returned by New for a model the simulator cannot play.
+var ErrUnknownModel = errors.New("unknown gateway model")
+
+// Cell is the signal of one radio access technology.
+type Cell struct {
+	Band string  `json:"band"`
+	Bars float64 `json:"bars"`
+	CID  int     `json:"cid"`
+	// NodeID is the eNodeB ID on 4G and the gNodeB ID on 5G.
+	NodeID int `json:"nodeId"`
+	RSRP   int `json:"rsrp"`
+	RSRQ   int `json:"rsrq"`
+	RSSI   int `json:"rssi"`
+	SINR   int `json:"sinr"`
+}
+
+// Sample is the signal served by one signal request. A nil cell is reported
+// as not connected.
+type Sample struct {
+	LTE *Cell `json:"4g,omitempty"`
+	NR  *Cell `json:"5g,omitempty"`
+}
+
+// Options configure a simulated gateway.
+type Options struct {
+	// Model is ModelArcadyan or ModelNokia.
+	Model string
+	// Username and Password are the admin credentials. Any password is
+	// accepted when Password is empty. The Nokia login only sends hashes of
+	// the password, so its password is never checked.
+	Username string
+	Password string
+	// Downtime is how long the gateway stays unreachable after a reboot.
+	Downtime time.Duration
+	// Samples are served in turn by signal requests, starting over after the
+	// last one. Random values are served when there are none.
+	Samples []Sample
+	// Logf, when set, is called for every request.
+	Logf func(format string, args ...any)
+}
+
+// Server is a simulated gateway. It is an http.Handler.
+type Server struct {
+	opts    Options
+	routes  map[string]http.HandlerFunc
+	started time.Time
+
+	mu        sync.Mutex
+	token     string
+	downUntil time.Time
+	logins    int
+	reboots   int
+	next      int
+	random    Sample
+}
+
+// New returns a simulated gateway of opts.Model.
+func New(opts Options) (*Server, error) {
+	if opts.Username == "" {
+		opts.Username = DefaultUsername
+	}
+
+	s := &Server{opts: opts, started: time.Now(), random: randomSample()}
+
+	switch opts.Model {
+	case ModelArcadyan:
+		s.routes = s.arcadyanRoutes()
+	case ModelNokia:
+		s.routes = s.nokiaRoutes()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, opts.Model)
+	}
+
+	return s, nil
+}
+
+// Logins returns the number of successful logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.logins
+}
+
+// Reboots returns the number of reboots requested.
+func (s *Server) Reboots() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reboots
+}
+
+// Down reports whether the gateway is rebooting.
+func (s *Server) Down() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Now().Before(s.downUntil)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Logf != nil {
+		s.opts.Logf("%s %s", r.Method, r.URL.RequestURI())
+	}
+
+	if s.Down() {
+		dropConnection(w)
+
+		return
+	}
+
+	route, ok := s.routes[r.Method+" "+r.URL.RequestURI()]
+	if !ok {
+		route, ok = s.routes[r.Method+" "+r.URL.Path]
+	}
+
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	route(w, r)
+}
+
+// dropConnection closes the connection without answering, like a gateway
+// that is restarting.
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+
+	_ = conn.Close()
+}
+
+// validPassword reports whether password may log in.
+func (s *Server) validPassword(username, password string) bool {
+	return username == s.opts.Username && (s.opts.Password == "" || password == s.opts.Password)
+}
+
+// newSession records a successful login and returns its token.
+func (s *Server) newSession() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins++
+	s.token = randomToken()
+
+	return s.token
+}
+
+func (s *Server) validToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return token != "" && token == s.token
+}
+
+// reboot takes the gateway down for the configured downtime, ending the
+// current session.
+func (s *Server) reboot() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reboots++
+	s.token = ""
+	s.downUntil = time.Now().Add(s.opts.Downtime)
+}
+
+// sample returns the signal to serve next.
+func (s *Server) sample() Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.opts.Samples) > 0 {
+		sample := s.opts.Samples[s.next%len(s.opts.Samples)]
+		s.next++
+
+		return sample
+	}
+
+	s.random = s.random.drift()
+
+	return s.random
+}
+
+func randomToken() string {
+	const tokenBytes = 16
+
+	token := make([]byte, tokenBytes)
+	_, _ = rand.Read(token)
+
+	return hex.EncodeToString(token)
+}
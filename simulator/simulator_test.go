@@ -0,0 +1,251 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, addr, path string, doc any) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, "http://"+addr+path, nil)
+	require.NoError(t, err)
+
+	return do(t, req, doc)
+}
+
+func do(t *testing.T, req *http.Request, doc any) int {
+	t.Helper()
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if doc != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(doc))
+	}
+
+	return resp.StatusCode
+}
+
+func arcadyanLogin(t *testing.T, addr, password string) (string, int) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost,
+		"http://"+addr+arcadyanLoginPath,
+		strings.NewReader(`{"username":"admin","password":"`+password+`"}`))
+	require.NoError(t, err)
+
+	var doc struct {
+		Auth struct {
+			Token string `json:"token"`
+		} `json:"auth"`
+	}
+
+	status := do(t, req, &doc)
+
+	return doc.Auth.Token, status
+}
+
+func arcadyanReboot(t *testing.T, addr, token string) int {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost,
+		"http://"+addr+arcadyanRebootPath, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return do(t, req, nil)
+}
+
+func TestNew_UnknownModel(t *testing.T) {
+	_, err := New(Options{Model: "FAST5688W"})
+	require.ErrorIs(t, err, ErrUnknownModel)
+}
+
+func TestArcadyan_Login(t *testing.T) {
+	sim, addr := Start(t, Options{Model: ModelArcadyan, Password: "s3cret"})
+
+	_, status := arcadyanLogin(t, addr, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	token, status := arcadyanLogin(t, addr, "s3cret")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, 1, sim.Logins())
+}
+
+func TestArcadyan_Gateway(t *testing.T) {
+	_, addr := Start(t, Options{Model: ModelArcadyan})
+
+	var doc struct {
+		Device struct {
+			Manufacturer string `json:"manufacturer"`
+		} `json:"device"`
+		Signal map[string]json.RawMessage `json:"signal"`
+	}
+
+	require.Equal(t, http.StatusOK, get(t, addr, arcadyanGatewayPath, &doc))
+	assert.Equal(t, "Arcadyan", doc.Device.Manufacturer)
+	assert.Contains(t, doc.Signal, "4g")
+	assert.Contains(t, doc.Signal, "5g")
+	assert.Contains(t, doc.Signal, "generic")
+}
+
+func TestArcadyan_Clients(t *testing.T) {
+	_, addr := Start(t, Options{Model: ModelArcadyan})
+
+	assert.Equal(t, http.StatusUnauthorized, get(t, addr, arcadyanClientsPath, nil))
+
+	token, _ := arcadyanLogin(t, addr, "anything")
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet,
+		"http://"+addr+arcadyanClientsPath, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var doc struct {
+		Clients map[string][]json.RawMessage `json:"clients"`
+	}
+
+	require.Equal(t, http.StatusOK, do(t, req, &doc))
+	assert.Len(t, doc.Clients["5.0ghz"], 2)
+	assert.Len(t, doc.Clients["ethernet"], 1)
+}
+
+func TestArcadyan_RebootGoesDown(t *testing.T) {
+	const downtime = 300 * time.Millisecond
+
+	sim, addr := Start(t, Options{Model: ModelArcadyan, Downtime: downtime})
+
+	assert.Equal(t, http.StatusUnauthorized, arcadyanReboot(t, addr, "forged"))
+	assert.Zero(t, sim.Reboots())
+
+	token, _ := arcadyanLogin(t, addr, "anything")
+	assert.Equal(t, http.StatusOK, arcadyanReboot(t, addr, token))
+	assert.Equal(t, 1, sim.Reboots())
+	assert.True(t, sim.Down())
+
+	_, err := http.Get("http://" + addr + "/") //nolint:noctx
+	require.Error(t, err, "connections are dropped while rebooting")
+
+	require.Eventually(t, func() bool { return !sim.Down() }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, get(t, addr, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, arcadyanReboot(t, addr, token),
+		"a reboot ends the session")
+}
+
+func TestSamples_Scripted(t *testing.T) {
+	samples := []Sample{
+		{LTE: &Cell{Band: "b2", RSRP: -90}},
+		{NR: &Cell{Band: "n71", RSRP: -110}},
+	}
+	_, addr := Start(t, Options{Model: ModelArcadyan, Samples: samples})
+
+	rsrps := make([]int, 0, 3)
+
+	for range 3 {
+		var doc struct {
+			Signal map[string]struct {
+				RSRP  int      `json:"rsrp"`
+				Bands []string `json:"bands"`
+			} `json:"signal"`
+		}
+
+		require.Equal(t, http.StatusOK, get(t, addr, arcadyanSignalPath, &doc))
+
+		for _, key := range []string{"4g", "5g"} {
+			if cell, ok := doc.Signal[key]; ok {
+				rsrps = append(rsrps, cell.RSRP)
+			}
+		}
+	}
+
+	assert.Equal(t, []int{-90, -110, -90}, rsrps)
+}
+
+func TestSamples_RandomStayInRange(t *testing.T) {
+	sample := randomSample()
+
+	for range 1000 {
+		sample = sample.drift()
+
+		assert.GreaterOrEqual(t, sample.LTE.RSRP, -140)
+		assert.LessOrEqual(t, sample.LTE.RSRP, -44)
+		assert.GreaterOrEqual(t, sample.NR.SINR, -10)
+		assert.LessOrEqual(t, sample.NR.SINR, 30)
+	}
+}
+
+func TestNokia_LoginAndReboot(t *testing.T) {
+	sim, addr := Start(t, Options{Model: ModelNokia, Downtime: time.Minute})
+
+	var nonce struct {
+		Nonce string `json:"nonce"`
+	}
+
+	require.Equal(t, http.StatusOK, get(t, addr, nokiaNoncePath, &nonce))
+	require.NotEmpty(t, nonce.Nonce)
+
+	login := func(form url.Values) (*http.Cookie, int) {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost,
+			"http://"+addr+nokiaLoginPath, strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close() //nolint:errcheck
+
+		var doc struct {
+			Result int `json:"result"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == nokiaSessionCookie {
+				return cookie, doc.Result
+			}
+		}
+
+		return nil, doc.Result
+	}
+
+	_, result := login(url.Values{"nonce": {nonce.Nonce}})
+	assert.Equal(t, 1, result)
+
+	cookie, result := login(url.Values{
+		"nonce": {nonce.Nonce}, "userhash": {"u"}, "response": {"r"},
+	})
+	require.NotNil(t, cookie)
+	assert.Zero(t, result)
+	assert.Equal(t, 1, sim.Logins())
+
+	var radio struct {
+		LTE []struct {
+			Stat map[string]any `json:"stat"`
+		} `json:"cell_LTE_stats_cfg"`
+	}
+
+	require.Equal(t, http.StatusOK, get(t, addr, nokiaRadioPath, &radio))
+	require.Len(t, radio.LTE, 1)
+	assert.Equal(t, "B66", radio.LTE[0].Stat["Band"])
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodPost,
+		"http://"+addr+nokiaRebootPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, do(t, req, nil))
+
+	req.AddCookie(cookie)
+	assert.Equal(t, http.StatusOK, do(t, req, nil))
+	assert.True(t, sim.Down())
+}